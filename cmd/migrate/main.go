@@ -35,10 +35,38 @@ func main() {
 		os.Exit(1)
 	}
 
-	if err := db.AutoMigrate(&domain.TodoItem{}); err != nil {
+	if err := db.AutoMigrate(&domain.TodoItem{}, &domain.List{}, &domain.Membership{}, &domain.IdempotencyRecord{}, &domain.AuditEntry{}, &domain.TodoVersion{}, &domain.ChangeCounter{}, &domain.OutboxMessage{}, &domain.Webhook{}, &domain.WebhookDelivery{}); err != nil {
 		slog.Error("failed to run migrations", slog.Any("err", err))
 		os.Exit(1)
 	}
 
+	// todo items used to be keyed by their id alone, so that an id was taken in every workspace
+	var keyColumns int64
+	if err := db.Raw(`SELECT count(*) FROM information_schema.key_column_usage
+		WHERE table_name = 'todo_items' AND constraint_name = 'todo_items_pkey'`).Scan(&keyColumns).Error; err != nil {
+		slog.Error("failed to read the primary key of todo items", slog.Any("err", err))
+		os.Exit(1)
+	}
+	if keyColumns == 1 {
+		if err := db.Exec(`ALTER TABLE todo_items DROP CONSTRAINT todo_items_pkey, ADD PRIMARY KEY (workspace_id, id)`).Error; err != nil {
+			slog.Error("failed to key todo items by workspace", slog.Any("err", err))
+			os.Exit(1)
+		}
+	}
+	if err := db.Exec(`DROP INDEX IF EXISTS idx_todo_items_workspace_item`).Error; err != nil {
+		slog.Error("failed to drop the superseded index of todo items", slog.Any("err", err))
+		os.Exit(1)
+	}
+
+	// items written before versions were recorded start their history with their current state
+	backfill := db.Exec(`INSERT INTO todo_item_versions
+		(workspace_id, todo_id, version, list_id, owner_id, description, due_date, completed, deleted_at, valid_from)
+		SELECT workspace_id, id, version, list_id, owner_id, description, due_date, completed, deleted_at, CURRENT_TIMESTAMP
+		FROM todo_items t WHERE NOT EXISTS (SELECT 1 FROM todo_item_versions v WHERE v.workspace_id = t.workspace_id AND v.todo_id = t.id)`)
+	if backfill.Error != nil {
+		slog.Error("failed to backfill todo item versions", slog.Any("err", backfill.Error))
+		os.Exit(1)
+	}
+
 	slog.Info("database migrations applied successfully", slog.String("env", env))
 }
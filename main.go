@@ -7,12 +7,18 @@ import (
 	"os"
 
 	"github.com/gin-gonic/gin"
+	"github.com/taheri24/helitask/pkg/adapter/gqlapi"
+	"github.com/taheri24/helitask/pkg/adapter/grpcapi"
 	"github.com/taheri24/helitask/pkg/adapter/handlers"
 	"github.com/taheri24/helitask/pkg/config"
 	"github.com/taheri24/helitask/pkg/di"
+	"github.com/taheri24/helitask/pkg/events"
 	"github.com/taheri24/helitask/pkg/logger"
+	"github.com/taheri24/helitask/pkg/policy"
 	"github.com/taheri24/helitask/pkg/ports/storage"
 	"github.com/taheri24/helitask/pkg/server"
+	"github.com/taheri24/helitask/pkg/service"
+	"github.com/taheri24/helitask/pkg/webhooks"
 	"go.uber.org/fx"
 )
 
@@ -36,10 +42,17 @@ func main() {
 		fx.NopLogger,
 		fx.Provide(logger.Default, di.ProvideDB),
 		fx.Supply(cfg, appRoot),
+		server.Module,
 		storage.Module,
+		storage.CacheModule,
+		policy.Module,
+		service.Module,
+		events.Module,
+		webhooks.Module,
 		handlers.Module,
-		fx.Invoke(storage.EnsureDatabaseServerVersion),
-		fx.Invoke(server.StartServer),
+		gqlapi.Module,
+		grpcapi.Module,
+		fx.Invoke(storage.EnsureDatabaseServerVersion, storage.StartIdempotencyPurge, storage.StartTrashPurge, events.StartRelay, webhooks.StartDeliverer),
 	)
 
 	if err := app.Start(context.Background()); err != nil {
@@ -47,5 +60,13 @@ func main() {
 		os.Exit(1)
 	}
 
-	defer app.Stop(context.Background())
+	// Run until the process is asked to stop, then give the running requests and
+	// open connections the configured time to finish
+	<-app.Done()
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	if err := app.Stop(ctx); err != nil {
+		slog.Error("Failed to stop application gracefully", slog.Any("err", err))
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,76 @@
+package gqlapi
+
+import (
+	"context"
+	"errors"
+
+	"github.com/taheri24/helitask/pkg/domain"
+	"github.com/taheri24/helitask/pkg/logger"
+	"github.com/taheri24/helitask/pkg/service"
+)
+
+// Error codes set in the extensions of the errors of a response
+const (
+	codeBadInput        = "BAD_USER_INPUT"
+	codeNotFound        = "NOT_FOUND"
+	codeConflict        = "CONFLICT"
+	codeVersionConflict = "VERSION_CONFLICT"
+	codeUnauthenticated = "UNAUTHENTICATED"
+	codeForbidden       = "FORBIDDEN"
+	codeQuotaExceeded   = "QUOTA_EXCEEDED"
+	codeTimeout         = "TIMEOUT"
+	codeInternal        = "INTERNAL_SERVER_ERROR"
+)
+
+// resolverError is an error of a field, with a code clients can tell it by
+type resolverError struct {
+	code    string
+	message string
+}
+
+func (e *resolverError) Error() string {
+	return e.message
+}
+
+// Extensions is read by graphql-go into the extensions of the error
+func (e *resolverError) Extensions() map[string]any {
+	return map[string]any{"code": e.code}
+}
+
+func badInput(message string) error {
+	return &resolverError{codeBadInput, message}
+}
+
+// errorCode returns the code answering err, and whether err is an unexpected failure
+func errorCode(err error) (string, bool) {
+	switch {
+	case errors.Is(err, service.ErrInvalidInput):
+		return codeBadInput, false
+	case errors.Is(err, domain.ErrRecordNotFound):
+		return codeNotFound, false
+	case errors.Is(err, domain.ErrConflict):
+		return codeConflict, false
+	case errors.Is(err, domain.ErrVersionConflict):
+		return codeVersionConflict, false
+	case errors.Is(err, domain.ErrUnauthenticated):
+		return codeUnauthenticated, false
+	case errors.Is(err, domain.ErrForbidden):
+		return codeForbidden, false
+	case errors.Is(err, domain.ErrTodoQuotaExceeded), errors.Is(err, domain.ErrDescriptionTooLarge):
+		return codeQuotaExceeded, false
+	case errors.Is(err, context.DeadlineExceeded):
+		return codeTimeout, false
+	}
+	return codeInternal, true
+}
+
+// toError maps the errors of the todo service, the repositories and the policy onto field errors. Unexpected
+// failures are logged and answered with message alone, so that no internals leak to clients.
+func toError(log logger.Logger, message string, err error) error {
+	code, unexpected := errorCode(err)
+	if unexpected {
+		log.Error(message, err)
+		return &resolverError{code, message}
+	}
+	return &resolverError{code, err.Error()}
+}
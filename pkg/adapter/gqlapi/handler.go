@@ -0,0 +1,55 @@
+package gqlapi
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/taheri24/helitask/pkg/domain"
+)
+
+//go:embed schema.graphql
+var schemaSDL string
+
+// maxQueryDepth bounds the nesting of a query, which lists and their items could otherwise
+// repeat without end
+const maxQueryDepth = 10
+
+// NewSchema parses the schema and binds it to its resolvers. Up to a page of fields resolve
+// at once, so that the loaders batch the reads of a whole page.
+func NewSchema(resolver *Resolver) *graphql.Schema {
+	return graphql.MustParseSchema(schemaSDL, resolver,
+		graphql.MaxParallelism(domain.MaxPageSize),
+		graphql.MaxDepth(maxQueryDepth),
+	)
+}
+
+// Handler serves GraphQL requests over HTTP
+type Handler struct {
+	schema *graphql.Schema
+	todos  domain.TodoRepository
+	lists  domain.ListRepository
+}
+
+// NewHandler creates the Handler of schema
+func NewHandler(schema *graphql.Schema, todos domain.TodoRepository, lists domain.ListRepository) *Handler {
+	return &Handler{schema: schema, todos: todos, lists: lists}
+}
+
+// Serve handles a POSTed GraphQL request. The response is 200 with the errors of the fields in
+// its errors, unless the request body is no GraphQL request at all.
+func (h *Handler) Serve(c *gin.Context) {
+	var req struct {
+		Query         string         `json:"query"`
+		OperationName string         `json:"operationName"`
+		Variables     map[string]any `json:"variables"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.Query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": []gin.H{{"message": "the body must be a JSON object with a query"}}})
+		return
+	}
+	// every request gets loaders of its own, so that no read outlives it or crosses workspaces
+	ctx := withLoaders(c.Request.Context(), newLoaders(h.todos, h.lists))
+	c.JSON(http.StatusOK, h.schema.Exec(ctx, req.Query, req.OperationName, req.Variables))
+}
@@ -0,0 +1,182 @@
+package gqlapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/taheri24/helitask/pkg/auth"
+	"github.com/taheri24/helitask/pkg/config"
+	"github.com/taheri24/helitask/pkg/domain"
+	"github.com/taheri24/helitask/pkg/logger"
+	"github.com/taheri24/helitask/pkg/policy"
+	"github.com/taheri24/helitask/pkg/ports/storage"
+	"github.com/taheri24/helitask/pkg/ports/storage/sqlite"
+	"github.com/taheri24/helitask/pkg/service"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxtest"
+)
+
+// countingRepository counts the reads of items by id
+type countingRepository struct {
+	domain.TodoRepository
+	gets, batches atomic.Int32
+}
+
+func (r *countingRepository) GetByID(ctx context.Context, id domain.UUID) (*domain.TodoItem, error) {
+	r.gets.Add(1)
+	return r.TodoRepository.GetByID(ctx, id)
+}
+
+func (r *countingRepository) GetByIDs(ctx context.Context, ids []domain.UUID) ([]*domain.TodoItem, error) {
+	r.batches.Add(1)
+	return r.TodoRepository.GetByIDs(ctx, ids)
+}
+
+type response struct {
+	Data   map[string]json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string         `json:"message"`
+		Extensions map[string]any `json:"extensions"`
+	} `json:"errors"`
+}
+
+// TestGraphQL tests queries, mutations and the batching of reads by id
+func TestGraphQL(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := config.Default()
+	cfg.Auth.JWTSecret = "test-secret"
+	app, counter := gin.New(), &countingRepository{}
+	fxApp := fxtest.New(t, fx.NopLogger, fx.Provide(logger.Nop), fx.Supply(sqlite.NewDb(t, ""), app, cfg),
+		storage.Module, policy.Module, service.Module, Module,
+		fx.Decorate(func(repo domain.TodoRepository) domain.TodoRepository {
+			counter.TodoRepository = repo
+			return counter
+		}))
+	fxApp.RequireStart()
+	defer fxApp.RequireStop()
+	query := func(user, query string, variables map[string]any) response {
+		token, err := auth.SignHS256(auth.Claims{"sub": user, "workspace": "default"}, []byte(cfg.Auth.JWTSecret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := json.Marshal(map[string]any{"query": query, "variables": variables})
+		req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body)))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var out response
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &out))
+		return out
+	}
+	field := func(res response, name string, into any) {
+		if assert.Empty(t, res.Errors) {
+			assert.NoError(t, json.Unmarshal(res.Data[name], into))
+		}
+	}
+	code := func(res response) any {
+		if assert.NotEmpty(t, res.Errors) {
+			return res.Errors[0].Extensions["code"]
+		}
+		return nil
+	}
+
+	var list struct{ ID string }
+	field(query("alice", `mutation { createList(name: "Sprint 1") { id } }`, nil), "createList", &list)
+	const create = `mutation($input: TodoInput!) { createTodo(input: $input) { id version ownerId list { name } } }`
+	var ids []string
+	for _, description := range []string{"First", "Second", "Third"} {
+		var todo struct {
+			ID      string
+			Version int
+			OwnerID string
+			List    struct{ Name string }
+		}
+		field(query("alice", create, map[string]any{"input": map[string]any{
+			"description": description, "dueDate": "2025-03-01T10:00:00Z", "listId": list.ID,
+		}}), "createTodo", &todo)
+		assert.Equal(t, 1, todo.Version)
+		assert.Equal(t, "alice", todo.OwnerID)
+		assert.Equal(t, "Sprint 1", todo.List.Name)
+		ids = append(ids, todo.ID)
+	}
+	assert.Equal(t, "BAD_USER_INPUT", code(query("alice", create, map[string]any{"input": map[string]any{
+		"description": "", "dueDate": "2025-03-01T10:00:00Z"}})))
+	field(query("bob", create, map[string]any{"input": map[string]any{
+		"description": "Private to bob", "dueDate": "2025-03-02T10:00:00Z"}}), "createTodo", &struct{}{})
+
+	// many items read by id in one request make a single read
+	counter.gets.Store(0)
+	counter.batches.Store(0)
+	res := query("alice", `query($a: ID!, $b: ID!, $c: ID!, $missing: ID!) {
+		a: todo(id: $a) { description } b: todo(id: $b) { description } c: todo(id: $c) { description }
+		missing: todo(id: $missing) { description } }`,
+		map[string]any{"a": ids[0], "b": ids[1], "c": ids[2], "missing": domain.NewUUID().String()})
+	assert.Empty(t, res.Errors)
+	assert.JSONEq(t, `{"description": "Second"}`, string(res.Data["b"]))
+	assert.JSONEq(t, `null`, string(res.Data["missing"]))
+	assert.Equal(t, int32(0), counter.gets.Load())
+	assert.Equal(t, int32(1), counter.batches.Load(), "the reads by id are batched")
+
+	var conn struct {
+		Edges []struct {
+			Cursor string
+			Node   struct{ Description string }
+		}
+		PageInfo struct {
+			HasNextPage bool
+			EndCursor   string
+		}
+	}
+	const todos = `query($after: String) { todos(first: 2, after: $after) {
+		edges { cursor node { description list { name } } } pageInfo { hasNextPage endCursor } } }`
+	field(query("alice", todos, nil), "todos", &conn)
+	assert.Len(t, conn.Edges, 2)
+	assert.True(t, conn.PageInfo.HasNextPage)
+	assert.Equal(t, conn.Edges[1].Cursor, conn.PageInfo.EndCursor)
+	field(query("alice", todos, map[string]any{"after": conn.PageInfo.EndCursor}), "todos", &conn)
+	if assert.Len(t, conn.Edges, 1, "the item of bob is left out") {
+		assert.NotEqual(t, "Private to bob", conn.Edges[0].Node.Description)
+	}
+	assert.False(t, conn.PageInfo.HasNextPage)
+	assert.Equal(t, "BAD_USER_INPUT", code(query("alice", todos, map[string]any{"after": "nope"})))
+
+	var updated struct {
+		Description string
+		Completed   bool
+		Version     int
+	}
+	const update = `mutation($id: ID!, $version: Int, $list: ID) { updateTodo(id: $id, expectedVersion: $version,
+		input: {description: "First, done", dueDate: "2025-03-01T10:00:00Z", completed: true, listId: $list}) { description completed version } }`
+	field(query("alice", update, map[string]any{"id": ids[0], "version": 1, "list": list.ID}), "updateTodo", &updated)
+	assert.Equal(t, 2, updated.Version)
+	assert.True(t, updated.Completed)
+	assert.Equal(t, "VERSION_CONFLICT", code(query("alice", update, map[string]any{"id": ids[0], "version": 1, "list": list.ID})))
+	assert.Equal(t, "FORBIDDEN", code(query("bob", update, map[string]any{"id": ids[0], "list": list.ID})))
+
+	var listed struct {
+		Name  string
+		Todos struct {
+			Edges []struct{ Node struct{ Description string } }
+		}
+	}
+	field(query("alice", `query($id: ID!) { list(id: $id) { name todos(filter: {completed: true}) { edges { node { description } } } } }`,
+		map[string]any{"id": list.ID}), "list", &listed)
+	if assert.Len(t, listed.Todos.Edges, 1) {
+		assert.Equal(t, "First, done", listed.Todos.Edges[0].Node.Description)
+	}
+	assert.Equal(t, "FORBIDDEN", code(query("bob", `query($id: ID!) { list(id: $id) { name } }`, map[string]any{"id": list.ID})))
+
+	res = query("alice", `mutation($id: ID!) { deleteTodo(id: $id) }`, map[string]any{"id": ids[1]})
+	assert.Empty(t, res.Errors)
+	res = query("alice", `query($id: ID!) { todo(id: $id) { id } }`, map[string]any{"id": ids[1]})
+	assert.JSONEq(t, `null`, string(res.Data["todo"]), "deleted items are in the trash")
+	assert.Equal(t, "NOT_FOUND", code(query("alice", `mutation($id: ID!) { deleteTodo(id: $id) }`, map[string]any{"id": ids[1]})))
+}
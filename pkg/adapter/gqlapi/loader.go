@@ -0,0 +1,61 @@
+package gqlapi
+
+import (
+	"context"
+	"time"
+
+	"github.com/graph-gophers/dataloader/v7"
+	"github.com/taheri24/helitask/pkg/domain"
+)
+
+// loaderWait is how long a loader collects the keys of a batch after the first one
+const loaderWait = 2 * time.Millisecond
+
+// loaders batch the reads of one request by id, so that resolving many items makes one query
+// per batch rather than one per item. They remember what they read until the request ends.
+type loaders struct {
+	todos *dataloader.Loader[domain.UUID, *domain.TodoItem]
+	lists *dataloader.Loader[domain.UUID, *domain.List]
+}
+
+func newLoaders(todos domain.TodoRepository, lists domain.ListRepository) *loaders {
+	return &loaders{
+		todos: dataloader.NewBatchedLoader(batchByID(todos.GetByIDs, func(t *domain.TodoItem) domain.UUID { return t.ID }),
+			dataloader.WithWait[domain.UUID, *domain.TodoItem](loaderWait)),
+		lists: dataloader.NewBatchedLoader(batchByID(lists.GetByIDs, func(l *domain.List) domain.UUID { return l.ID }),
+			dataloader.WithWait[domain.UUID, *domain.List](loaderWait)),
+	}
+}
+
+// batchByID adapts a read of many records by id to a batch function, which must answer every
+// key in order; keys without a record get ErrRecordNotFound
+func batchByID[V any](get func(context.Context, []domain.UUID) ([]V, error), id func(V) domain.UUID) dataloader.BatchFunc[domain.UUID, V] {
+	return func(ctx context.Context, keys []domain.UUID) []*dataloader.Result[V] {
+		results := make([]*dataloader.Result[V], len(keys))
+		records, err := get(ctx, keys)
+		byID := make(map[domain.UUID]V, len(records))
+		for _, record := range records {
+			byID[id(record)] = record
+		}
+		for i, key := range keys {
+			if record, ok := byID[key]; ok {
+				results[i] = &dataloader.Result[V]{Data: record}
+			} else if err != nil {
+				results[i] = &dataloader.Result[V]{Error: err}
+			} else {
+				results[i] = &dataloader.Result[V]{Error: domain.ErrRecordNotFound}
+			}
+		}
+		return results
+	}
+}
+
+type loadersKey struct{}
+
+func withLoaders(ctx context.Context, l *loaders) context.Context {
+	return context.WithValue(ctx, loadersKey{}, l)
+}
+
+func loadersFrom(ctx context.Context) *loaders {
+	return ctx.Value(loadersKey{}).(*loaders)
+}
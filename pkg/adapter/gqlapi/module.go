@@ -0,0 +1,19 @@
+package gqlapi
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/taheri24/helitask/pkg/config"
+	"github.com/taheri24/helitask/pkg/server"
+	"go.uber.org/fx"
+)
+
+// RegisterRoutes serves the GraphQL API at /graphql, behind the same middlewares as the REST API
+func RegisterRoutes(appEngine *gin.Engine, h *Handler, cfg *config.Config) {
+	appEngine.POST("/graphql", append(server.APIMiddlewares(cfg), h.Serve)...)
+}
+
+// Module provides the GraphQL API
+var Module = fx.Module("apiGraphqlV0",
+	fx.Provide(NewResolver, NewSchema, NewHandler),
+	fx.Invoke(RegisterRoutes),
+)
@@ -0,0 +1,271 @@
+package gqlapi
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/taheri24/helitask/pkg/domain"
+	"github.com/taheri24/helitask/pkg/logger"
+	"github.com/taheri24/helitask/pkg/service"
+)
+
+// Resolver is the root of the schema, resolving its queries and mutations over the same
+// todo service, repositories and policy as the REST API
+type Resolver struct {
+	todos  *service.TodoService
+	lists  domain.ListRepository
+	policy domain.Policy
+	logger logger.Logger
+}
+
+// NewResolver creates the root resolver
+func NewResolver(todos *service.TodoService, lists domain.ListRepository, policy domain.Policy, logger logger.Logger) *Resolver {
+	return &Resolver{todos: todos, lists: lists, policy: policy, logger: logger}
+}
+
+type todoFilterInput struct {
+	ListID    *graphql.ID
+	OwnerID   *string
+	Completed *bool
+}
+
+type todoInput struct {
+	Description string
+	DueDate     graphql.Time
+	Completed   *bool
+	ListID      *graphql.ID
+}
+
+type connectionArgs struct {
+	Filter *todoFilterInput
+	First  *int32
+	After  *string
+}
+
+// Todo resolves the item with the id, batched with the other items of the request
+func (r *Resolver) Todo(ctx context.Context, args struct{ ID graphql.ID }) (*todoResolver, error) {
+	id, err := parseID(args.ID, "id")
+	if err != nil {
+		return nil, err
+	}
+	todo, err := loadersFrom(ctx).todos.Load(ctx, id)()
+	if errors.Is(err, domain.ErrRecordNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, toError(r.logger, "failed to fetch todo item", err)
+	}
+	if err := r.policy.Authorize(ctx, domain.ActionReadTodo, domain.TodoResource(todo)); err != nil {
+		return nil, toError(r.logger, "failed to authorize request", err)
+	}
+	return &todoResolver{r, todo}, nil
+}
+
+// Todos resolves a page of the items matching the filter
+func (r *Resolver) Todos(ctx context.Context, args connectionArgs) (*connectionResolver, error) {
+	filter, err := parseFilter(args.Filter)
+	if err != nil {
+		return nil, err
+	}
+	return r.connection(ctx, filter, args)
+}
+
+// List resolves the list with the id, once the caller may read it. A missing list resolves to
+// null for every caller of the workspace.
+func (r *Resolver) List(ctx context.Context, args struct{ ID graphql.ID }) (*listResolver, error) {
+	id, err := parseID(args.ID, "id")
+	if err != nil {
+		return nil, err
+	}
+	list, err := loadersFrom(ctx).lists.Load(ctx, id)()
+	if errors.Is(err, domain.ErrRecordNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, toError(r.logger, "failed to fetch list", err)
+	}
+	if err := r.policy.Authorize(ctx, domain.ActionReadList, domain.ListResource(id)); err != nil {
+		return nil, toError(r.logger, "failed to authorize request", err)
+	}
+	return &listResolver{r, list}, nil
+}
+
+// CreateTodo stores a new item owned by the caller
+func (r *Resolver) CreateTodo(ctx context.Context, args struct {
+	ID    *graphql.ID
+	Input todoInput
+}) (*todoResolver, error) {
+	var id *domain.UUID
+	if args.ID != nil {
+		parsed, err := parseID(*args.ID, "id")
+		if err != nil {
+			return nil, err
+		}
+		id = &parsed
+	}
+	in, err := args.Input.fields()
+	if err != nil {
+		return nil, err
+	}
+	todo, err := r.todos.Create(ctx, id, in)
+	if err != nil {
+		return nil, toError(r.logger, "failed to save todo item", err)
+	}
+	loadersFrom(ctx).todos.Prime(ctx, todo.ID, todo)
+	return &todoResolver{r, todo}, nil
+}
+
+// UpdateTodo replaces the fields of an item like a PUT of the REST API
+func (r *Resolver) UpdateTodo(ctx context.Context, args struct {
+	ID              graphql.ID
+	Input           todoInput
+	ExpectedVersion *int32
+}) (*todoResolver, error) {
+	id, err := parseID(args.ID, "id")
+	if err != nil {
+		return nil, err
+	}
+	in, err := args.Input.fields()
+	if err != nil {
+		return nil, err
+	}
+	if args.ExpectedVersion != nil {
+		ctx = domain.WithExpectedVersion(ctx, int64(*args.ExpectedVersion))
+	}
+	todo, err := r.todos.Update(ctx, id, in)
+	if err != nil {
+		return nil, toError(r.logger, "failed to save todo item", err)
+	}
+	loadersFrom(ctx).todos.Clear(ctx, id).Prime(ctx, id, todo)
+	return &todoResolver{r, todo}, nil
+}
+
+// DeleteTodo moves an item to the trash
+func (r *Resolver) DeleteTodo(ctx context.Context, args struct{ ID graphql.ID }) (graphql.ID, error) {
+	id, err := parseID(args.ID, "id")
+	if err != nil {
+		return "", err
+	}
+	if err := r.todos.Delete(ctx, id); err != nil {
+		return "", toError(r.logger, "failed to delete todo item", err)
+	}
+	loadersFrom(ctx).todos.Clear(ctx, id)
+	return args.ID, nil
+}
+
+// CreateList stores a new list with the caller as its admin
+func (r *Resolver) CreateList(ctx context.Context, args struct{ Name string }) (*listResolver, error) {
+	if args.Name == "" {
+		return nil, badInput("name is required")
+	}
+	if err := r.policy.Authorize(ctx, domain.ActionCreateList, domain.Resource{}); err != nil {
+		return nil, toError(r.logger, "failed to authorize request", err)
+	}
+	list := domain.List{ID: domain.NewUUID(), Name: args.Name, CreatedBy: domain.PrincipalFromContext(ctx).UserID}
+	if err := r.lists.Create(ctx, &list); err != nil {
+		if errors.Is(err, domain.ErrConflict) {
+			return nil, &resolverError{codeConflict, "list name already in use"}
+		}
+		return nil, toError(r.logger, "failed to save list", err)
+	}
+	loadersFrom(ctx).lists.Prime(ctx, list.ID, &list)
+	return &listResolver{r, &list}, nil
+}
+
+// connection resolves a page of the items matching filter. The items the caller may not read
+// are left out, so a page may be short of first.
+func (r *Resolver) connection(ctx context.Context, filter domain.TodoFilter, args connectionArgs) (*connectionResolver, error) {
+	page := domain.Page{Limit: domain.DefaultPageSize}
+	if args.First != nil {
+		if *args.First < 1 || *args.First > domain.MaxPageSize {
+			return nil, badInput("first must be between 1 and " + strconv.Itoa(domain.MaxPageSize))
+		}
+		page.Limit = int(*args.First)
+	}
+	if args.After != nil {
+		position, err := decodeCursor(*args.After)
+		if err != nil {
+			return nil, badInput("invalid cursor")
+		}
+		page.Offset = position + 1
+	}
+	todos, err := r.todos.List(ctx, filter, page)
+	if err != nil {
+		return nil, toError(r.logger, "failed to list todo items", err)
+	}
+	conn := &connectionResolver{edges: make([]*edgeResolver, len(todos.Items)), hasNextPage: todos.More}
+	if todos.Next > page.Offset {
+		// the items left out still move the cursor past them
+		cursor := encodeCursor(todos.Next - 1)
+		conn.endCursor = &cursor
+	}
+	loaders := loadersFrom(ctx)
+	for i, todo := range todos.Items {
+		loaders.todos.Prime(ctx, todo.ID, todo)
+		conn.edges[i] = &edgeResolver{encodeCursor(todos.Positions[i]), &todoResolver{r, todo}}
+	}
+	return conn, nil
+}
+
+// fields reads the fields of in for the todo service, which validates them
+func (in *todoInput) fields() (service.TodoInput, error) {
+	var listID *domain.UUID
+	if in.ListID != nil {
+		id, err := parseID(*in.ListID, "listId")
+		if err != nil {
+			return service.TodoInput{}, err
+		}
+		listID = &id
+	}
+	return service.TodoInput{ListID: listID, Description: in.Description, DueDate: in.DueDate.Time, Completed: in.Completed != nil && *in.Completed}, nil
+}
+
+func parseFilter(in *todoFilterInput) (domain.TodoFilter, error) {
+	var filter domain.TodoFilter
+	if in == nil {
+		return filter, nil
+	}
+	if in.ListID != nil {
+		id, err := parseID(*in.ListID, "listId")
+		if err != nil {
+			return filter, err
+		}
+		filter.ListID = &id
+	}
+	if in.OwnerID != nil {
+		filter.OwnerID = *in.OwnerID
+	}
+	filter.Completed = in.Completed
+	return filter, nil
+}
+
+func parseID(value graphql.ID, field string) (domain.UUID, error) {
+	id, err := domain.ParseUUID(string(value))
+	if err != nil || id == (domain.UUID{}) {
+		return domain.UUID{}, badInput(field + " must be a UUID")
+	}
+	return id, nil
+}
+
+// encodeCursor makes the opaque cursor of the item at a position of a listing
+func encodeCursor(position int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("position:" + strconv.Itoa(position)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	position, ok := strings.CutPrefix(string(raw), "position:")
+	if !ok {
+		return 0, errors.New("unknown cursor")
+	}
+	n, err := strconv.Atoi(position)
+	if err != nil || n < 0 {
+		return 0, errors.New("invalid cursor position")
+	}
+	return n, nil
+}
@@ -0,0 +1,133 @@
+package gqlapi
+
+import (
+	"context"
+	"errors"
+
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/taheri24/helitask/pkg/domain"
+)
+
+type todoResolver struct {
+	root *Resolver
+	todo *domain.TodoItem
+}
+
+func (t *todoResolver) ID() graphql.ID {
+	return graphql.ID(t.todo.ID.String())
+}
+
+func (t *todoResolver) Description() string {
+	return t.todo.Description
+}
+
+func (t *todoResolver) DueDate() graphql.Time {
+	return graphql.Time{Time: t.todo.DueDate}
+}
+
+func (t *todoResolver) Completed() bool {
+	return t.todo.Completed
+}
+
+func (t *todoResolver) Version() int32 {
+	return int32(t.todo.Version)
+}
+
+func (t *todoResolver) OwnerID() *string {
+	if t.todo.OwnerID == "" {
+		return nil
+	}
+	return &t.todo.OwnerID
+}
+
+func (t *todoResolver) ListID() *graphql.ID {
+	if t.todo.ListID == nil {
+		return nil
+	}
+	id := graphql.ID(t.todo.ListID.String())
+	return &id
+}
+
+// List resolves the list of the item, batched with the lists of the other items of the request
+func (t *todoResolver) List(ctx context.Context) (*listResolver, error) {
+	if t.todo.ListID == nil {
+		return nil, nil
+	}
+	list, err := loadersFrom(ctx).lists.Load(ctx, *t.todo.ListID)()
+	if errors.Is(err, domain.ErrRecordNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, toError(t.root.logger, "failed to fetch list", err)
+	}
+	return &listResolver{t.root, list}, nil
+}
+
+type listResolver struct {
+	root *Resolver
+	list *domain.List
+}
+
+func (l *listResolver) ID() graphql.ID {
+	return graphql.ID(l.list.ID.String())
+}
+
+func (l *listResolver) Name() string {
+	return l.list.Name
+}
+
+func (l *listResolver) CreatedBy() string {
+	return l.list.CreatedBy
+}
+
+func (l *listResolver) CreatedAt() graphql.Time {
+	return graphql.Time{Time: l.list.CreatedAt}
+}
+
+// Todos resolves a page of the items of the list matching the filter
+func (l *listResolver) Todos(ctx context.Context, args connectionArgs) (*connectionResolver, error) {
+	filter, err := parseFilter(args.Filter)
+	if err != nil {
+		return nil, err
+	}
+	filter.ListID = &l.list.ID
+	return l.root.connection(ctx, filter, args)
+}
+
+type connectionResolver struct {
+	edges       []*edgeResolver
+	hasNextPage bool
+	endCursor   *string
+}
+
+func (c *connectionResolver) Edges() []*edgeResolver {
+	return c.edges
+}
+
+func (c *connectionResolver) PageInfo() *pageInfoResolver {
+	return &pageInfoResolver{c}
+}
+
+type pageInfoResolver struct {
+	conn *connectionResolver
+}
+
+func (p *pageInfoResolver) HasNextPage() bool {
+	return p.conn.hasNextPage
+}
+
+func (p *pageInfoResolver) EndCursor() *string {
+	return p.conn.endCursor
+}
+
+type edgeResolver struct {
+	cursor string
+	node   *todoResolver
+}
+
+func (e *edgeResolver) Cursor() string {
+	return e.cursor
+}
+
+func (e *edgeResolver) Node() *todoResolver {
+	return e.node
+}
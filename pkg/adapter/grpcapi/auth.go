@@ -0,0 +1,115 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+
+	"github.com/taheri24/helitask/pkg/auth"
+	"github.com/taheri24/helitask/pkg/config"
+	"github.com/taheri24/helitask/pkg/domain"
+	"github.com/taheri24/helitask/pkg/tenant"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// RequestIDMetadata carries the id of a call, like the X-Request-ID header of the REST API
+const RequestIDMetadata = "x-request-id"
+
+// Authenticator scopes the context of every call to its principal, workspace and request id,
+// the way the middlewares of the REST API do for requests. The principal comes from a bearer
+// token in the authorization metadata, and the workspace is resolved by the tenant.Resolver
+// of the REST API, with the metadata named like the tenant header.
+type Authenticator struct {
+	secret   []byte
+	resolver *tenant.Resolver
+}
+
+// NewAuthenticator creates an Authenticator from the auth and tenant settings
+func NewAuthenticator(cfg *config.Config) *Authenticator {
+	return &Authenticator{secret: []byte(cfg.Auth.JWTSecret), resolver: tenant.NewResolver(cfg)}
+}
+
+// Unary is the interceptor of unary calls
+func (a *Authenticator) Unary(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, err := a.scope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// Stream is the interceptor of streaming calls
+func (a *Authenticator) Stream(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := a.scope(ss.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, &scopedStream{ss, ctx})
+}
+
+// scopedStream is a ServerStream with the context of its call replaced
+type scopedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *scopedStream) Context() context.Context {
+	return s.ctx
+}
+
+// scope returns ctx carrying what the call is made by and for
+func (a *Authenticator) scope(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	id := first(md, RequestIDMetadata)
+	if !domain.ValidRequestID(id) {
+		id = domain.NewUUID().String()
+	}
+	ctx = domain.WithRequestID(ctx, id)
+
+	var claims auth.Claims
+	if token := auth.BearerToken(first(md, "authorization")); token != "" && len(a.secret) > 0 {
+		var err error
+		if claims, err = auth.ParseHS256(token, a.secret); err != nil {
+			return nil, status.Error(codes.Unauthenticated, "invalid bearer token")
+		}
+		ctx = domain.WithPrincipal(ctx, domain.Principal{UserID: claims.String("sub")})
+	}
+
+	ws, err := a.workspace(ctx, md, claims)
+	if err != nil {
+		return nil, err
+	}
+	return domain.WithWorkspace(ctx, ws), nil
+}
+
+// workspace resolves the workspace of a call
+func (a *Authenticator) workspace(ctx context.Context, md metadata.MD, claims auth.Claims) (domain.Workspace, error) {
+	req := tenant.Request{Claims: claims, Header: func(name string) string { return first(md, name) }, Host: first(md, ":authority")}
+	if p, ok := peer.FromContext(ctx); ok {
+		if addr, err := netip.ParseAddrPort(p.Addr.String()); err == nil {
+			req.Peer = addr.Addr()
+		}
+	}
+	ws, err := a.resolver.Resolve(req)
+	switch {
+	case errors.Is(err, domain.ErrUnauthenticated):
+		return ws, status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, tenant.ErrWorkspaceMismatch), errors.Is(err, tenant.ErrNoWorkspaceClaim):
+		return ws, status.Error(codes.PermissionDenied, err.Error())
+	case err != nil:
+		return ws, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return ws, nil
+}
+
+// first returns the first value of the metadata key, whose name is case-insensitive
+func first(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
@@ -0,0 +1,48 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+
+	"github.com/taheri24/helitask/pkg/domain"
+	"github.com/taheri24/helitask/pkg/logger"
+	"github.com/taheri24/helitask/pkg/service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// statusCode returns the gRPC code answering err, and whether err is an unexpected failure
+func statusCode(err error) (codes.Code, bool) {
+	switch {
+	case errors.Is(err, service.ErrInvalidInput):
+		return codes.InvalidArgument, false
+	case errors.Is(err, domain.ErrRecordNotFound):
+		return codes.NotFound, false
+	case errors.Is(err, domain.ErrConflict):
+		return codes.AlreadyExists, false
+	case errors.Is(err, domain.ErrVersionConflict):
+		return codes.Aborted, false
+	case errors.Is(err, domain.ErrUnauthenticated):
+		return codes.Unauthenticated, false
+	case errors.Is(err, domain.ErrForbidden):
+		return codes.PermissionDenied, false
+	case errors.Is(err, domain.ErrTodoQuotaExceeded), errors.Is(err, domain.ErrDescriptionTooLarge):
+		return codes.ResourceExhausted, false
+	case errors.Is(err, context.DeadlineExceeded):
+		return codes.DeadlineExceeded, false
+	case errors.Is(err, context.Canceled):
+		return codes.Canceled, false
+	}
+	return codes.Internal, true
+}
+
+// toStatus maps the errors of the todo service onto gRPC statuses. Unexpected
+// failures are logged and answered with message alone, so that no internals leak to clients.
+func toStatus(log logger.Logger, message string, err error) error {
+	code, unexpected := statusCode(err)
+	if unexpected {
+		log.Error(message, err)
+		return status.Error(code, message)
+	}
+	return status.Error(code, err.Error())
+}
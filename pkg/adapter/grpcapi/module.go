@@ -0,0 +1,71 @@
+package grpcapi
+
+import (
+	"context"
+	"log/slog"
+	"net"
+
+	todov1 "github.com/taheri24/helitask/pkg/api/todov1"
+	"github.com/taheri24/helitask/pkg/config"
+	"github.com/taheri24/helitask/pkg/utils"
+	"go.uber.org/fx"
+	"google.golang.org/grpc"
+)
+
+// NewServer creates the gRPC server of the TodoService
+func NewServer(todos *TodoServer, authenticator *Authenticator) *grpc.Server {
+	srv := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(authenticator.Unary),
+		grpc.ChainStreamInterceptor(authenticator.Stream),
+	)
+	todov1.RegisterTodoServiceServer(srv, todos)
+	return srv
+}
+
+// StartServer serves the gRPC API on its own port, unless no port is configured. On stop the
+// running calls are given until the stop deadline to finish.
+func StartServer(lc fx.Lifecycle, srv *grpc.Server, todos *TodoServer, cfg *config.Config) {
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			port := cfg.Server.GRPCPort
+			if port == "" {
+				return nil
+			}
+			if utils.IsNumber(port) {
+				port = ":" + port
+			}
+			listener, err := net.Listen("tcp", port)
+			if err != nil {
+				slog.Error("Failed to start gRPC server", slog.Any("err", err))
+				return err
+			}
+			go func() {
+				if err := srv.Serve(listener); err != nil {
+					slog.Error("gRPC server stopped", slog.Any("err", err))
+				}
+			}()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			todos.Stop()
+			stopped := make(chan struct{})
+			go func() {
+				srv.GracefulStop()
+				close(stopped)
+			}()
+			select {
+			case <-stopped:
+				return nil
+			case <-ctx.Done():
+				srv.Stop()
+				return ctx.Err()
+			}
+		},
+	})
+}
+
+// Module provides the gRPC API, served next to the REST API on the port of GRPC_PORT
+var Module = fx.Module("apiGrpcV1",
+	fx.Provide(NewTodoServer, NewAuthenticator, NewServer),
+	fx.Invoke(StartServer),
+)
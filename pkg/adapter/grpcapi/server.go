@@ -0,0 +1,290 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	todov1 "github.com/taheri24/helitask/pkg/api/todov1"
+	"github.com/taheri24/helitask/pkg/domain"
+	"github.com/taheri24/helitask/pkg/events"
+	"github.com/taheri24/helitask/pkg/logger"
+	"github.com/taheri24/helitask/pkg/service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// eventReset is the type of the event telling a watcher it missed events that are no longer available
+const eventReset = "reset"
+
+// TodoServer implements the gRPC TodoService over the same todo service, policy and event
+// broker as the REST API
+type TodoServer struct {
+	todov1.UnimplementedTodoServiceServer
+	todos  *service.TodoService
+	policy domain.Policy
+	broker *events.Broker
+	logger logger.Logger
+	// stopping is closed when the server stops, ending the running watches
+	stopping chan struct{}
+	stopOnce sync.Once
+}
+
+// NewTodoServer creates the TodoService implementation
+func NewTodoServer(todos *service.TodoService, policy domain.Policy, broker *events.Broker, logger logger.Logger) *TodoServer {
+	return &TodoServer{todos: todos, policy: policy, broker: broker, logger: logger, stopping: make(chan struct{})}
+}
+
+// Stop ends the running watches, which would otherwise hold up a graceful stop of the server
+func (s *TodoServer) Stop() {
+	s.stopOnce.Do(func() { close(s.stopping) })
+}
+
+// Create stores a new item, with the id of the request when it has one
+func (s *TodoServer) Create(ctx context.Context, req *todov1.CreateRequest) (*todov1.Todo, error) {
+	var id *domain.UUID
+	if req.GetId() != "" {
+		parsed, err := parseID(req.GetId(), "id")
+		if err != nil {
+			return nil, err
+		}
+		id = &parsed
+	}
+	listID, err := parseListID(req.GetListId())
+	if err != nil {
+		return nil, err
+	}
+	in := service.TodoInput{ListID: listID, Description: req.GetDescription(), DueDate: asTime(req.GetDueDate()), Completed: req.GetCompleted()}
+	todo, err := s.todos.Create(ctx, id, in)
+	if err != nil {
+		return nil, toStatus(s.logger, "failed to save todo item", err)
+	}
+	return newTodo(todo), nil
+}
+
+// Get returns an item outside the trash
+func (s *TodoServer) Get(ctx context.Context, req *todov1.GetRequest) (*todov1.Todo, error) {
+	id, err := parseID(req.GetId(), "id")
+	if err != nil {
+		return nil, err
+	}
+	todo, err := s.todos.Get(ctx, id)
+	if err != nil {
+		return nil, toStatus(s.logger, "failed to fetch todo item", err)
+	}
+	return newTodo(todo), nil
+}
+
+// List returns the items the caller may read matching the filter of the request. Items the
+// caller may not read are left out of a page, so a page may be short of page_size.
+func (s *TodoServer) List(ctx context.Context, req *todov1.ListRequest) (*todov1.ListResponse, error) {
+	page := domain.Page{Limit: domain.DefaultPageSize}
+	if size := req.GetPageSize(); size < 0 || size > domain.MaxPageSize {
+		return nil, status.Errorf(codes.InvalidArgument, "page_size must be between 1 and %d", domain.MaxPageSize)
+	} else if size > 0 {
+		page.Limit = int(size)
+	}
+	if token := req.GetPageToken(); token != "" {
+		offset, err := strconv.Atoi(token)
+		if err != nil || offset < 0 {
+			return nil, status.Error(codes.InvalidArgument, "invalid page_token")
+		}
+		page.Offset = offset
+	}
+	listID, err := parseListID(req.GetListId())
+	if err != nil {
+		return nil, err
+	}
+	filter := domain.TodoFilter{ListID: listID, OwnerID: req.GetOwnerId(), Completed: req.Completed}
+
+	todos, err := s.todos.List(ctx, filter, page)
+	if err != nil {
+		return nil, toStatus(s.logger, "failed to list todo items", err)
+	}
+	resp := &todov1.ListResponse{Items: make([]*todov1.Todo, len(todos.Items))}
+	for i, todo := range todos.Items {
+		resp.Items[i] = newTodo(todo)
+	}
+	if todos.More {
+		resp.NextPageToken = strconv.Itoa(todos.Next)
+	}
+	return resp, nil
+}
+
+// Update replaces the fields of an item like a PUT of the REST API, failing with ABORTED when expected_version is set and
+// the item has another version
+func (s *TodoServer) Update(ctx context.Context, req *todov1.UpdateRequest) (*todov1.Todo, error) {
+	id, err := parseID(req.GetId(), "id")
+	if err != nil {
+		return nil, err
+	}
+	listID, err := parseListID(req.GetListId())
+	if err != nil {
+		return nil, err
+	}
+	if version := req.GetExpectedVersion(); version != 0 {
+		ctx = domain.WithExpectedVersion(ctx, version)
+	}
+	in := service.TodoInput{ListID: listID, Description: req.GetDescription(), DueDate: asTime(req.GetDueDate()), Completed: req.GetCompleted()}
+	todo, err := s.todos.Update(ctx, id, in)
+	if err != nil {
+		return nil, toStatus(s.logger, "failed to save todo item", err)
+	}
+	return newTodo(todo), nil
+}
+
+// Delete moves an item to the trash
+func (s *TodoServer) Delete(ctx context.Context, req *todov1.DeleteRequest) (*todov1.DeleteResponse, error) {
+	id, err := parseID(req.GetId(), "id")
+	if err != nil {
+		return nil, err
+	}
+	if err := s.todos.Delete(ctx, id); err != nil {
+		return nil, toStatus(s.logger, "failed to delete todo item", err)
+	}
+	return &todov1.DeleteResponse{}, nil
+}
+
+// Watch streams the events of the items of the workspace the caller may read, like the SSE
+// stream of the REST API does. A watcher that falls too far behind is ended with UNAVAILABLE
+// and resumes with the sequence of the last event it got.
+func (s *TodoServer) Watch(req *todov1.WatchRequest, stream todov1.TodoService_WatchServer) error {
+	ctx := stream.Context()
+	listID, err := parseListID(req.GetListId())
+	if err != nil {
+		return err
+	}
+	if listID != nil {
+		if err := s.policy.Authorize(ctx, domain.ActionReadList, domain.ListResource(*listID)); err != nil {
+			return toStatus(s.logger, "failed to authorize call", err)
+		}
+	}
+	owner := req.GetOwnerId()
+	workspaceID := domain.WorkspaceFromContext(ctx).ID
+	sub, replay, complete := s.broker.Subscribe(workspaceID, req.GetAfterSequence(), func(event *domain.Event) bool {
+		return event.WorkspaceID == workspaceID &&
+			(listID == nil || domain.SameList(listID, event.Todo.ListID)) &&
+			(owner == "" || event.Todo.OwnerID == owner)
+	})
+	defer sub.Close()
+
+	if !complete {
+		if err := stream.Send(&todov1.Event{Type: eventReset, At: timestamppb.Now()}); err != nil {
+			return err
+		}
+	}
+	for _, event := range replay {
+		if err := s.send(stream, event); err != nil {
+			return err
+		}
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case <-s.stopping:
+			return status.Error(codes.Unavailable, "server is stopping")
+		case event, ok := <-sub.Events():
+			if !ok {
+				return status.Error(codes.Unavailable, "watch fell behind the events, resume from the last sequence")
+			}
+			if err := s.send(stream, event); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// send streams event when the caller may read its item
+func (s *TodoServer) send(stream todov1.TodoService_WatchServer, event *domain.Event) error {
+	resource := domain.Resource{ListID: event.Todo.ListID, OwnerID: event.Todo.OwnerID}
+	if ok, err := s.readable(stream.Context(), resource); err != nil {
+		return toStatus(s.logger, "failed to authorize call", err)
+	} else if !ok {
+		return nil
+	}
+	return stream.Send(newEvent(event))
+}
+
+// readable reports whether the caller may read resource, failing only when the policy does
+func (s *TodoServer) readable(ctx context.Context, resource domain.Resource) (bool, error) {
+	err := s.policy.Authorize(ctx, domain.ActionReadTodo, resource)
+	if errors.Is(err, domain.ErrForbidden) || errors.Is(err, domain.ErrUnauthenticated) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func parseID(value, field string) (domain.UUID, error) {
+	id, err := domain.ParseUUID(value)
+	if err != nil || id == (domain.UUID{}) {
+		return domain.UUID{}, status.Errorf(codes.InvalidArgument, "%s must be a UUID", field)
+	}
+	return id, nil
+}
+
+// parseListID reads an optional list id, where empty means no list
+func parseListID(value string) (*domain.UUID, error) {
+	if value == "" {
+		return nil, nil
+	}
+	id, err := parseID(value, "list_id")
+	if err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
+
+// asTime converts an optional timestamp, where nil is the zero time
+func asTime(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	return ts.AsTime()
+}
+
+func newTodo(todo *domain.TodoItem) *todov1.Todo {
+	out := &todov1.Todo{
+		Id:          todo.ID.String(),
+		OwnerId:     todo.OwnerID,
+		Description: todo.Description,
+		DueDate:     timestamppb.New(todo.DueDate),
+		Completed:   todo.Completed,
+		Version:     todo.Version,
+	}
+	if todo.ListID != nil {
+		out.ListId = todo.ListID.String()
+	}
+	if todo.DeletedAt.Valid {
+		out.DeletedAt = timestamppb.New(todo.DeletedAt.Time)
+	}
+	return out
+}
+
+func newEvent(event *domain.Event) *todov1.Event {
+	snapshot := event.Todo
+	todo := &todov1.Todo{
+		Id:          snapshot.ID.String(),
+		OwnerId:     snapshot.OwnerID,
+		Description: snapshot.Description,
+		DueDate:     timestamppb.New(snapshot.DueDate),
+		Completed:   snapshot.Completed,
+		Version:     snapshot.Version,
+	}
+	if snapshot.ListID != nil {
+		todo.ListId = snapshot.ListID.String()
+	}
+	if snapshot.DeletedAt != nil {
+		todo.DeletedAt = timestamppb.New(*snapshot.DeletedAt)
+	}
+	return &todov1.Event{
+		Sequence: event.Sequence,
+		Type:     string(event.Type),
+		ActorId:  event.ActorID,
+		At:       timestamppb.New(event.At),
+		Todo:     todo,
+	}
+}
@@ -0,0 +1,194 @@
+package grpcapi
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	todov1 "github.com/taheri24/helitask/pkg/api/todov1"
+	"github.com/taheri24/helitask/pkg/auth"
+	"github.com/taheri24/helitask/pkg/config"
+	"github.com/taheri24/helitask/pkg/domain"
+	"github.com/taheri24/helitask/pkg/events"
+	"github.com/taheri24/helitask/pkg/logger"
+	"github.com/taheri24/helitask/pkg/policy"
+	"github.com/taheri24/helitask/pkg/ports/storage"
+	"github.com/taheri24/helitask/pkg/ports/storage/sqlite"
+	"github.com/taheri24/helitask/pkg/service"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxtest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// setupClient serves the gRPC API on an in-memory listener and returns a client of it
+func setupClient(t *testing.T, cfg *config.Config, options ...fx.Option) todov1.TodoServiceClient {
+	cfg.Server.GRPCPort = ""
+	var srv *grpc.Server
+	options = append([]fx.Option{fx.NopLogger, fx.Provide(logger.Nop), fx.Supply(sqlite.NewDb(t, ""), cfg),
+		storage.Module, storage.CacheModule, policy.Module, service.Module, events.Module, Module, fx.Populate(&srv)}, options...)
+	app := fxtest.New(t, options...)
+	app.RequireStart()
+	t.Cleanup(app.RequireStop)
+
+	listener := bufconn.Listen(1 << 20)
+	go func() { _ = srv.Serve(listener) }()
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return todov1.NewTodoServiceClient(conn)
+}
+
+func code(err error) codes.Code {
+	return status.Code(err)
+}
+
+// TestTodoService tests the calls of the TodoService and the codes of their failures
+func TestTodoService(t *testing.T) {
+	cfg := config.Default()
+	cfg.Auth.JWTSecret = "test-secret"
+	client := setupClient(t, cfg)
+	in := func(workspace, user string) context.Context {
+		token, err := auth.SignHS256(auth.Claims{"sub": user, "workspace": workspace}, []byte(cfg.Auth.JWTSecret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return metadata.AppendToOutgoingContext(t.Context(), "authorization", "Bearer "+token)
+	}
+	as := func(user string) context.Context { return in("default", user) }
+	alice := as("alice")
+	due := timestamppb.New(time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC))
+
+	created, err := client.Create(alice, &todov1.CreateRequest{Description: "First", DueDate: due})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), created.GetVersion())
+	assert.Equal(t, "alice", created.GetOwnerId())
+	_, err = client.Create(alice, &todov1.CreateRequest{Id: created.GetId(), Description: "Again", DueDate: due})
+	assert.Equal(t, codes.AlreadyExists, code(err))
+	_, err = client.Create(alice, &todov1.CreateRequest{Description: "", DueDate: due})
+	assert.Equal(t, codes.InvalidArgument, code(err))
+	_, err = client.Create(alice, &todov1.CreateRequest{Description: "No due date"})
+	assert.Equal(t, codes.InvalidArgument, code(err))
+
+	got, err := client.Get(alice, &todov1.GetRequest{Id: created.GetId()})
+	assert.NoError(t, err)
+	assert.Equal(t, "First", got.GetDescription())
+	assert.True(t, due.AsTime().Equal(got.GetDueDate().AsTime()))
+	_, err = client.Get(as("bob"), &todov1.GetRequest{Id: created.GetId()})
+	assert.Equal(t, codes.PermissionDenied, code(err), "bob may not read the items of alice")
+	_, err = client.Get(alice, &todov1.GetRequest{Id: domain.NewUUID().String()})
+	assert.Equal(t, codes.NotFound, code(err))
+	_, err = client.Get(alice, &todov1.GetRequest{Id: "nope"})
+	assert.Equal(t, codes.InvalidArgument, code(err))
+	bad := metadata.AppendToOutgoingContext(t.Context(), "authorization", "Bearer invalid")
+	_, err = client.Get(bad, &todov1.GetRequest{Id: created.GetId()})
+	assert.Equal(t, codes.Unauthenticated, code(err))
+
+	updated, err := client.Update(alice, &todov1.UpdateRequest{Id: created.GetId(), Description: "First, done", DueDate: due, Completed: true, ExpectedVersion: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), updated.GetVersion())
+	_, err = client.Update(alice, &todov1.UpdateRequest{Id: created.GetId(), Description: "Stale", DueDate: due, ExpectedVersion: 1})
+	assert.Equal(t, codes.Aborted, code(err), "the item was changed since version 1")
+
+	for _, description := range []string{"Second", "Third"} {
+		_, err := client.Create(alice, &todov1.CreateRequest{Description: description, DueDate: due})
+		assert.NoError(t, err)
+	}
+	_, err = client.Create(as("bob"), &todov1.CreateRequest{Description: "Private to bob", DueDate: due})
+	assert.NoError(t, err)
+	page, err := client.List(alice, &todov1.ListRequest{OwnerId: "alice", PageSize: 2})
+	assert.NoError(t, err)
+	assert.Len(t, page.GetItems(), 2)
+	assert.NotEmpty(t, page.GetNextPageToken())
+	page, err = client.List(alice, &todov1.ListRequest{OwnerId: "alice", PageSize: 2, PageToken: page.GetNextPageToken()})
+	assert.NoError(t, err)
+	assert.Len(t, page.GetItems(), 1)
+	assert.Empty(t, page.GetNextPageToken())
+	completed := true
+	page, err = client.List(alice, &todov1.ListRequest{Completed: &completed})
+	assert.NoError(t, err)
+	if assert.Len(t, page.GetItems(), 1) {
+		assert.Equal(t, "First, done", page.GetItems()[0].GetDescription())
+	}
+	page, err = client.List(alice, &todov1.ListRequest{})
+	assert.NoError(t, err)
+	assert.Len(t, page.GetItems(), 3, "the items of bob are left out")
+	_, err = client.List(alice, &todov1.ListRequest{PageSize: 1000})
+	assert.Equal(t, codes.InvalidArgument, code(err))
+
+	_, err = client.Delete(as("bob"), &todov1.DeleteRequest{Id: created.GetId()})
+	assert.Equal(t, codes.PermissionDenied, code(err))
+	_, err = client.Delete(alice, &todov1.DeleteRequest{Id: created.GetId()})
+	assert.NoError(t, err)
+	_, err = client.Get(alice, &todov1.GetRequest{Id: created.GetId()})
+	assert.Equal(t, codes.NotFound, code(err), "deleted items are in the trash")
+
+	page, err = client.List(in("other", "alice"), &todov1.ListRequest{})
+	assert.NoError(t, err)
+	assert.Empty(t, page.GetItems(), "workspaces are apart")
+	_, err = client.List(metadata.AppendToOutgoingContext(alice, "x-workspace-id", "other"), &todov1.ListRequest{})
+	assert.Equal(t, codes.PermissionDenied, code(err), "the metadata must agree with the token")
+	_, err = client.List(in("-bad", "alice"), &todov1.ListRequest{})
+	assert.Equal(t, codes.InvalidArgument, code(err))
+	_, err = client.List(t.Context(), &todov1.ListRequest{})
+	assert.Equal(t, codes.Unauthenticated, code(err), "calls must carry the workspace claim")
+}
+
+// TestTodoServiceWatch tests streaming the changes of the items the caller may read
+func TestTodoServiceWatch(t *testing.T) {
+	cfg := config.Default()
+	cfg.Auth.JWTSecret = "test-secret"
+	var (
+		relay *events.Relay
+		todos *TodoServer
+	)
+	client := setupClient(t, cfg, fx.Populate(&relay, &todos))
+	as := func(user string) context.Context {
+		token, err := auth.SignHS256(auth.Claims{"sub": user, "workspace": "default"}, []byte(cfg.Auth.JWTSecret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return metadata.AppendToOutgoingContext(t.Context(), "authorization", "Bearer "+token)
+	}
+	due := timestamppb.New(time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC))
+
+	watch, err := client.Watch(as("alice"), &todov1.WatchRequest{})
+	assert.NoError(t, err)
+	created, err := client.Create(as("alice"), &todov1.CreateRequest{Description: "Watched", DueDate: due})
+	assert.NoError(t, err)
+	_, err = client.Create(as("bob"), &todov1.CreateRequest{Description: "Private to bob", DueDate: due})
+	assert.NoError(t, err)
+	_, err = client.Update(as("alice"), &todov1.UpdateRequest{Id: created.GetId(), Description: "Watched", DueDate: due, Completed: true})
+	assert.NoError(t, err)
+	_, err = relay.RelayOnce(t.Context())
+	assert.NoError(t, err)
+
+	first, err := watch.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, string(domain.TodoCreated), first.GetType())
+	assert.Equal(t, created.GetId(), first.GetTodo().GetId())
+	second, err := watch.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, string(domain.TodoCompleted), second.GetType(), "the item of bob is left out")
+
+	resumed, err := client.Watch(as("alice"), &todov1.WatchRequest{AfterSequence: first.GetSequence()})
+	assert.NoError(t, err)
+	replayed, err := resumed.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, second.GetSequence(), replayed.GetSequence(), "a resumed watch is sent the events it missed")
+
+	todos.Stop()
+	_, err = watch.Recv()
+	assert.Equal(t, codes.Unavailable, code(err), "stopping the server ends the watches")
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/taheri24/helitask/pkg/domain"
+	"github.com/taheri24/helitask/pkg/service"
+)
+
+const (
+	// MaxBatchOperations is the maximum number of operations of a single batch request
+	MaxBatchOperations = 500
+
+	batchModeAtomic     = "atomic"
+	batchModeBestEffort = "best_effort"
+)
+
+type batchOperation struct {
+	Op   string       `json:"op"`
+	ID   *domain.UUID `json:"id"`
+	Item *todoInput   `json:"item"`
+}
+
+type batchResult struct {
+	Index  int    `json:"index"`
+	Op     string `json:"op"`
+	ID     string `json:"id,omitempty"`
+	Status int    `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+func (r *batchResult) fail(status int, message string) {
+	r.Status, r.Error = status, message
+}
+
+// BatchTodoItems handles creating, updating and deleting several todo items in one request.
+// In atomic mode all operations are applied in one transaction, or none is;
+// in best effort mode every operation succeeds or fails on its own.
+func (h *TodoHandler) BatchTodoItems(c *gin.Context) {
+	ctx := c.Request.Context()
+	var input struct {
+		Mode       string           `json:"mode"`
+		Operations []batchOperation `json:"operations"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		helper.ResponseError(c, http.StatusBadRequest, "Invalid input", err)
+		return
+	}
+	if input.Mode == "" {
+		input.Mode = batchModeAtomic
+	}
+	if input.Mode != batchModeAtomic && input.Mode != batchModeBestEffort {
+		helper.ResponseError(c, http.StatusBadRequest, "mode must be atomic or best_effort", nil)
+		return
+	}
+	if len(input.Operations) == 0 {
+		helper.ResponseError(c, http.StatusBadRequest, "operations are required", nil)
+		return
+	}
+	if len(input.Operations) > MaxBatchOperations {
+		helper.ResponseError(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("a batch holds at most %d operations", MaxBatchOperations), nil)
+		return
+	}
+
+	ops := make([]service.BatchOp, len(input.Operations))
+	for i, op := range input.Operations {
+		ops[i] = service.BatchOp{Op: op.Op, ID: op.ID}
+		if op.Item != nil {
+			fields := op.Item.fields()
+			ops[i].Item = &fields
+			if op.Op == service.BatchCreate {
+				ops[i].ID = op.Item.ID
+			}
+		}
+	}
+	outcomes, err := h.service.Batch(ctx, ops, input.Mode == batchModeAtomic)
+	if err != nil && !errors.Is(err, service.ErrBatchAborted) {
+		helper.ResponseError(c, http.StatusInternalServerError, "Failed to apply batch", err)
+		return
+	}
+
+	results := make([]batchResult, len(ops))
+	status := http.StatusOK
+	for i, outcome := range outcomes {
+		results[i] = newBatchResult(i, ops[i].Op, outcome)
+		switch {
+		case errors.Is(err, service.ErrBatchAborted) && outcome.Err == nil:
+			results[i].fail(http.StatusFailedDependency, "rolled back, another operation failed")
+		case outcome.Err != nil && status == http.StatusOK:
+			status = http.StatusMultiStatus
+		}
+	}
+	if errors.Is(err, service.ErrBatchAborted) {
+		status = http.StatusUnprocessableEntity
+	}
+	helper.SendSuccessResponse(c, status, gin.H{"mode": input.Mode, "results": results})
+}
+
+// newBatchResult is the result of the operation at index with the given outcome
+func newBatchResult(index int, op string, outcome service.BatchResult) batchResult {
+	res := batchResult{Index: index, Op: op}
+	if outcome.ID != nil {
+		res.ID = outcome.ID.String()
+	}
+	switch {
+	case outcome.Err == nil && op == service.BatchCreate:
+		res.Status = http.StatusCreated
+	case outcome.Err == nil && op == service.BatchDelete:
+		res.Status = http.StatusNoContent
+	case outcome.Err == nil:
+		res.Status = http.StatusOK
+	default:
+		res.fail(operationErrorStatus(outcome.Err))
+	}
+	return res
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestBatchTodoItems tests the atomic and best effort modes of BatchTodoItems
+func TestBatchTodoItems(t *testing.T) {
+	app, fxApp := setupApp(t, "sample1.sql")
+	fxApp.RequireStart()
+	defer fxApp.RequireStop()
+
+	type result struct {
+		Index  int    `json:"index"`
+		ID     string `json:"id"`
+		Status int    `json:"status"`
+		Error  string `json:"error"`
+	}
+	batch := func(input string) (int, []result) {
+		req, w := setupHTTP("POST", "/api/v0/todo/batch", input)
+		app.ServeHTTP(w, req)
+		var output struct {
+			Results []result `json:"results"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &output); err != nil {
+			t.Log(w.Body.String())
+		}
+		return w.Code, output.Results
+	}
+	statuses := func(results []result) []int {
+		var out []int
+		for _, r := range results {
+			out = append(out, r.Status)
+		}
+		return out
+	}
+	exists := func(id string) int {
+		req, w := setupHTTP("GET", "/api/v0/todo/"+id, "")
+		app.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	t.Run("Atomic success", func(t *testing.T) {
+		status, results := batch(`{"mode": "atomic", "operations": [
+			{"op": "create", "item": {"id": "0b9f7c62-1d2e-4f3a-8b4c-5d6e7f8a9b0c", "description": "Sprint task 1", "due_date": "2025-04-01T09:00:00Z"}},
+			{"op": "create", "item": {"description": "Sprint task 2", "due_date": "2025-04-02T09:00:00Z"}},
+			{"op": "update", "id": "0b9f7c62-1d2e-4f3a-8b4c-5d6e7f8a9b0c", "item": {"description": "Sprint task 1b", "due_date": "2025-04-01T09:00:00Z"}},
+			{"op": "delete", "id": "3f6c1a4e-9966-4f1c-a2a9-1b8df67f8cc3"}
+		]}`)
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, []int{http.StatusCreated, http.StatusCreated, http.StatusOK, http.StatusNoContent}, statuses(results))
+		assert.Equal(t, http.StatusOK, exists(results[1].ID))
+		assert.Equal(t, http.StatusNotFound, exists("3f6c1a4e-9966-4f1c-a2a9-1b8df67f8cc3"))
+	})
+
+	t.Run("Atomic rollback", func(t *testing.T) {
+		status, results := batch(`{"operations": [
+			{"op": "create", "item": {"description": "Rolled back", "due_date": "2025-04-01T09:00:00Z"}},
+			{"op": "delete", "id": "c2e89319-e563-4a0b-9ef0-349beb3ef672"},
+			{"op": "update", "id": "00000000-0000-0000-0000-000000000000", "item": {"description": "Missing", "due_date": "2025-04-01T09:00:00Z"}},
+			{"op": "create", "item": {"description": "Not attempted", "due_date": "2025-04-01T09:00:00Z"}}
+		]}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, status)
+		assert.Equal(t, []int{http.StatusFailedDependency, http.StatusFailedDependency, http.StatusNotFound, http.StatusFailedDependency}, statuses(results))
+		assert.Equal(t, http.StatusNotFound, exists(results[0].ID))
+		assert.Equal(t, http.StatusOK, exists("c2e89319-e563-4a0b-9ef0-349beb3ef672"))
+	})
+
+	t.Run("Best effort", func(t *testing.T) {
+		status, results := batch(`{"mode": "best_effort", "operations": [
+			{"op": "create", "item": {"description": "Kept", "due_date": "2025-04-01T09:00:00Z"}},
+			{"op": "create", "item": {"id": "0b9f7c62-1d2e-4f3a-8b4c-5d6e7f8a9b0c", "description": "Duplicate", "due_date": "2025-04-01T09:00:00Z"}},
+			{"op": "create", "item": {"description": "", "due_date": "2025-04-01T09:00:00Z"}},
+			{"op": "delete", "id": "c2e89319-e563-4a0b-9ef0-349beb3ef672"},
+			{"op": "archive", "id": "c2e89319-e563-4a0b-9ef0-349beb3ef672"},
+			{"op": "create", "item": {"id": "00000000-0000-0000-0000-000000000000", "description": "Nil id", "due_date": "2025-04-01T09:00:00Z"}}
+		]}`)
+		assert.Equal(t, http.StatusMultiStatus, status)
+		assert.Equal(t, []int{http.StatusCreated, http.StatusConflict, http.StatusBadRequest, http.StatusNoContent, http.StatusBadRequest, http.StatusBadRequest}, statuses(results))
+		assert.Equal(t, http.StatusOK, exists(results[0].ID))
+		assert.Equal(t, http.StatusNotFound, exists("c2e89319-e563-4a0b-9ef0-349beb3ef672"))
+	})
+
+	t.Run("Invalid requests", func(t *testing.T) {
+		status, _ := batch(`{"mode": "eventually", "operations": [{"op": "delete", "id": "c2e89319-e563-4a0b-9ef0-349beb3ef672"}]}`)
+		assert.Equal(t, http.StatusBadRequest, status)
+		status, _ = batch(`{"operations": []}`)
+		assert.Equal(t, http.StatusBadRequest, status)
+	})
+}
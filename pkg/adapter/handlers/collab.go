@@ -0,0 +1,486 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/taheri24/helitask/pkg/domain"
+	"github.com/taheri24/helitask/pkg/events"
+	"github.com/taheri24/helitask/pkg/logger"
+	"github.com/taheri24/helitask/pkg/service"
+	"go.uber.org/fx"
+)
+
+// Types of the messages of the collaboration protocol
+const (
+	collabSubscribe   = "subscribe"
+	collabUnsubscribe = "unsubscribe"
+	collabEdit        = "edit"
+	collabAck         = "ack"
+	collabError       = "error"
+	collabPresence    = "presence"
+	collabEvent       = "event"
+)
+
+const (
+	// collabReadLimit is the size of the largest message accepted from a client
+	collabReadLimit = 16 << 10
+	// collabWriteWait is how long writing a message to a client, or closing its connection, may take
+	collabWriteWait = 10 * time.Second
+)
+
+// collabInput is a message sent by a client. The id, when set, is echoed by the ack or error answering it.
+type collabInput struct {
+	Type    string       `json:"type"`
+	ID      string       `json:"id,omitempty"`
+	ListID  *domain.UUID `json:"list_id,omitempty"`
+	TodoID  *domain.UUID `json:"todo_id,omitempty"`
+	Version int64        `json:"version,omitempty"`
+	Changes *todoChanges `json:"changes,omitempty"`
+}
+
+// todoChanges are the fields of a todo item set by an edit; the fields left out keep their value
+type todoChanges struct {
+	Description *string    `json:"description"`
+	DueDate     *time.Time `json:"due_date"`
+	Completed   *bool      `json:"completed"`
+}
+
+// applyTo copies the changed fields onto in
+func (ch *todoChanges) applyTo(in *service.TodoInput) {
+	if ch.Description != nil {
+		in.Description = *ch.Description
+	}
+	if ch.DueDate != nil {
+		in.DueDate = *ch.DueDate
+	}
+	if ch.Completed != nil {
+		in.Completed = *ch.Completed
+	}
+}
+
+// collabOutput is a message sent to a client
+type collabOutput struct {
+	Type    string        `json:"type"`
+	ID      string        `json:"id,omitempty"`
+	Status  int           `json:"status,omitempty"`
+	Message string        `json:"message,omitempty"`
+	ListID  *domain.UUID  `json:"list_id,omitempty"`
+	Users   []string      `json:"users,omitempty"`
+	Todo    *todoOutput   `json:"todo,omitempty"`
+	Event   *domain.Event `json:"event,omitempty"`
+}
+
+// collabFailure is the error message answering the client message with the given id
+func collabFailure(id string, status int, message string) collabOutput {
+	return collabOutput{Type: collabError, ID: id, Status: status, Message: message}
+}
+
+// collabRoom gathers the connections viewing a list
+type collabRoom struct {
+	workspaceID string
+	listID      domain.UUID
+}
+
+// CollabHub tracks the open collaboration connections and the lists each of them is viewing
+type CollabHub struct {
+	mu     sync.Mutex
+	closed bool
+	conns  map[*collabConn]struct{}
+	rooms  map[collabRoom]map[*collabConn]struct{}
+	active sync.WaitGroup
+}
+
+// NewCollabHub creates a CollabHub whose connections are closed when the application stops
+func NewCollabHub(lc fx.Lifecycle) *CollabHub {
+	h := &CollabHub{conns: map[*collabConn]struct{}{}, rooms: map[collabRoom]map[*collabConn]struct{}{}}
+	lc.Append(fx.Hook{OnStop: h.Close})
+	return h
+}
+
+// register adds conn to the hub, and reports false once the hub is closed
+func (h *CollabHub) register(conn *collabConn) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return false
+	}
+	h.conns[conn] = struct{}{}
+	h.active.Add(1)
+	return true
+}
+
+// unregister removes conn and the presence of its user from the lists it was viewing
+func (h *CollabHub) unregister(conn *collabConn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for room, conns := range h.rooms {
+		if _, ok := conns[conn]; ok {
+			h.leaveLocked(conn, room)
+		}
+	}
+	delete(h.conns, conn)
+	h.active.Done()
+}
+
+// join adds conn to the viewers of room and tells them who is viewing it
+func (h *CollabHub) join(conn *collabConn, room collabRoom) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.rooms[room] == nil {
+		h.rooms[room] = map[*collabConn]struct{}{}
+	}
+	h.rooms[room][conn] = struct{}{}
+	h.presenceLocked(room)
+}
+
+// leave removes conn from the viewers of room
+func (h *CollabHub) leave(conn *collabConn, room collabRoom) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.leaveLocked(conn, room)
+}
+
+// leaveLocked is leave for a locked hub
+func (h *CollabHub) leaveLocked(conn *collabConn, room collabRoom) {
+	if _, ok := h.rooms[room][conn]; !ok {
+		return
+	}
+	delete(h.rooms[room], conn)
+	if len(h.rooms[room]) == 0 {
+		delete(h.rooms, room)
+		return
+	}
+	h.presenceLocked(room)
+}
+
+// presenceLocked sends the users viewing room to its viewers; the hub must be locked
+func (h *CollabHub) presenceLocked(room collabRoom) {
+	users := []string{}
+	for conn := range h.rooms[room] {
+		if conn.user != "" && !slices.Contains(users, conn.user) {
+			users = append(users, conn.user)
+		}
+	}
+	slices.Sort(users)
+	for conn := range h.rooms[room] {
+		conn.enqueue(collabOutput{Type: collabPresence, ListID: &room.listID, Users: users})
+	}
+}
+
+// Close refuses new connections and closes the open ones with a going away status,
+// waiting until ctx is done for their clients to acknowledge it
+func (h *CollabHub) Close(ctx context.Context) error {
+	h.mu.Lock()
+	h.closed = true
+	for conn := range h.conns {
+		conn.close(websocket.CloseGoingAway, "server is shutting down")
+	}
+	h.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		h.active.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// collabConn is the WebSocket connection of a client. Messages to the client are queued and
+// written by its write loop, so that a slow client never blocks the hub or the broker.
+type collabConn struct {
+	ws          *websocket.Conn
+	user        string
+	workspaceID string
+	send        chan collabOutput
+
+	mu    sync.Mutex
+	lists map[domain.UUID]struct{}
+
+	closeOnce   sync.Once
+	closing     chan struct{}
+	closeCode   int
+	closeReason string
+	readDone    chan struct{}
+}
+
+// enqueue queues msg for the client without waiting. A client whose queue is full does not
+// keep up with its messages and is disconnected.
+func (c *collabConn) enqueue(msg collabOutput) {
+	select {
+	case <-c.closing:
+	case c.send <- msg:
+	default:
+		c.close(websocket.CloseTryAgainLater, "too slow to keep up")
+	}
+}
+
+// close asks the write loop to close the connection with the given status
+func (c *collabConn) close(code int, reason string) {
+	c.closeOnce.Do(func() {
+		c.closeCode, c.closeReason = code, reason
+		close(c.closing)
+	})
+}
+
+// viewing reports whether the client subscribed to the list
+func (c *collabConn) viewing(listID *domain.UUID) bool {
+	if listID == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.lists[*listID]
+	return ok
+}
+
+// writeLoop writes the queued messages and the pings keeping the connection alive until it
+// is closed. Closing sends a close message and waits for the client to answer it.
+func (c *collabConn) writeLoop(heartbeat time.Duration) {
+	defer c.ws.Close()
+	var ping <-chan time.Time
+	if heartbeat > 0 {
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+		ping = ticker.C
+	}
+	for {
+		select {
+		case msg := <-c.send:
+			if err := c.ws.SetWriteDeadline(time.Now().Add(collabWriteWait)); err != nil {
+				c.close(websocket.CloseAbnormalClosure, "")
+				return
+			}
+			if err := c.ws.WriteJSON(msg); err != nil {
+				c.close(websocket.CloseAbnormalClosure, "")
+				return
+			}
+		case <-ping:
+			if err := c.ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(collabWriteWait)); err != nil {
+				c.close(websocket.CloseAbnormalClosure, "")
+				return
+			}
+		case <-c.closing:
+			message := websocket.FormatCloseMessage(c.closeCode, c.closeReason)
+			if err := c.ws.WriteControl(websocket.CloseMessage, message, time.Now().Add(collabWriteWait)); err != nil {
+				return
+			}
+			select {
+			case <-c.readDone:
+			case <-time.After(collabWriteWait):
+			}
+			return
+		}
+	}
+}
+
+// CollabHandler serves the WebSocket connections of clients editing todo items together
+type CollabHandler struct {
+	service   *service.TodoService
+	policy    domain.Policy
+	broker    *events.Broker
+	hub       *CollabHub
+	logger    logger.Logger
+	buffer    int
+	heartbeat time.Duration
+	upgrader  websocket.Upgrader
+}
+
+// Connect upgrades the request to a WebSocket connection speaking the collaboration protocol.
+// Clients subscribe to the lists they view, are told who else is viewing them, get the changes
+// of their todo items, and edit items at the version they last saw. Every message is
+// authorized for the principal of the connection.
+func (h *CollabHandler) Connect(c *gin.Context) {
+	ws, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// the upgrader answered the request
+		return
+	}
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+	conn := &collabConn{
+		ws:          ws,
+		user:        domain.PrincipalFromContext(ctx).UserID,
+		workspaceID: domain.WorkspaceFromContext(ctx).ID,
+		send:        make(chan collabOutput, max(h.buffer, 1)),
+		lists:       map[domain.UUID]struct{}{},
+		closing:     make(chan struct{}),
+		readDone:    make(chan struct{}),
+	}
+	if !h.hub.register(conn) {
+		conn.close(websocket.CloseGoingAway, "server is shutting down")
+		close(conn.readDone)
+		conn.writeLoop(0)
+		return
+	}
+	defer h.hub.unregister(conn)
+
+	sub, _, _ := h.broker.Subscribe(conn.workspaceID, 0, func(event *domain.Event) bool {
+		return event.WorkspaceID == conn.workspaceID && conn.viewing(event.Todo.ListID)
+	})
+	defer sub.Close()
+	go h.forward(ctx, conn, sub)
+	go conn.writeLoop(h.heartbeat)
+
+	h.readLoop(ctx, conn)
+}
+
+// readLoop handles the messages of the client until the connection is closed
+func (h *CollabHandler) readLoop(ctx context.Context, conn *collabConn) {
+	defer close(conn.readDone)
+	defer conn.close(websocket.CloseNormalClosure, "")
+	ws := conn.ws
+	ws.SetReadLimit(collabReadLimit)
+	extend := func(string) error {
+		if h.heartbeat <= 0 {
+			return nil
+		}
+		// a client that answers no ping for two heartbeats is gone
+		return ws.SetReadDeadline(time.Now().Add(2 * h.heartbeat))
+	}
+	ws.SetPongHandler(extend)
+	for {
+		if err := extend(""); err != nil {
+			return
+		}
+		_, data, err := ws.ReadMessage()
+		if err != nil {
+			if errors.Is(err, websocket.ErrReadLimit) {
+				conn.close(websocket.CloseMessageTooBig, "message too big")
+			}
+			return
+		}
+		var msg collabInput
+		if err := json.Unmarshal(data, &msg); err != nil {
+			conn.enqueue(collabFailure("", http.StatusBadRequest, "Invalid message"))
+			continue
+		}
+		switch msg.Type {
+		case collabSubscribe:
+			h.subscribe(ctx, conn, &msg)
+		case collabUnsubscribe:
+			h.unsubscribe(conn, &msg)
+		case collabEdit:
+			h.edit(ctx, conn, &msg)
+		default:
+			conn.enqueue(collabFailure(msg.ID, http.StatusBadRequest, "Unknown message type"))
+		}
+	}
+}
+
+// forward sends the events of the lists the client views, when it may read their items.
+// A client too slow for the broker is disconnected.
+func (h *CollabHandler) forward(ctx context.Context, conn *collabConn, sub *events.Subscription) {
+	for {
+		select {
+		case <-conn.closing:
+			return
+		case event, ok := <-sub.Events():
+			if !ok {
+				conn.close(websocket.CloseTryAgainLater, "too slow to keep up")
+				return
+			}
+			resource := domain.Resource{ListID: event.Todo.ListID, OwnerID: event.Todo.OwnerID}
+			if err := h.policy.Authorize(ctx, domain.ActionReadTodo, resource); err != nil {
+				continue
+			}
+			conn.enqueue(collabOutput{Type: collabEvent, Event: event})
+		}
+	}
+}
+
+// authorize asks the policy whether the client may perform action on resource, and answers
+// the message with an error when it may not
+func (h *CollabHandler) authorize(ctx context.Context, conn *collabConn, id string, action domain.Action, resource domain.Resource) bool {
+	err := h.policy.Authorize(ctx, action, resource)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, domain.ErrUnauthenticated):
+		conn.enqueue(collabFailure(id, http.StatusUnauthorized, "Authentication required"))
+	case errors.Is(err, domain.ErrForbidden):
+		conn.enqueue(collabFailure(id, http.StatusForbidden, "Forbidden"))
+	default:
+		h.logger.Error("Failed to authorize collaboration message", err)
+		conn.enqueue(collabFailure(id, http.StatusInternalServerError, "Failed to authorize request"))
+	}
+	return false
+}
+
+// subscribe adds the client to the viewers of a list it may read
+func (h *CollabHandler) subscribe(ctx context.Context, conn *collabConn, msg *collabInput) {
+	if msg.ListID == nil {
+		conn.enqueue(collabFailure(msg.ID, http.StatusBadRequest, "list_id is required"))
+		return
+	}
+	if !h.authorize(ctx, conn, msg.ID, domain.ActionReadList, domain.ListResource(*msg.ListID)) {
+		return
+	}
+	conn.mu.Lock()
+	conn.lists[*msg.ListID] = struct{}{}
+	conn.mu.Unlock()
+	conn.enqueue(collabOutput{Type: collabAck, ID: msg.ID, ListID: msg.ListID})
+	h.hub.join(conn, collabRoom{conn.workspaceID, *msg.ListID})
+}
+
+// unsubscribe removes the client from the viewers of a list
+func (h *CollabHandler) unsubscribe(conn *collabConn, msg *collabInput) {
+	if msg.ListID == nil {
+		conn.enqueue(collabFailure(msg.ID, http.StatusBadRequest, "list_id is required"))
+		return
+	}
+	conn.mu.Lock()
+	delete(conn.lists, *msg.ListID)
+	conn.mu.Unlock()
+	h.hub.leave(conn, collabRoom{conn.workspaceID, *msg.ListID})
+	conn.enqueue(collabOutput{Type: collabAck, ID: msg.ID, ListID: msg.ListID})
+}
+
+// edit changes a todo item when it is still at the version the client edited. An edit
+// based on an older version is refused with the current item, for the client to merge.
+func (h *CollabHandler) edit(ctx context.Context, conn *collabConn, msg *collabInput) {
+	if msg.TodoID == nil || msg.Changes == nil || msg.Version < 1 {
+		conn.enqueue(collabFailure(msg.ID, http.StatusBadRequest, "todo_id, version and changes are required"))
+		return
+	}
+	todo, err := h.service.Patch(domain.WithExpectedVersion(ctx, msg.Version), *msg.TodoID, func(in *service.TodoInput) error {
+		msg.Changes.applyTo(in)
+		return nil
+	})
+	switch {
+	case errors.Is(err, domain.ErrVersionConflict):
+		failure := collabFailure(msg.ID, http.StatusConflict, "todo item was changed since this version")
+		if current, err := h.service.Get(domain.WithPrimary(ctx), *msg.TodoID); err == nil {
+			out := newTodoOutput(current)
+			failure.Todo = &out
+		}
+		conn.enqueue(failure)
+	case errors.Is(err, service.ErrInvalidInput):
+		conn.enqueue(collabFailure(msg.ID, http.StatusBadRequest, err.Error()))
+	case errors.Is(err, domain.ErrUnauthenticated):
+		conn.enqueue(collabFailure(msg.ID, http.StatusUnauthorized, "Authentication required"))
+	case errors.Is(err, domain.ErrForbidden):
+		conn.enqueue(collabFailure(msg.ID, http.StatusForbidden, "Forbidden"))
+	case err != nil:
+		status, message := saveErrorStatus(err)
+		if status == http.StatusInternalServerError {
+			h.logger.Error(message, err)
+		}
+		conn.enqueue(collabFailure(msg.ID, status, message))
+	default:
+		out := newTodoOutput(todo)
+		conn.enqueue(collabOutput{Type: collabAck, ID: msg.ID, Todo: &out})
+	}
+}
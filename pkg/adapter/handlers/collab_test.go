@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/taheri24/helitask/pkg/auth"
+	"github.com/taheri24/helitask/pkg/config"
+	"github.com/taheri24/helitask/pkg/domain"
+	"github.com/taheri24/helitask/pkg/events"
+	"go.uber.org/fx"
+)
+
+// TestCollab tests presence, live edits and events over the collaboration WebSocket
+func TestCollab(t *testing.T) {
+	cfg := config.Default()
+	cfg.Auth.JWTSecret = "test-secret"
+	var (
+		relay *events.Relay
+		hub   *CollabHub
+	)
+	app, fxApp := setupAppWithConfig(t, "", cfg, fx.Populate(&relay, &hub))
+	fxApp.RequireStart()
+	defer fxApp.RequireStop()
+	server := httptest.NewServer(app)
+	defer server.Close()
+	token := func(user string) string {
+		token, err := auth.SignHS256(auth.Claims{"sub": user, "workspace": "default"}, []byte(cfg.Auth.JWTSecret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	call := func(user, method, path, body string) []byte {
+		req, w := setupHTTP(method, path, body)
+		req.Header.Set("Authorization", "Bearer "+token(user))
+		app.ServeHTTP(w, req)
+		assert.Less(t, w.Code, 300, w.Body.String())
+		return w.Body.Bytes()
+	}
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/v0/todo/ws?access_token="
+	dial := func(user string) *websocket.Conn {
+		ws, _, err := websocket.DefaultDialer.Dial(wsURL+token(user), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ws
+	}
+	read := func(ws *websocket.Conn) collabOutput {
+		var msg collabOutput
+		assert.NoError(t, ws.SetReadDeadline(time.Now().Add(5*time.Second)))
+		if err := ws.ReadJSON(&msg); err != nil {
+			t.Fatal(err)
+		}
+		return msg
+	}
+	// readPresence returns the next presence message, skipping the events sent before it
+	readPresence := func(ws *websocket.Conn) []string {
+		for {
+			if msg := read(ws); msg.Type == collabPresence {
+				return msg.Users
+			}
+		}
+	}
+
+	listID := extractJsonVal(call("alice", "POST", "/api/v0/lists/", `{"name": "Sprint 1"}`), "id")
+	call("alice", "POST", "/api/v0/lists/"+listID+"/members", `{"user_id": "bob", "role": "editor"}`)
+	todoID := extractJsonVal(call("alice", "POST", "/api/v0/todo/", `{"description": "Shared", "due_date": "2025-12-31T23:59:59Z", "list_id": "`+listID+`"}`), "id")
+
+	_, resp, err := websocket.DefaultDialer.Dial(wsURL+"invalid", nil)
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	alice, bob, carol := dial("alice"), dial("bob"), dial("carol")
+	defer alice.Close()
+	defer bob.Close()
+	defer carol.Close()
+	subscribe := `{"type": "subscribe", "id": "s1", "list_id": "` + listID + `"}`
+	assert.NoError(t, alice.WriteMessage(websocket.TextMessage, []byte(subscribe)))
+	msg := read(alice)
+	assert.Equal(t, collabAck, msg.Type)
+	assert.Equal(t, "s1", msg.ID)
+	assert.Equal(t, listID, msg.ListID.String())
+	assert.Equal(t, []string{"alice"}, read(alice).Users)
+	assert.NoError(t, bob.WriteMessage(websocket.TextMessage, []byte(subscribe)))
+	assert.Equal(t, collabAck, read(bob).Type)
+	assert.Equal(t, []string{"alice", "bob"}, read(bob).Users)
+	assert.Equal(t, []string{"alice", "bob"}, read(alice).Users, "viewers are told who joins")
+
+	assert.NoError(t, carol.WriteMessage(websocket.TextMessage, []byte(subscribe)))
+	msg = read(carol)
+	assert.Equal(t, collabError, msg.Type)
+	assert.Equal(t, http.StatusForbidden, msg.Status, "carol is no member of the list")
+	assert.NoError(t, carol.WriteMessage(websocket.TextMessage, []byte(`{"type": "dance"}`)))
+	assert.Equal(t, http.StatusBadRequest, read(carol).Status)
+
+	edit := func(ws *websocket.Conn, id string, version int, description string) collabOutput {
+		body := `{"type": "edit", "id": "` + id + `", "todo_id": "` + todoID + `", "version": ` + strconv.Itoa(version) +
+			`, "changes": {"description": "` + description + `"}}`
+		assert.NoError(t, ws.WriteMessage(websocket.TextMessage, []byte(body)))
+		return read(ws)
+	}
+	msg = edit(bob, "e1", 1, "Edited by bob")
+	assert.Equal(t, collabAck, msg.Type)
+	assert.Equal(t, int64(2), msg.Todo.Version)
+	assert.Equal(t, "Edited by bob", msg.Todo.Description)
+	msg = edit(alice, "e2", 1, "Edited by alice")
+	assert.Equal(t, http.StatusConflict, msg.Status, "alice edited a version bob already changed")
+	assert.Equal(t, "Edited by bob", msg.Todo.Description, "the conflict comes with the current item")
+	assert.Equal(t, collabAck, edit(alice, "e3", 2, "Merged by alice").Type)
+	assert.Equal(t, http.StatusBadRequest, edit(alice, "e4", 3, "").Status)
+
+	_, err = relay.RelayOnce(t.Context())
+	assert.NoError(t, err)
+	var updates []string
+	for len(updates) < 2 {
+		if msg := read(bob); msg.Type == collabEvent && msg.Event.Type == domain.TodoUpdated {
+			updates = append(updates, msg.Event.Todo.Description)
+		}
+	}
+	assert.Equal(t, []string{"Edited by bob", "Merged by alice"}, updates, "viewers get the changes of the list")
+
+	assert.NoError(t, bob.Close())
+	assert.Equal(t, []string{"alice"}, readPresence(alice), "viewers are told who leaves")
+
+	// stopping the server closes the open connections with a going away status, and waits for
+	// the clients to acknowledge it
+	closed := make(chan error, 2)
+	for _, ws := range []*websocket.Conn{alice, carol} {
+		go func() {
+			_ = ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+			for {
+				if _, _, err := ws.ReadMessage(); err != nil {
+					closed <- err
+					return
+				}
+			}
+		}()
+	}
+	assert.NoError(t, hub.Close(t.Context()))
+	for range 2 {
+		err := <-closed
+		assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), err)
+	}
+	late := dial("alice")
+	defer late.Close()
+	assert.NoError(t, late.SetReadDeadline(time.Now().Add(5*time.Second)))
+	_, _, err = late.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), "a stopped hub refuses new connections")
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"github.com/taheri24/helitask/pkg/domain"
+	"github.com/taheri24/helitask/pkg/events"
+)
+
+// LastEventIDHeader is sent by reconnecting EventSource clients with the id of the last event they got
+const LastEventIDHeader = "Last-Event-ID"
+
+// TodoEventsHandler streams the changes of todo items to clients as Server-Sent Events
+type TodoEventsHandler struct {
+	broker    *events.Broker
+	policy    domain.Policy
+	heartbeat time.Duration
+}
+
+// StreamTodoEvents handles the SSE stream of the todo events of the workspace the caller may read.
+// The list_id and owner query parameters narrow the stream. A client resuming with Last-Event-ID
+// is first sent the events it missed; when the replay buffer no longer holds all of them, it gets
+// a reset event and should read the items again.
+func (h *TodoEventsHandler) StreamTodoEvents(c *gin.Context) {
+	ctx := c.Request.Context()
+	var listID *domain.UUID
+	if v := c.Query("list_id"); v != "" {
+		id, err := domain.ParseUUID(v)
+		if err != nil {
+			helper.ResponseError(c, http.StatusBadRequest, "Invalid list_id", err)
+			return
+		}
+		if !helper.Authorize(c, h.policy, domain.ActionReadList, domain.ListResource(id)) {
+			return
+		}
+		listID = &id
+	}
+	owner := c.Query("owner")
+	var after uint64
+	if v := c.GetHeader(LastEventIDHeader); v != "" {
+		var err error
+		if after, err = strconv.ParseUint(v, 10, 64); err != nil {
+			helper.ResponseError(c, http.StatusBadRequest, "Invalid Last-Event-ID", err)
+			return
+		}
+	}
+
+	workspaceID := domain.WorkspaceFromContext(ctx).ID
+	sub, replay, complete := h.broker.Subscribe(workspaceID, after, func(event *domain.Event) bool {
+		return event.WorkspaceID == workspaceID &&
+			(listID == nil || domain.SameList(listID, event.Todo.ListID)) &&
+			(owner == "" || event.Todo.OwnerID == owner)
+	})
+	defer sub.Close()
+
+	c.Header("Content-Type", sse.ContentType)
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	if !complete {
+		c.Render(-1, sse.Event{Event: "reset", Data: gin.H{"reason": "missed events are no longer available"}})
+	}
+	for _, event := range replay {
+		if !h.send(c, event) {
+			return
+		}
+	}
+	c.Writer.Flush()
+
+	var heartbeat <-chan time.Time
+	if h.heartbeat > 0 {
+		ticker := time.NewTicker(h.heartbeat)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-sub.Events():
+			// a closed subscription fell behind; the client resumes from its last event
+			if !ok || !h.send(c, event) {
+				return
+			}
+		case now := <-heartbeat:
+			c.Render(-1, sse.Event{Event: "heartbeat", Data: now.UTC().Format(time.RFC3339)})
+		}
+		c.Writer.Flush()
+	}
+}
+
+// send writes event when the caller may read its item, and reports whether the stream goes on
+func (h *TodoEventsHandler) send(c *gin.Context, event *domain.Event) bool {
+	resource := domain.Resource{ListID: event.Todo.ListID, OwnerID: event.Todo.OwnerID}
+	err := h.policy.Authorize(c.Request.Context(), domain.ActionReadTodo, resource)
+	if errors.Is(err, domain.ErrForbidden) || errors.Is(err, domain.ErrUnauthenticated) {
+		return true
+	} else if err != nil {
+		return false
+	}
+	c.Render(-1, sse.Event{Id: strconv.FormatUint(event.Sequence, 10), Event: string(event.Type), Data: event})
+	return true
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taheri24/helitask/pkg/auth"
+	"github.com/taheri24/helitask/pkg/config"
+	"github.com/taheri24/helitask/pkg/events"
+	"go.uber.org/fx"
+)
+
+// sseMessage is one message read from an event stream
+type sseMessage struct {
+	id, event, data string
+}
+
+// openStream connects to an event stream and returns its messages until ctx is done
+func openStream(t *testing.T, ctx context.Context, url, token, lastEventID string) <-chan sseMessage {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if lastEventID != "" {
+		req.Header.Set(LastEventIDHeader, lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.True(t, strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream"))
+	messages := make(chan sseMessage, 16)
+	go func() {
+		defer resp.Body.Close()
+		defer close(messages)
+		var msg sseMessage
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			field, value, _ := strings.Cut(scanner.Text(), ":")
+			switch field {
+			case "id":
+				msg.id = value
+			case "event":
+				msg.event = value
+			case "data":
+				msg.data = value
+			case "":
+				messages <- msg
+				msg = sseMessage{}
+			}
+		}
+	}()
+	return messages
+}
+
+// next returns the next message of the stream that is not a heartbeat
+func next(t *testing.T, messages <-chan sseMessage) sseMessage {
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case msg := <-messages:
+			if msg.event != "heartbeat" {
+				return msg
+			}
+		case <-timeout:
+			t.Fatal("no event received")
+		}
+	}
+}
+
+// TestTodoEvents tests streaming the changes of todo items as Server-Sent Events
+func TestTodoEvents(t *testing.T) {
+	cfg := config.Default()
+	cfg.Auth.JWTSecret = "test-secret"
+	cfg.Stream.Heartbeat, cfg.Stream.ReplaySize = 20*time.Millisecond, 2
+	var relay *events.Relay
+	app, fxApp := setupAppWithConfig(t, "", cfg, fx.Populate(&relay))
+	fxApp.RequireStart()
+	defer fxApp.RequireStop()
+	server := httptest.NewServer(app)
+	defer server.Close()
+	token := func(user string) string {
+		token, err := auth.SignHS256(auth.Claims{"sub": user, "workspace": "default"}, []byte(cfg.Auth.JWTSecret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	create := func(user, description string) string {
+		req, w := setupHTTP("POST", "/api/v0/todo/", `{"description": "`+description+`", "due_date": "2025-03-01T10:00:00Z"}`)
+		req.Header.Set("Authorization", "Bearer "+token(user))
+		app.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		_, err := relay.RelayOnce(t.Context())
+		assert.NoError(t, err)
+		return extractJsonVal(w.Body.Bytes(), "id")
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	stream := openStream(t, ctx, server.URL+"/api/v0/todo/events", token("alice"), "")
+	heartbeat := <-stream
+	assert.Equal(t, "heartbeat", heartbeat.event, "an idle stream gets heartbeats")
+
+	create("bob", "Private to bob")
+	id := create("alice", "Streamed")
+	msg := next(t, stream)
+	assert.Equal(t, "todo.created", msg.event, "alice does not get the events of the items of bob")
+	assert.Contains(t, msg.data, id)
+	assert.NotEmpty(t, msg.id)
+	cancel()
+
+	// a client resuming with the id of the last event it got is sent the events it missed
+	firstID := msg.id
+	create("alice", "Missed")
+	ctx, cancel = context.WithCancel(t.Context())
+	defer cancel()
+	resumed := openStream(t, ctx, server.URL+"/api/v0/todo/events?owner=alice", token("alice"), firstID)
+	msg = next(t, resumed)
+	assert.Contains(t, msg.data, "Missed")
+	assert.Greater(t, msg.id, firstID)
+	cancel()
+
+	create("alice", "Evicts the first event")
+	ctx, cancel = context.WithCancel(t.Context())
+	defer cancel()
+	reset := openStream(t, ctx, server.URL+"/api/v0/todo/events", token("alice"), "1")
+	assert.Equal(t, "reset", next(t, reset).event, "the replay buffer only holds the two most recent events")
+
+	req, w := setupHTTP("GET", "/api/v0/todo/events?list_id=nope", "")
+	req.Header.Set("Authorization", "Bearer "+token("alice"))
+	app.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
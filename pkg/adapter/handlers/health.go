@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/taheri24/helitask/pkg/domain"
+	"github.com/taheri24/helitask/pkg/logger"
+)
+
+// HealthHandler answers liveness and readiness probes
+type HealthHandler struct {
+	database domain.HealthChecker
+	logger   logger.Logger
+}
+
+// Live reports that the process is up; it does not depend on the database,
+// so an unreachable database never gets the service restarted
+func (h *HealthHandler) Live(c *gin.Context) {
+	helper.SendSuccessResponse(c, http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Ready reports whether the service can serve requests. While the database is
+// unreachable it answers 503 with a degraded status and keeps running. Probes are not
+// authenticated, so the cause is only logged.
+func (h *HealthHandler) Ready(c *gin.Context) {
+	if err := h.database.Check(c.Request.Context()); err != nil {
+		h.logger.Error("Readiness check failed", err)
+		helper.SendSuccessResponse(c, http.StatusServiceUnavailable, gin.H{
+			"status": "degraded",
+			"checks": gin.H{"database": "database unavailable"},
+		})
+		return
+	}
+	helper.SendSuccessResponse(c, http.StatusOK, gin.H{
+		"status": "ready",
+		"checks": gin.H{"database": "ok"},
+	})
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/taheri24/helitask/pkg/logger"
+	"github.com/taheri24/helitask/pkg/ports/storage"
+	"github.com/taheri24/helitask/pkg/ports/storage/sqlite"
+)
+
+// TestHealthProbes tests the liveness and readiness probes
+func TestHealthProbes(t *testing.T) {
+	app, fxApp := setupApp(t, "")
+	fxApp.RequireStart()
+	defer fxApp.RequireStop()
+
+	for path, status := range map[string]string{"/health/live": "ok", "/health/ready": "ready"} {
+		req, w := setupHTTP("GET", path, "")
+		app.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, status, extractJsonVal(w.Body.Bytes(), "status"))
+	}
+}
+
+// TestReadinessDegraded tests that an unreachable database degrades readiness without failing liveness
+func TestReadinessDegraded(t *testing.T) {
+	db := sqlite.NewDb(t, "")
+	sqlDb, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDb.Close()
+	h, app := ProvideHealthHandler(storage.NewDBHealth(db, logger.Nop()), logger.Nop()), gin.New()
+	app.GET("/health/live", h.Live)
+	app.GET("/health/ready", h.Ready)
+
+	req, w := setupHTTP("GET", "/health/ready", "")
+	app.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "degraded", extractJsonVal(w.Body.Bytes(), "status"))
+	assert.NotContains(t, w.Body.String(), "closed", "the cause of the failure is not disclosed")
+
+	req, w = setupHTTP("GET", "/health/live", "")
+	app.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -1,9 +1,12 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/taheri24/helitask/pkg/domain"
 	"github.com/taheri24/helitask/pkg/logger"
 )
 
@@ -20,8 +23,13 @@ func NewBaseHandler(defaultLogger logger.Logger) *Helper {
 	return &Helper{defaultLogger: defaultLogger}
 }
 
-// ResponseError sends a standardized error response and logs the error
+// ResponseError sends a standardized error response and logs the error.
+// Errors caused by an exceeded deadline are answered with 504 instead of status.
 func (h *Helper) ResponseError(c *gin.Context, status int, message string, err error) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		status = http.StatusGatewayTimeout
+	}
+
 	// Store logger in a variable for reuse
 	logger := h.GetLogger(c)
 
@@ -33,6 +41,48 @@ func (h *Helper) ResponseError(c *gin.Context, status int, message string, err e
 	c.JSON(status, gin.H{"error": message})
 }
 
+// ResponseProblem sends an RFC 7807 problem details response
+func (h *Helper) ResponseProblem(c *gin.Context, status int, title string, err error) {
+	problem := gin.H{"type": "about:blank", "title": title, "status": status}
+	if err != nil {
+		problem["detail"] = err.Error()
+	}
+	c.Header("Content-Type", "application/problem+json")
+	c.JSON(status, problem)
+}
+
+// Authorize asks the policy whether the request may perform action on resource.
+// When it may not, a problem response is sent and false is returned.
+func (h *Helper) Authorize(c *gin.Context, policy domain.Policy, action domain.Action, resource domain.Resource) bool {
+	return h.AuthorizeIn(c.Request.Context(), c, policy, action, resource)
+}
+
+// AuthorizeIn is Authorize asking the policy with ctx, such as the context of a running transaction
+func (h *Helper) AuthorizeIn(ctx context.Context, c *gin.Context, policy domain.Policy, action domain.Action, resource domain.Resource) bool {
+	err := policy.Authorize(ctx, action, resource)
+	if err == nil {
+		return true
+	}
+	if !h.ResponseAuthError(c, err) {
+		h.ResponseError(c, http.StatusInternalServerError, "Failed to authorize request", err)
+	}
+	return false
+}
+
+// ResponseAuthError sends a problem response when err is a refusal of the policy, reporting
+// whether it was one
+func (h *Helper) ResponseAuthError(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, domain.ErrUnauthenticated):
+		h.ResponseProblem(c, http.StatusUnauthorized, "Authentication required", err)
+	case errors.Is(err, domain.ErrForbidden):
+		h.ResponseProblem(c, http.StatusForbidden, "Forbidden", err)
+	default:
+		return false
+	}
+	return true
+}
+
 // SendSuccessResponse sends a standardized success response
 func (h *Helper) SendSuccessResponse(c *gin.Context, status int, data any) {
 	c.JSON(status, data)
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/taheri24/helitask/pkg/domain"
+)
+
+// historyEntryOutput is the representation of an audit entry sent to clients
+type historyEntryOutput struct {
+	Version   int64                         `json:"version"`
+	Action    domain.AuditAction            `json:"action"`
+	Actor     string                        `json:"actor,omitempty"`
+	RequestID string                        `json:"request_id,omitempty"`
+	At        string                        `json:"at"`
+	Changes   map[string]domain.FieldChange `json:"changes"`
+}
+
+// GetTodoHistory handles listing the changes of a TodoItem, most recent first.
+// The history of items in the trash can be read as well.
+func (h *TodoHandler) GetTodoHistory(c *gin.Context) {
+	id, err := domain.ParseUUID(c.Param("id"))
+	if err != nil {
+		helper.ResponseError(c, http.StatusBadRequest, "Invalid UUID", err)
+		return
+	}
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+	history, err := h.service.History(c.Request.Context(), id, page)
+	if err != nil {
+		h.responseServiceError(c, "Failed to read todo history", err)
+		return
+	}
+	items := make([]historyEntryOutput, len(history.Entries))
+	for i, entry := range history.Entries {
+		items[i] = historyEntryOutput{entry.Version, entry.Action, entry.ActorID, entry.RequestID, entry.At.UTC().Format(time.RFC3339Nano), entry.Changes}
+	}
+	out := gin.H{"items": items}
+	if history.More {
+		out["next_offset"] = history.Next
+	}
+	helper.SendSuccessResponse(c, http.StatusOK, out)
+}
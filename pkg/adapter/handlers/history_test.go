@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taheri24/helitask/pkg/auth"
+	"github.com/taheri24/helitask/pkg/config"
+	"github.com/taheri24/helitask/pkg/server"
+)
+
+// TestTodoHistory tests that changes of a todo item are listed with their actor, request id and diff
+func TestTodoHistory(t *testing.T) {
+	cfg := config.Default()
+	cfg.Auth.JWTSecret = "test-secret"
+	app, fxApp := setupAppWithConfig(t, "", cfg)
+	fxApp.RequireStart()
+	defer fxApp.RequireStop()
+	do := func(method, path, user, requestID, body string) *httptest.ResponseRecorder {
+		req, w := setupHTTP(method, path, body)
+		token, err := auth.SignHS256(auth.Claims{"sub": user, "workspace": "default"}, []byte(cfg.Auth.JWTSecret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		if requestID != "" {
+			req.Header.Set(server.RequestIDHeader, requestID)
+		}
+		app.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("POST", "/api/v0/todo/", "alice", "", `{"description": "Audited Todo", "due_date": "2025-03-01T10:00:00Z"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.NotEmpty(t, w.Header().Get(server.RequestIDHeader), "a request id is generated when the client sends none")
+	id := extractJsonVal(w.Body.Bytes(), "id")
+
+	w = do("PUT", "/api/v0/todo/"+id, "alice", "move-due-date", `{"description": "Audited Todo", "due_date": "2025-03-02T10:00:00Z", "completed": true}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "move-due-date", w.Header().Get(server.RequestIDHeader))
+
+	w = do("GET", "/api/v0/todo/"+id+"/history", "alice", "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var history struct {
+		Items []struct {
+			Action    string `json:"action"`
+			Actor     string `json:"actor"`
+			RequestID string `json:"request_id"`
+			Changes   map[string]struct {
+				Before any `json:"before"`
+				After  any `json:"after"`
+			} `json:"changes"`
+		} `json:"items"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &history))
+	if assert.Len(t, history.Items, 2) {
+		change := history.Items[0]
+		assert.Equal(t, "completed", change.Action)
+		assert.Equal(t, "alice", change.Actor)
+		assert.Equal(t, "move-due-date", change.RequestID)
+		assert.Equal(t, "2025-03-01T10:00:00Z", change.Changes["due_date"].Before)
+		assert.Equal(t, "2025-03-02T10:00:00Z", change.Changes["due_date"].After)
+		assert.Equal(t, true, change.Changes["completed"].After)
+		assert.NotContains(t, change.Changes, "description")
+		assert.Equal(t, "created", history.Items[1].Action)
+	}
+
+	w = do("GET", "/api/v0/todo/"+id+"/history?limit=1", "alice", "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var page struct {
+		NextOffset int `json:"next_offset"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	assert.Equal(t, 1, page.NextOffset)
+	w = do("GET", "/api/v0/todo/"+id+"/history?limit=2", "alice", "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "next_offset", "a full last page does not link to an empty one")
+
+	w = do("GET", "/api/v0/todo/"+id+"/history", "bob", "", "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/taheri24/helitask/pkg/domain"
+)
+
+// IdempotencyKeyHeader is the request header naming an idempotent request
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+const maxIdempotencyKeyLength = 255
+
+// idempotencyWriteTimeout bounds storing the outcome of a request, which outlives the request
+const idempotencyWriteTimeout = 5 * time.Second
+
+// Idempotency replays the stored response of requests repeated with the same Idempotency-Key.
+// Keys are scoped to the workspace and principal of the request; reusing a key with a
+// different request is rejected with 422, and with a request still in flight with 409.
+type Idempotency struct {
+	repository domain.IdempotencyRepository
+	ttl        time.Duration
+}
+
+// NewIdempotency creates the Idempotency middleware, keeping responses for ttl
+func NewIdempotency(repository domain.IdempotencyRepository, ttl time.Duration) *Idempotency {
+	return &Idempotency{repository: repository, ttl: ttl}
+}
+
+// recordingWriter keeps a copy of the response body written by the handlers
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// Middleware is the gin handler of the Idempotency middleware
+func (m *Idempotency) Middleware(c *gin.Context) {
+	key := c.GetHeader(IdempotencyKeyHeader)
+	if key == "" {
+		c.Next()
+		return
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		helper.ResponseError(c, http.StatusBadRequest, "Idempotency-Key is too long", nil)
+		c.Abort()
+		return
+	}
+
+	ctx := c.Request.Context()
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		helper.ResponseError(c, http.StatusBadRequest, "Invalid input", err)
+		c.Abort()
+		return
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	clientID := domain.PrincipalFromContext(ctx).UserID
+	record := domain.IdempotencyRecord{
+		ClientID:    clientID,
+		Key:         key,
+		Fingerprint: fingerprint(c.Request, body),
+		ExpiresAt:   time.Now().Add(m.ttl),
+	}
+	if err := m.repository.Reserve(ctx, &record); err != nil {
+		if errors.Is(err, domain.ErrConflict) {
+			m.replay(c, &record)
+		} else {
+			helper.ResponseError(c, http.StatusInternalServerError, "Failed to reserve Idempotency-Key", err)
+		}
+		c.Abort()
+		return
+	}
+
+	writer := &recordingWriter{ResponseWriter: c.Writer}
+	c.Writer = writer
+	c.Next()
+
+	// the outcome is stored even when the client went away, or the key would stay reserved
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), idempotencyWriteTimeout)
+	defer cancel()
+	if status := writer.Status(); status >= http.StatusInternalServerError {
+		// failures are not remembered, so that the client can retry with the same key
+		if err := m.repository.Release(ctx, clientID, key); err != nil {
+			helper.GetLogger(c).Error("Failed to release Idempotency-Key", err)
+		}
+	} else if err := m.repository.Complete(ctx, clientID, key, status, writer.body.Bytes()); err != nil {
+		helper.GetLogger(c).Error("Failed to store idempotent response", err)
+	}
+}
+
+// replay answers a request whose key is already taken by the record stored for it
+func (m *Idempotency) replay(c *gin.Context, request *domain.IdempotencyRecord) {
+	stored, err := m.repository.Get(c.Request.Context(), request.ClientID, request.Key)
+	switch {
+	case err != nil:
+		helper.ResponseError(c, http.StatusInternalServerError, "Failed to read Idempotency-Key", err)
+	case stored.Fingerprint != request.Fingerprint:
+		helper.ResponseError(c, http.StatusUnprocessableEntity, "Idempotency-Key was used with a different request", nil)
+	case !stored.Completed():
+		helper.ResponseError(c, http.StatusConflict, "a request with this Idempotency-Key is in progress", nil)
+	default:
+		c.Header("Idempotent-Replayed", "true")
+		c.Data(stored.StatusCode, "application/json; charset=utf-8", stored.Body)
+	}
+}
+
+// fingerprint identifies the method, path and body of a request
+func fingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.Path+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
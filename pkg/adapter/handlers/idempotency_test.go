@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/taheri24/helitask/pkg/ports/storage"
+	"github.com/taheri24/helitask/pkg/ports/storage/sqlite"
+)
+
+// TestCreateTodoItemIdempotency tests that retried creations replay the original response
+func TestCreateTodoItemIdempotency(t *testing.T) {
+	app, fxApp := setupApp(t, "")
+	fxApp.RequireStart()
+	defer fxApp.RequireStop()
+
+	post := func(key, workspace, input string) (int, string, []byte) {
+		req, w := setupHTTP("POST", "/api/v0/todo/", input)
+		req.Header.Set(IdempotencyKeyHeader, key)
+		if workspace != "" {
+			req.Header.Set("X-Workspace-ID", workspace)
+		}
+		app.ServeHTTP(w, req)
+		return w.Code, w.Header().Get("Idempotent-Replayed"), w.Body.Bytes()
+	}
+	input := `{"description": "Test Todo", "due_date": "2025-12-31T23:59:59Z"}`
+
+	status, replayed, body := post("key-1", "", input)
+	if !assert.Equal(t, http.StatusCreated, status) {
+		t.Log(string(body))
+	}
+	assert.Empty(t, replayed)
+	id := extractJsonVal(body, "id")
+
+	status, replayed, body = post("key-1", "", input)
+	assert.Equal(t, http.StatusCreated, status)
+	assert.Equal(t, "true", replayed)
+	assert.Equal(t, id, extractJsonVal(body, "id"))
+
+	status, _, body = post("key-1", "", `{"description": "Other Todo", "due_date": "2025-12-31T23:59:59Z"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, status)
+	assert.Contains(t, extractJsonVal(body, "error"), "different request")
+
+	status, _, body = post("key-2", "", input)
+	assert.Equal(t, http.StatusCreated, status)
+	assert.NotEqual(t, id, extractJsonVal(body, "id"))
+
+	status, replayed, body = post("key-1", "team-a", input)
+	assert.Equal(t, http.StatusCreated, status, "keys are scoped to the workspace")
+	assert.Empty(t, replayed)
+	assert.NotEqual(t, id, extractJsonVal(body, "id"))
+
+	status, _, _ = post("key-3", "", `{"description": ""}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	status, replayed, _ = post("key-3", "", `{"description": ""}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "true", replayed, "client errors are replayed as well")
+}
+
+// TestIdempotencyClientGone tests that the outcome of a request is stored when its client
+// disconnects before the response is written
+func TestIdempotencyClientGone(t *testing.T) {
+	idempotency := NewIdempotency(storage.NewIdempotencyRepository(sqlite.NewDb(t, "")), time.Hour)
+	app := gin.New()
+	var disconnect context.CancelFunc
+	calls := 0
+	app.POST("/",
+		func(c *gin.Context) {
+			var ctx context.Context
+			ctx, disconnect = context.WithCancel(c.Request.Context())
+			c.Request = c.Request.WithContext(ctx)
+		},
+		idempotency.Middleware,
+		func(c *gin.Context) {
+			calls++
+			disconnect()
+			status := http.StatusCreated
+			if c.Query("fail") != "" {
+				status = http.StatusInternalServerError
+			}
+			c.JSON(status, gin.H{"call": calls})
+		})
+	post := func(key, query string) (int, string) {
+		req, w := setupHTTP("POST", "/"+query, `{}`)
+		req.Header.Set(IdempotencyKeyHeader, key)
+		req.Header.Set("X-LOG-SOURCE", "idempotency-test")
+		app.ServeHTTP(w, req)
+		return w.Code, w.Header().Get("Idempotent-Replayed")
+	}
+
+	post("key-1", "")
+	status, replayed := post("key-1", "")
+	assert.Equal(t, http.StatusCreated, status)
+	assert.Equal(t, "true", replayed, "the response is stored although the client was gone")
+
+	post("key-2", "?fail=1")
+	status, replayed = post("key-2", "")
+	assert.Equal(t, http.StatusCreated, status, "a failed request releases its key although the client was gone")
+	assert.Empty(t, replayed)
+	assert.Equal(t, 3, calls)
+}
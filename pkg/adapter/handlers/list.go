@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/taheri24/helitask/pkg/domain"
+)
+
+// ListHandler struct for HTTP requests on shared lists and their members
+type ListHandler struct {
+	lists       domain.ListRepository
+	memberships domain.MembershipRepository
+	policy      domain.Policy
+}
+
+type membershipOutput struct {
+	UserID string      `json:"user_id"`
+	Role   domain.Role `json:"role"`
+}
+
+// CreateList handles creating a new List owned by the caller
+func (h *ListHandler) CreateList(c *gin.Context) {
+	ctx := c.Request.Context()
+	var input struct {
+		Name string `json:"name"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		helper.ResponseError(c, http.StatusBadRequest, "Invalid input", err)
+		return
+	}
+	if input.Name == "" {
+		helper.ResponseError(c, http.StatusBadRequest, "name is required", nil)
+		return
+	}
+	if !helper.Authorize(c, h.policy, domain.ActionCreateList, domain.Resource{}) {
+		return
+	}
+
+	list := domain.List{
+		ID:        domain.NewUUID(),
+		Name:      input.Name,
+		CreatedBy: domain.PrincipalFromContext(ctx).UserID,
+	}
+	if err := h.lists.Create(ctx, &list); err != nil {
+		if errors.Is(err, domain.ErrConflict) {
+			helper.ResponseError(c, http.StatusConflict, "list name already in use", nil)
+			return
+		}
+		helper.ResponseError(c, http.StatusInternalServerError, "Failed to save list", err)
+		return
+	}
+	helper.SendCreatedResponse(c, list.ID.String())
+}
+
+// GetList handles retrieving a List by ID
+func (h *ListHandler) GetList(c *gin.Context) {
+	listID, ok := h.parseListID(c)
+	if !ok {
+		return
+	}
+	list, ok := h.authorizeList(c, listID, domain.ActionReadList)
+	if !ok {
+		return
+	}
+	var output = struct {
+		ID        string `json:"id"`
+		Name      string `json:"name"`
+		CreatedBy string `json:"created_by"`
+	}{list.ID.String(), list.Name, list.CreatedBy}
+
+	helper.SendSuccessResponse(c, http.StatusOK, output)
+}
+
+// ListMembers handles retrieving the members of a List
+func (h *ListHandler) ListMembers(c *gin.Context) {
+	listID, ok := h.parseListID(c)
+	if !ok {
+		return
+	}
+	if _, ok := h.authorizeList(c, listID, domain.ActionReadList); !ok {
+		return
+	}
+	members, err := h.memberships.List(c.Request.Context(), listID)
+	if err != nil {
+		helper.ResponseError(c, http.StatusInternalServerError, "Failed to fetch members", err)
+		return
+	}
+	output := make([]membershipOutput, 0, len(members))
+	for _, m := range members {
+		output = append(output, membershipOutput{m.UserID, m.Role})
+	}
+	helper.SendSuccessResponse(c, http.StatusOK, output)
+}
+
+// InviteMember handles adding a user to a List with a role
+func (h *ListHandler) InviteMember(c *gin.Context) {
+	listID, ok := h.parseListID(c)
+	if !ok {
+		return
+	}
+	var input membershipOutput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		helper.ResponseError(c, http.StatusBadRequest, "Invalid input", err)
+		return
+	}
+	if input.UserID == "" {
+		helper.ResponseError(c, http.StatusBadRequest, "user_id is required", nil)
+		return
+	}
+	if !input.Role.Valid() {
+		helper.ResponseError(c, http.StatusBadRequest, "role must be one of viewer, editor or admin", nil)
+		return
+	}
+	if _, ok := h.authorizeList(c, listID, domain.ActionManageMembers); !ok {
+		return
+	}
+
+	membership := domain.Membership{ListID: listID, UserID: input.UserID, Role: input.Role}
+	if err := h.memberships.Add(c.Request.Context(), &membership); err != nil {
+		if errors.Is(err, domain.ErrConflict) {
+			helper.ResponseError(c, http.StatusConflict, "user is already a member", nil)
+			return
+		}
+		helper.ResponseError(c, http.StatusInternalServerError, "Failed to save membership", err)
+		return
+	}
+	helper.SendSuccessResponse(c, http.StatusCreated, input)
+}
+
+// ChangeMemberRole handles changing the role of an existing member
+func (h *ListHandler) ChangeMemberRole(c *gin.Context) {
+	listID, ok := h.parseListID(c)
+	if !ok {
+		return
+	}
+	var input struct {
+		Role domain.Role `json:"role"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		helper.ResponseError(c, http.StatusBadRequest, "Invalid input", err)
+		return
+	}
+	if !input.Role.Valid() {
+		helper.ResponseError(c, http.StatusBadRequest, "role must be one of viewer, editor or admin", nil)
+		return
+	}
+	if _, ok := h.authorizeList(c, listID, domain.ActionManageMembers); !ok {
+		return
+	}
+
+	userID := c.Param("user_id")
+	if err := h.memberships.UpdateRole(c.Request.Context(), listID, userID, input.Role); err != nil {
+		h.responseLookupError(c, err)
+		return
+	}
+	helper.SendSuccessResponse(c, http.StatusOK, membershipOutput{userID, input.Role})
+}
+
+// authorizeList looks up the list before authorizing action on it, so that a missing list is
+// reported as such to every caller of the workspace
+func (h *ListHandler) authorizeList(c *gin.Context, listID domain.UUID, action domain.Action) (*domain.List, bool) {
+	list, err := h.lists.GetByID(c.Request.Context(), listID)
+	if err != nil {
+		h.responseLookupError(c, err)
+		return nil, false
+	}
+	if !helper.Authorize(c, h.policy, action, domain.ListResource(listID)) {
+		return nil, false
+	}
+	return list, true
+}
+
+func (h *ListHandler) parseListID(c *gin.Context) (domain.UUID, bool) {
+	id, err := domain.ParseUUID(c.Param("id"))
+	if err != nil {
+		helper.ResponseError(c, http.StatusBadRequest, "Invalid UUID", err)
+		return id, false
+	}
+	return id, true
+}
+
+func (h *ListHandler) responseLookupError(c *gin.Context, err error) {
+	if errors.Is(err, domain.ErrRecordNotFound) {
+		helper.ResponseError(c, http.StatusNotFound, "record not found", err)
+		return
+	}
+	helper.ResponseError(c, http.StatusInternalServerError, "Failed to fetch record", err)
+}
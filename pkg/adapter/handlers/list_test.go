@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taheri24/helitask/pkg/auth"
+	"github.com/taheri24/helitask/pkg/config"
+	"github.com/taheri24/helitask/pkg/domain"
+)
+
+// TestSharedListRoles tests that todo actions on a shared list are governed by membership roles
+func TestSharedListRoles(t *testing.T) {
+	cfg := config.Default()
+	cfg.Auth.JWTSecret = "test-secret"
+	app, fxApp := setupAppWithConfig(t, "", cfg)
+	fxApp.RequireStart()
+	defer fxApp.RequireStop()
+
+	tokens := map[string]string{}
+	for _, user := range []string{"alice", "bob", "carol"} {
+		token, err := auth.SignHS256(auth.Claims{"sub": user, "workspace": "default"}, []byte(cfg.Auth.JWTSecret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens[user] = "Bearer " + token
+	}
+	call := func(user, method, path, body string) (int, []byte) {
+		req, w := setupHTTP(method, path, body)
+		if user != "" {
+			req.Header.Set("Authorization", tokens[user])
+		}
+		app.ServeHTTP(w, req)
+		if w.Code == http.StatusForbidden {
+			assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+		}
+		return w.Code, w.Body.Bytes()
+	}
+
+	status, body := call("", "POST", "/api/v0/lists/", `{"name": "Sprint 1"}`)
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	status, body = call("alice", "POST", "/api/v0/lists/", `{"name": "Sprint 1"}`)
+	if !assert.Equal(t, http.StatusCreated, status) {
+		t.Log(string(body))
+	}
+	listID := extractJsonVal(body, "id")
+	listPath := "/api/v0/lists/" + listID
+
+	status, _ = call("alice", "POST", "/api/v0/lists/", `{"name": "Sprint 1"}`)
+	assert.Equal(t, http.StatusConflict, status)
+
+	todoInput := `{"description": "Shared Todo", "due_date": "2025-12-31T23:59:59Z", "list_id": "` + listID + `"}`
+	status, body = call("alice", "POST", "/api/v0/todo/", todoInput)
+	if !assert.Equal(t, http.StatusCreated, status) {
+		t.Log(string(body))
+	}
+	todoPath := "/api/v0/todo/" + extractJsonVal(body, "id")
+	missingPath := "/api/v0/lists/" + domain.NewUUID().String()
+
+	type TestCase struct {
+		name           string
+		user           string
+		method, path   string
+		body           string
+		expectedStatus int
+	}
+	testCases := []TestCase{
+		{"Non member cannot read", "bob", "GET", todoPath, "", http.StatusForbidden},
+		{"Admin invites viewer", "alice", "POST", listPath + "/members", `{"user_id": "bob", "role": "viewer"}`, http.StatusCreated},
+		{"Duplicate invitation", "alice", "POST", listPath + "/members", `{"user_id": "bob", "role": "viewer"}`, http.StatusConflict},
+		{"Viewer reads todo", "bob", "GET", todoPath, "", http.StatusOK},
+		{"Viewer reads list", "bob", "GET", listPath, "", http.StatusOK},
+		{"Viewer cannot create", "bob", "POST", "/api/v0/todo/", todoInput, http.StatusForbidden},
+		{"Viewer cannot invite", "bob", "POST", listPath + "/members", `{"user_id": "carol", "role": "viewer"}`, http.StatusForbidden},
+		{"Admin promotes to editor", "alice", "PUT", listPath + "/members/bob", `{"role": "editor"}`, http.StatusOK},
+		{"Invalid role", "alice", "PUT", listPath + "/members/bob", `{"role": "owner"}`, http.StatusBadRequest},
+		{"Unknown member", "alice", "PUT", listPath + "/members/dave", `{"role": "viewer"}`, http.StatusNotFound},
+		{"Editor creates", "bob", "POST", "/api/v0/todo/", todoInput, http.StatusCreated},
+		{"Editor cannot change roles", "bob", "PUT", listPath + "/members/bob", `{"role": "admin"}`, http.StatusForbidden},
+		{"Anonymous cannot read", "", "GET", todoPath, "", http.StatusUnauthorized},
+		{"Other user cannot read members", "carol", "GET", listPath + "/members", "", http.StatusForbidden},
+		{"Admin reads members", "alice", "GET", listPath + "/members", "", http.StatusOK},
+		{"Unknown list", "carol", "GET", missingPath, "", http.StatusNotFound},
+		{"Members of an unknown list", "carol", "GET", missingPath + "/members", "", http.StatusNotFound},
+		{"Invitation to an unknown list", "alice", "POST", missingPath + "/members", `{"user_id": "bob", "role": "viewer"}`, http.StatusNotFound},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			status, body := call(tc.user, tc.method, tc.path, tc.body)
+			if !assert.Equal(t, tc.expectedStatus, status) {
+				t.Log(string(body))
+			}
+		})
+	}
+}
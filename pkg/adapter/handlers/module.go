@@ -1,33 +1,114 @@
 package handlers
 
 import (
+	"net/http"
+
 	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/taheri24/helitask/pkg/config"
 	"github.com/taheri24/helitask/pkg/domain"
+	"github.com/taheri24/helitask/pkg/events"
 	"github.com/taheri24/helitask/pkg/logger"
+	"github.com/taheri24/helitask/pkg/server"
+	"github.com/taheri24/helitask/pkg/service"
 	"go.uber.org/fx"
 )
 
-func ProvideTodoHandler(repository domain.TodoRepository) TodoHandler {
-	return TodoHandler{repository}
+func ProvideTodoHandler(service *service.TodoService) TodoHandler {
+	return TodoHandler{service}
+}
+
+func ProvideListHandler(lists domain.ListRepository, memberships domain.MembershipRepository, policy domain.Policy) ListHandler {
+	return ListHandler{lists, memberships, policy}
+}
+
+func ProvideWebhookHandler(webhooks domain.WebhookRepository, deliveries domain.WebhookDeliveryRepository, policy domain.Policy, cfg *config.Config) WebhookHandler {
+	return WebhookHandler{webhooks, deliveries, policy, cfg.Webhook}
+}
+
+func ProvideTodoEventsHandler(broker *events.Broker, policy domain.Policy, cfg *config.Config) TodoEventsHandler {
+	return TodoEventsHandler{broker, policy, cfg.Stream.Heartbeat}
+}
+
+func ProvideCollabHandler(service *service.TodoService, policy domain.Policy, broker *events.Broker, hub *CollabHub, logger logger.Logger, cfg *config.Config) CollabHandler {
+	upgrader := websocket.Upgrader{
+		// clients authenticate with a token rather than cookies, so connections from other origins are safe
+		CheckOrigin: func(*http.Request) bool { return true },
+	}
+	return CollabHandler{service, policy, broker, hub, logger, cfg.Stream.ClientBuffer, cfg.Stream.Heartbeat, upgrader}
+}
+
+func ProvideSyncHandler(service *service.TodoService) SyncHandler {
+	return SyncHandler{service}
+}
+
+func ProvideRPCHandler(service *service.TodoService) RPCHandler {
+	return RPCHandler{service}
+}
+
+func ProvideHealthHandler(database domain.HealthChecker, logger logger.Logger) HealthHandler {
+	return HealthHandler{database, logger}
 }
 
 var Module fx.Option
 
 func init() {
 	var (
-		todoHandler TodoHandler
+		todoHandler    TodoHandler
+		eventsHandler  TodoEventsHandler
+		collabHandler  CollabHandler
+		syncHandler    SyncHandler
+		rpcHandler     RPCHandler
+		listHandler    ListHandler
+		webhookHandler WebhookHandler
+		healthHandler  HealthHandler
 	)
-	svcProviders := fx.Provide(ProvideTodoHandler)
+	svcProviders := fx.Provide(NewCollabHub, ProvideTodoHandler, ProvideTodoEventsHandler, ProvideCollabHandler, ProvideSyncHandler, ProvideRPCHandler, ProvideListHandler, ProvideWebhookHandler, ProvideHealthHandler)
 	Module = fx.Module("apiHttpRoutingV0", svcProviders,
-		fx.Populate(&todoHandler),
+		fx.Populate(&todoHandler, &eventsHandler, &collabHandler, &syncHandler, &rpcHandler, &listHandler, &webhookHandler, &healthHandler),
 		fx.Invoke(
-			func(appEngine *gin.Engine, logger logger.Logger) {
+			func(appEngine *gin.Engine, logger logger.Logger, cfg *config.Config, idempotencyRepository domain.IdempotencyRepository) {
 				helper.defaultLogger = logger
+				appEngine.GET("/health/live", healthHandler.Live)
+				appEngine.GET("/health/ready", healthHandler.Ready)
 				apiRouter := appEngine.Group("/api/v0")
+				apiRouter.Use(server.APIMiddlewares(cfg)...)
 				{
 					g, h := apiRouter.Group("/todo"), todoHandler
-					g.POST("/", h.CreateTodoItem)
+					idempotency := NewIdempotency(idempotencyRepository, cfg.Idempotency.TTL)
+					g.POST("/", idempotency.Middleware, h.CreateTodoItem)
+					g.POST("/batch", h.BatchTodoItems)
+					g.GET("/trash", h.ListTrash)
+					g.GET("/events", eventsHandler.StreamTodoEvents)
+					g.GET("/ws", collabHandler.Connect)
+					g.POST("/:id/restore", h.RestoreTodoItem)
+					g.GET("/:id/history", h.GetTodoHistory)
+					g.POST("/:id/revert", h.RevertTodoItem)
 					g.GET("/:id", h.GetTodoItem)
+					g.PUT("/:id", h.PutTodoItem)
+					g.PATCH("/:id", h.PatchTodoItem)
+					g.DELETE("/:id", h.DeleteTodoItem)
+				}
+				apiRouter.GET("/sync", syncHandler.PullChanges)
+				apiRouter.POST("/sync", syncHandler.PushChanges)
+				apiRouter.POST("/rpc", rpcHandler.Serve)
+				{
+					g, h := apiRouter.Group("/lists"), listHandler
+					g.POST("/", h.CreateList)
+					g.GET("/:id", h.GetList)
+					g.GET("/:id/members", h.ListMembers)
+					g.POST("/:id/members", h.InviteMember)
+					g.PUT("/:id/members/:user_id", h.ChangeMemberRole)
+				}
+				{
+					g, h := apiRouter.Group("/webhooks"), webhookHandler
+					g.POST("/", h.CreateWebhook)
+					g.GET("/", h.ListWebhooks)
+					g.GET("/:id", h.GetWebhook)
+					g.PUT("/:id", h.PutWebhook)
+					g.DELETE("/:id", h.DeleteWebhook)
+					g.GET("/:id/deliveries", h.ListDeliveries)
+					g.POST("/:id/deliveries/:delivery_id/retry", h.RetryDelivery)
 				}
 
 			},
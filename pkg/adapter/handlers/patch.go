@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/gin-gonic/gin"
+	"github.com/taheri24/helitask/pkg/domain"
+	"github.com/taheri24/helitask/pkg/service"
+)
+
+const (
+	// MergePatchContentType is the media type of RFC 7396 JSON Merge Patch documents
+	MergePatchContentType = "application/merge-patch+json"
+	// JSONPatchContentType is the media type of RFC 6902 JSON Patch documents
+	JSONPatchContentType = "application/json-patch+json"
+)
+
+var errUnsupportedPatch = errors.New("unsupported patch media type")
+
+// patchError is a patch document that cannot be applied, answered with status and message
+type patchError struct {
+	status  int
+	message string
+	err     error
+}
+
+func (e *patchError) Error() string {
+	return e.message
+}
+
+func (e *patchError) Unwrap() error {
+	return e.err
+}
+
+// applyPatch applies the patch document of the given media type to doc
+func applyPatch(contentType string, doc, patch []byte) ([]byte, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, errUnsupportedPatch
+	}
+	switch mediaType {
+	case MergePatchContentType:
+		return jsonpatch.MergePatch(doc, patch)
+	case JSONPatchContentType:
+		ops, err := jsonpatch.DecodePatch(patch)
+		if err != nil {
+			return nil, err
+		}
+		return ops.Apply(doc)
+	}
+	return nil, errUnsupportedPatch
+}
+
+// PatchTodoItem handles partial updates of a TodoItem with a JSON Merge Patch or a JSON Patch.
+// The patched item goes through the same validation as a created one.
+func (h *TodoHandler) PatchTodoItem(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, err := domain.ParseUUID(c.Param("id"))
+	if err != nil {
+		helper.ResponseError(c, http.StatusBadRequest, "Invalid UUID", err)
+		return
+	}
+	patch, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		helper.ResponseError(c, http.StatusBadRequest, "Invalid input", err)
+		return
+	}
+
+	todo, err := h.service.Patch(ctx, id, func(in *service.TodoInput) error {
+		doc, err := json.Marshal(todoInput{ID: &id, Description: in.Description, DueDate: in.DueDate, ListID: in.ListID, Completed: in.Completed})
+		if err != nil {
+			return err
+		}
+		patched, err := applyPatch(c.ContentType(), doc, patch)
+		if errors.Is(err, errUnsupportedPatch) {
+			return &patchError{http.StatusUnsupportedMediaType, "Unsupported patch format", err}
+		} else if err != nil {
+			return &patchError{http.StatusUnprocessableEntity, "Failed to apply patch", err}
+		}
+		var input todoInput
+		if err := json.Unmarshal(patched, &input); err != nil {
+			return &patchError{http.StatusBadRequest, "Invalid input", fmt.Errorf("patched todo item: %w", err)}
+		}
+		if input.ID == nil || *input.ID != id {
+			return &patchError{http.StatusBadRequest, "id cannot be changed", nil}
+		}
+		*in = input.fields()
+		return nil
+	})
+	var failure *patchError
+	if errors.As(err, &failure) {
+		if failure.status == http.StatusUnsupportedMediaType {
+			c.Header("Accept-Patch", MergePatchContentType+", "+JSONPatchContentType)
+		}
+		helper.ResponseError(c, failure.status, failure.message, failure.err)
+		return
+	} else if err != nil {
+		h.responseServiceError(c, "Failed to save todo item", err)
+		return
+	}
+	helper.SendSuccessResponse(c, http.StatusOK, newTodoOutput(todo))
+}
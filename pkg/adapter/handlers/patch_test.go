@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestPatchTodoItem tests partial updates with JSON Merge Patch and JSON Patch documents
+func TestPatchTodoItem(t *testing.T) {
+	app, fxApp := setupApp(t, "sample1.sql")
+	fxApp.RequireStart()
+	defer fxApp.RequireStop()
+	const path = "/api/v0/todo/3f6c1a4e-9966-4f1c-a2a9-1b8df67f8cc3"
+
+	type TestCase struct {
+		name           string
+		contentType    string
+		patch          string
+		expectedStatus int
+		description    string
+		dueDate        string
+	}
+	testCases := []TestCase{
+		{"Merge patch due date", MergePatchContentType, `{"due_date": "2025-04-01T09:00:00Z"}`, http.StatusOK, "Buy groceries", "2025-04-01 09:00:00 +0000 UTC"},
+		{"JSON patch description", JSONPatchContentType, `[{"op": "test", "path": "/description", "value": "Buy groceries"}, {"op": "replace", "path": "/description", "value": "Buy vegetables"}]`, http.StatusOK, "Buy vegetables", "2025-04-01 09:00:00 +0000 UTC"},
+		{"Failed JSON patch test", JSONPatchContentType, `[{"op": "test", "path": "/description", "value": "Buy groceries"}, {"op": "replace", "path": "/description", "value": "Buy fruit"}]`, http.StatusUnprocessableEntity, "Buy vegetables", ""},
+		{"Merge patch removing due date", MergePatchContentType, `{"due_date": null}`, http.StatusBadRequest, "Buy vegetables", ""},
+		{"Patched description empty", MergePatchContentType, `{"description": ""}`, http.StatusBadRequest, "Buy vegetables", ""},
+		{"Changing the id", JSONPatchContentType, `[{"op": "replace", "path": "/id", "value": "c2e89319-e563-4a0b-9ef0-349beb3ef672"}]`, http.StatusBadRequest, "Buy vegetables", ""},
+		{"Plain JSON body", "application/json", `{"description": "Buy fruit"}`, http.StatusUnsupportedMediaType, "Buy vegetables", ""},
+		{"Malformed patch", MergePatchContentType, `{"description": `, http.StatusUnprocessableEntity, "Buy vegetables", ""},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, w := setupHTTP("PATCH", path, tc.patch)
+			req.Header.Set("Content-Type", tc.contentType)
+			app.ServeHTTP(w, req)
+			if !assert.Equal(t, tc.expectedStatus, w.Code) {
+				t.Log(w.Body.String())
+			}
+			if tc.dueDate != "" {
+				assert.Equal(t, tc.dueDate, extractJsonVal(w.Body.Bytes(), "due_date"))
+			}
+
+			req, w = setupHTTP("GET", path, "")
+			app.ServeHTTP(w, req)
+			assert.Equal(t, tc.description, extractJsonVal(w.Body.Bytes(), "description"))
+		})
+	}
+
+	req, w := setupHTTP("PATCH", "/api/v0/todo/00000000-0000-0000-0000-000000000000", `{}`)
+	req.Header.Set("Content-Type", MergePatchContentType)
+	app.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
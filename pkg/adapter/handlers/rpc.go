@@ -0,0 +1,360 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/taheri24/helitask/pkg/domain"
+	"github.com/taheri24/helitask/pkg/service"
+)
+
+const (
+	rpcVersion = "2.0"
+	// MaxRPCBatch is the maximum number of calls of a single JSON-RPC batch
+	MaxRPCBatch = 100
+)
+
+// JSON-RPC error codes: those the specification reserves, and the server errors of the methods
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcInternalError  = -32603
+
+	rpcNotFound        = -32001
+	rpcConflict        = -32002
+	rpcVersionConflict = -32003
+	rpcUnauthenticated = -32004
+	rpcForbidden       = -32005
+	rpcQuotaExceeded   = -32006
+	rpcTimeout         = -32007
+)
+
+// rpcRequest is a JSON-RPC 2.0 call; a call without an id member is a notification
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	ID      json.RawMessage `json:"id"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+// rpcResponse holds either the result or the error of a call. The id is null when the id of
+// the call could not be read.
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// rpcMethod runs a method with the raw params of its call. Errors other than an *rpcError
+// are mapped onto error objects by rpcErrorOf.
+type rpcMethod func(h *RPCHandler, c *gin.Context, params json.RawMessage) (any, error)
+
+var rpcMethods = map[string]rpcMethod{
+	"todo.create":  (*RPCHandler).create,
+	"todo.get":     (*RPCHandler).get,
+	"todo.list":    (*RPCHandler).list,
+	"todo.update":  (*RPCHandler).update,
+	"todo.delete":  (*RPCHandler).delete,
+	"todo.restore": (*RPCHandler).restore,
+}
+
+// RPCHandler serves the todo items over JSON-RPC 2.0, applying the same rules as TodoHandler
+type RPCHandler struct {
+	service *service.TodoService
+}
+
+// Serve handles a JSON-RPC call or batch of calls. The calls of a batch run one after another,
+// and the responses of its notifications are left out; when nothing is left to answer, the
+// response is 204 without a body.
+func (h *RPCHandler) Serve(c *gin.Context) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		helper.ResponseError(c, http.StatusBadRequest, "Failed to read request", err)
+		return
+	}
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 || body[0] != '[' {
+		if res := h.call(c, body); res != nil {
+			c.JSON(http.StatusOK, res)
+		} else {
+			c.Status(http.StatusNoContent)
+		}
+		return
+	}
+
+	var calls []json.RawMessage
+	if err := json.Unmarshal(body, &calls); err != nil {
+		c.JSON(http.StatusOK, rpcFailure(nil, &rpcError{Code: rpcParseError, Message: "Parse error"}))
+		return
+	}
+	switch {
+	case len(calls) == 0:
+		c.JSON(http.StatusOK, rpcFailure(nil, &rpcError{Code: rpcInvalidRequest, Message: "Invalid Request: empty batch"}))
+		return
+	case len(calls) > MaxRPCBatch:
+		c.JSON(http.StatusOK, rpcFailure(nil, &rpcError{Code: rpcInvalidRequest, Message: fmt.Sprintf("Invalid Request: a batch holds at most %d calls", MaxRPCBatch)}))
+		return
+	}
+	responses := make([]*rpcResponse, 0, len(calls))
+	for _, raw := range calls {
+		if res := h.call(c, raw); res != nil {
+			responses = append(responses, res)
+		}
+	}
+	if len(responses) == 0 {
+		c.Status(http.StatusNoContent)
+		return
+	}
+	c.JSON(http.StatusOK, responses)
+}
+
+// call runs one call, returning nil for notifications
+func (h *RPCHandler) call(c *gin.Context, raw json.RawMessage) *rpcResponse {
+	var req rpcRequest
+	if err := json.Unmarshal(raw, &req); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) || len(raw) == 0 {
+			return rpcFailure(nil, &rpcError{Code: rpcParseError, Message: "Parse error"})
+		}
+		return rpcFailure(nil, &rpcError{Code: rpcInvalidRequest, Message: "Invalid Request"})
+	}
+	if !validRPCID(req.ID) {
+		return rpcFailure(nil, &rpcError{Code: rpcInvalidRequest, Message: "Invalid Request: id must be a string, a number or null"})
+	}
+	notification := req.ID == nil
+	if req.JSONRPC != rpcVersion || req.Method == "" {
+		return rpcFailure(req.ID, &rpcError{Code: rpcInvalidRequest, Message: `Invalid Request: jsonrpc must be "2.0" and method is required`})
+	}
+
+	var (
+		result any
+		err    error
+	)
+	if method, ok := rpcMethods[req.Method]; !ok {
+		err = &rpcError{Code: rpcMethodNotFound, Message: "Method not found: " + req.Method}
+	} else {
+		result, err = method(h, c, req.Params)
+	}
+	if notification {
+		return nil
+	}
+	if err != nil {
+		return rpcFailure(req.ID, rpcErrorOf(c, err))
+	}
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		return rpcFailure(req.ID, rpcErrorOf(c, err))
+	}
+	return &rpcResponse{JSONRPC: rpcVersion, Result: encoded, ID: req.ID}
+}
+
+func rpcFailure(id json.RawMessage, err *rpcError) *rpcResponse {
+	return &rpcResponse{JSONRPC: rpcVersion, Error: err, ID: id}
+}
+
+// validRPCID reports whether id is absent, a string, a number or null
+func validRPCID(id json.RawMessage) bool {
+	if id == nil {
+		return true
+	}
+	var value any
+	if err := json.Unmarshal(id, &value); err != nil {
+		return false
+	}
+	switch value.(type) {
+	case nil, string, float64:
+		return true
+	}
+	return false
+}
+
+// rpcErrorOf maps the errors of the repository and the policy onto error objects, logging
+// the unexpected ones
+func rpcErrorOf(c *gin.Context, err error) *rpcError {
+	var rpcErr *rpcError
+	if errors.As(err, &rpcErr) {
+		return rpcErr
+	}
+	if errors.Is(err, service.ErrInvalidInput) {
+		return &rpcError{Code: rpcInvalidParams, Message: "Invalid params: " + err.Error()}
+	}
+	code := rpcInternalError
+	switch {
+	case errors.Is(err, domain.ErrRecordNotFound):
+		code = rpcNotFound
+	case errors.Is(err, domain.ErrConflict):
+		code = rpcConflict
+	case errors.Is(err, domain.ErrVersionConflict):
+		code = rpcVersionConflict
+	case errors.Is(err, domain.ErrUnauthenticated):
+		code = rpcUnauthenticated
+	case errors.Is(err, domain.ErrForbidden):
+		code = rpcForbidden
+	case errors.Is(err, domain.ErrTodoQuotaExceeded), errors.Is(err, domain.ErrDescriptionTooLarge):
+		code = rpcQuotaExceeded
+	case errors.Is(err, context.DeadlineExceeded):
+		code = rpcTimeout
+	default:
+		helper.GetLogger(c).Error("JSON-RPC call failed", err)
+		return &rpcError{Code: rpcInternalError, Message: "Internal error"}
+	}
+	_, message := serviceErrorStatus(err, "Failed to complete the call")
+	if errors.Is(err, domain.ErrForbidden) || errors.Is(err, domain.ErrUnauthenticated) {
+		message = err.Error()
+	}
+	return &rpcError{Code: code, Message: message}
+}
+
+// decodeParams reads the params of a call, which must be an object of known members
+func decodeParams(params json.RawMessage, into any) error {
+	if len(params) == 0 || params[0] != '{' {
+		return &rpcError{Code: rpcInvalidParams, Message: "Invalid params: params must be an object"}
+	}
+	dec := json.NewDecoder(bytes.NewReader(params))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(into); err != nil {
+		return &rpcError{Code: rpcInvalidParams, Message: "Invalid params: " + err.Error()}
+	}
+	return nil
+}
+
+func invalidParams(message string) error {
+	return &rpcError{Code: rpcInvalidParams, Message: "Invalid params: " + message}
+}
+
+type rpcIDParams struct {
+	ID *domain.UUID `json:"id"`
+}
+
+// decodeID reads the id params of a call
+func decodeID(params json.RawMessage) (domain.UUID, error) {
+	var p rpcIDParams
+	if err := decodeParams(params, &p); err != nil {
+		return domain.UUID{}, err
+	}
+	if p.ID == nil {
+		return domain.UUID{}, invalidParams("id is required")
+	}
+	return *p.ID, nil
+}
+
+// create stores a new item like CreateTodoItem, returning the stored item
+func (h *RPCHandler) create(c *gin.Context, params json.RawMessage) (any, error) {
+	var input todoInput
+	if err := decodeParams(params, &input); err != nil {
+		return nil, err
+	}
+	todo, err := h.service.Create(c.Request.Context(), input.ID, input.fields())
+	if err != nil {
+		return nil, err
+	}
+	return newTodoOutput(todo), nil
+}
+
+// get returns an item outside the trash
+func (h *RPCHandler) get(c *gin.Context, params json.RawMessage) (any, error) {
+	id, err := decodeID(params)
+	if err != nil {
+		return nil, err
+	}
+	todo, err := h.service.Get(c.Request.Context(), id)
+	if err != nil {
+		return nil, err
+	}
+	return newTodoOutput(todo), nil
+}
+
+// list returns a page of the items the caller may read matching the filter params, by due date
+func (h *RPCHandler) list(c *gin.Context, params json.RawMessage) (any, error) {
+	p := struct {
+		ListID    *domain.UUID `json:"list_id"`
+		OwnerID   string       `json:"owner_id"`
+		Completed *bool        `json:"completed"`
+		Limit     int          `json:"limit"`
+		Offset    int          `json:"offset"`
+	}{Limit: domain.DefaultPageSize}
+	if len(params) > 0 {
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+	}
+	if p.Limit < 1 || p.Limit > domain.MaxPageSize {
+		return nil, invalidParams(fmt.Sprintf("limit must be between 1 and %d", domain.MaxPageSize))
+	}
+	if p.Offset < 0 {
+		return nil, invalidParams("offset must not be negative")
+	}
+	filter := domain.TodoFilter{ListID: p.ListID, OwnerID: p.OwnerID, Completed: p.Completed}
+	page, err := h.service.List(c.Request.Context(), filter, domain.Page{Limit: p.Limit, Offset: p.Offset})
+	if err != nil {
+		return nil, err
+	}
+	return newTodoPageOutput(page), nil
+}
+
+// update replaces the fields of an item like PutTodoItem, without creating missing items. With
+// expected_version it only applies while the item is still at that version.
+func (h *RPCHandler) update(c *gin.Context, params json.RawMessage) (any, error) {
+	ctx := c.Request.Context()
+	var p struct {
+		todoInput
+		ExpectedVersion int64 `json:"expected_version"`
+	}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	if p.ID == nil {
+		return nil, invalidParams("id is required")
+	}
+	if p.ExpectedVersion != 0 {
+		ctx = domain.WithExpectedVersion(ctx, p.ExpectedVersion)
+	}
+	todo, err := h.service.Update(ctx, *p.ID, p.fields())
+	if err != nil {
+		return nil, err
+	}
+	return newTodoOutput(todo), nil
+}
+
+// delete moves an item to the trash; its result is null
+func (h *RPCHandler) delete(c *gin.Context, params json.RawMessage) (any, error) {
+	id, err := decodeID(params)
+	if err != nil {
+		return nil, err
+	}
+	return nil, h.service.Delete(c.Request.Context(), id)
+}
+
+// restore takes an item out of the trash like RestoreTodoItem
+func (h *RPCHandler) restore(c *gin.Context, params json.RawMessage) (any, error) {
+	id, err := decodeID(params)
+	if err != nil {
+		return nil, err
+	}
+	todo, err := h.service.Restore(c.Request.Context(), id)
+	if errors.Is(err, domain.ErrRecordNotFound) {
+		return nil, &rpcError{Code: rpcNotFound, Message: "record not found in trash"}
+	} else if err != nil {
+		return nil, err
+	}
+	return newTodoOutput(todo), nil
+}
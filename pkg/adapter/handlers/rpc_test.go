@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taheri24/helitask/pkg/auth"
+	"github.com/taheri24/helitask/pkg/config"
+	"github.com/taheri24/helitask/pkg/domain"
+)
+
+// TestRPC tests the JSON-RPC endpoint with single calls, batches and notifications
+func TestRPC(t *testing.T) {
+	cfg := config.Default()
+	cfg.Auth.JWTSecret = "test-secret"
+	app, fxApp := setupAppWithConfig(t, "", cfg)
+	fxApp.RequireStart()
+	defer fxApp.RequireStop()
+	rpc := func(user, body string) (int, []byte) {
+		token, err := auth.SignHS256(auth.Claims{"sub": user, "workspace": "default"}, []byte(cfg.Auth.JWTSecret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, w := setupHTTP("POST", "/api/v0/rpc", body)
+		req.Header.Set("Authorization", "Bearer "+token)
+		app.ServeHTTP(w, req)
+		return w.Code, w.Body.Bytes()
+	}
+	type response struct {
+		JSONRPC string          `json:"jsonrpc"`
+		Result  json.RawMessage `json:"result"`
+		Error   *rpcError       `json:"error"`
+		ID      json.RawMessage `json:"id"`
+	}
+	call := func(user, body string) response {
+		status, out := rpc(user, body)
+		assert.Equal(t, http.StatusOK, status)
+		var res response
+		assert.NoError(t, json.Unmarshal(out, &res), string(out))
+		assert.Equal(t, "2.0", res.JSONRPC)
+		return res
+	}
+	errorCode := func(res response) int {
+		if assert.NotNil(t, res.Error, string(res.Result)) {
+			return res.Error.Code
+		}
+		return 0
+	}
+	var todo todoOutput
+	res := call("alice", `{"jsonrpc": "2.0", "id": 1, "method": "todo.create", "params": {"description": "Scripted", "due_date": "2025-03-01T10:00:00Z"}}`)
+	assert.Nil(t, res.Error)
+	assert.JSONEq(t, `1`, string(res.ID))
+	assert.NoError(t, json.Unmarshal(res.Result, &todo))
+	assert.Equal(t, int64(1), todo.Version)
+
+	res = call("alice", `{"jsonrpc": "2.0", "id": "get-1", "method": "todo.get", "params": {"id": "`+todo.ID+`"}}`)
+	assert.JSONEq(t, `"get-1"`, string(res.ID), "string ids are echoed")
+	assert.Contains(t, string(res.Result), `"Scripted"`)
+	assert.Equal(t, rpcForbidden, errorCode(call("bob", `{"jsonrpc": "2.0", "id": 2, "method": "todo.get", "params": {"id": "`+todo.ID+`"}}`)))
+	assert.Equal(t, rpcNotFound, errorCode(call("alice", `{"jsonrpc": "2.0", "id": 3, "method": "todo.get", "params": {"id": "`+domain.NewUUID().String()+`"}}`)))
+	assert.Equal(t, rpcInvalidParams, errorCode(call("alice", `{"jsonrpc": "2.0", "id": 4, "method": "todo.get", "params": ["`+todo.ID+`"]}`)))
+	assert.Equal(t, rpcInvalidParams, errorCode(call("alice", `{"jsonrpc": "2.0", "id": 5, "method": "todo.get", "params": {"id": "`+todo.ID+`", "extra": 1}}`)))
+	assert.Equal(t, rpcInvalidParams, errorCode(call("alice", `{"jsonrpc": "2.0", "id": 6, "method": "todo.create", "params": {"description": ""}}`)))
+	assert.Equal(t, rpcMethodNotFound, errorCode(call("alice", `{"jsonrpc": "2.0", "id": 7, "method": "todo.explode"}`)))
+
+	update := `{"jsonrpc": "2.0", "id": 8, "method": "todo.update", "params": {"id": "` + todo.ID + `", "expected_version": 1,
+		"description": "Scripted, done", "due_date": "2025-03-01T10:00:00Z", "completed": true}}`
+	res = call("alice", update)
+	assert.Nil(t, res.Error)
+	assert.Contains(t, string(res.Result), `"version":2`)
+	assert.Equal(t, rpcVersionConflict, errorCode(call("alice", update)))
+
+	res = call("alice", `{"jsonrpc": "2.0", "id": 9, "method": "todo.list", "params": {"completed": true}}`)
+	var listed struct {
+		Items []todoOutput `json:"items"`
+	}
+	assert.NoError(t, json.Unmarshal(res.Result, &listed))
+	if assert.Len(t, listed.Items, 1) {
+		assert.Equal(t, "Scripted, done", listed.Items[0].Description)
+	}
+
+	// malformed requests are answered with a null id
+	res = call("alice", `{"jsonrpc": "2.0", "method": "todo.get", "id": 1`)
+	assert.Equal(t, rpcParseError, errorCode(res))
+	assert.JSONEq(t, `null`, string(res.ID))
+	assert.Equal(t, rpcInvalidRequest, errorCode(call("alice", `{"jsonrpc": "1.0", "id": 10, "method": "todo.get"}`)))
+	assert.Equal(t, rpcInvalidRequest, errorCode(call("alice", `{"jsonrpc": "2.0", "id": {}, "method": "todo.get"}`)))
+	assert.Equal(t, rpcInvalidRequest, errorCode(call("alice", `[]`)))
+
+	// notifications are run but not answered
+	status, body := rpc("alice", `{"jsonrpc": "2.0", "method": "todo.create", "params": {"description": "Notified", "due_date": "2025-03-01T10:00:00Z"}}`)
+	assert.Equal(t, http.StatusNoContent, status)
+	assert.Empty(t, body)
+
+	status, body = rpc("alice", `[
+		{"jsonrpc": "2.0", "id": "a", "method": "todo.list", "params": {"owner_id": "alice"}},
+		{"jsonrpc": "2.0", "method": "todo.delete", "params": {"id": "`+todo.ID+`"}},
+		{"jsonrpc": "2.0", "id": "b", "method": "todo.get", "params": {"id": "`+todo.ID+`"}},
+		{"jsonrpc": "2.0", "id": "c", "method": "todo.restore", "params": {"id": "`+todo.ID+`"}},
+		1
+	]`)
+	assert.Equal(t, http.StatusOK, status)
+	var batch []response
+	assert.NoError(t, json.Unmarshal(body, &batch), string(body))
+	if assert.Len(t, batch, 4, "the notification is left out") {
+		assert.Contains(t, string(batch[0].Result), `"Notified"`, "the notification was run")
+		assert.Equal(t, rpcNotFound, errorCode(batch[1]), "the item was deleted by the notification before it")
+		assert.Contains(t, string(batch[2].Result), `"version":4`)
+		assert.Equal(t, rpcInvalidRequest, errorCode(batch[3]))
+	}
+
+	status, _ = rpc("alice", `[{"jsonrpc": "2.0", "method": "todo.explode"}]`)
+	assert.Equal(t, http.StatusNoContent, status, "a batch of notifications is not answered")
+}
@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/taheri24/helitask/pkg/domain"
+	"github.com/taheri24/helitask/pkg/service"
+)
+
+var errInvalidSyncToken = errors.New("invalid sync token")
+
+// SyncHandler serves the delta sync of offline-first clients
+type SyncHandler struct {
+	service *service.TodoService
+}
+
+// encodeSyncToken turns a position in the changes of a workspace into the opaque token given to clients
+func encodeSyncToken(cursor domain.SyncCursor) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(cursor.Sequence, 10) + ":" + cursor.TodoID.String()))
+}
+
+// decodeSyncToken reads the position of a token made by encodeSyncToken
+func decodeSyncToken(token string) (domain.SyncCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return domain.SyncCursor{}, errInvalidSyncToken
+	}
+	sequence, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return domain.SyncCursor{}, errInvalidSyncToken
+	}
+	var cursor domain.SyncCursor
+	if cursor.Sequence, err = strconv.ParseInt(sequence, 10, 64); err != nil || cursor.Sequence < 0 {
+		return domain.SyncCursor{}, errInvalidSyncToken
+	}
+	if cursor.TodoID, err = domain.ParseUUID(id); err != nil {
+		return domain.SyncCursor{}, errInvalidSyncToken
+	}
+	return cursor, nil
+}
+
+// syncItemOutput is the representation of a todo item sent to syncing clients, with the clocks
+// of its fields for merging it with their offline edits
+type syncItemOutput struct {
+	todoOutput
+	Clocks domain.FieldClocks `json:"clocks,omitempty"`
+}
+
+func newSyncItemOutput(todo *domain.TodoItem) syncItemOutput {
+	return syncItemOutput{newTodoOutput(todo), todo.Clocks}
+}
+
+// tombstoneOutput is the representation of a deleted todo item sent to syncing clients
+type tombstoneOutput struct {
+	ID        string `json:"id"`
+	Version   int64  `json:"version"`
+	DeletedAt string `json:"deleted_at"`
+}
+
+// PullChanges handles reading the todo items created, changed or deleted since the since token.
+// Deleted items are returned as tombstones; a first sync without a token gets none. The items
+// come in the order of their last change, limit at a time, with the token to pass to the next
+// call. has_more reports whether more changes are waiting. A token from before changes that are
+// no longer kept is answered with 410, after which the client syncs again without a token.
+func (h *SyncHandler) PullChanges(c *gin.Context) {
+	ctx := c.Request.Context()
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+	var cursor domain.SyncCursor
+	since := c.Query("since")
+	if since != "" {
+		var err error
+		if cursor, err = decodeSyncToken(since); err != nil {
+			helper.ResponseError(c, http.StatusBadRequest, "Invalid since token", err)
+			return
+		}
+	}
+
+	changes, err := h.service.Changes(ctx, cursor, page.Limit)
+	if errors.Is(err, domain.ErrResyncRequired) {
+		helper.ResponseError(c, http.StatusGone, "Sync token expired, a full sync is required", err)
+		return
+	} else if err != nil {
+		helper.ResponseError(c, http.StatusInternalServerError, "Failed to read changes", err)
+		return
+	}
+	items, tombstones := []syncItemOutput{}, []tombstoneOutput{}
+	for _, version := range changes.Versions {
+		if version.DeletedAt == nil {
+			items = append(items, newSyncItemOutput(version.Item()))
+		} else if since != "" {
+			tombstones = append(tombstones, tombstoneOutput{version.TodoID.String(), version.Version, version.DeletedAt.UTC().Format(time.RFC3339Nano)})
+		}
+	}
+	helper.SendSuccessResponse(c, http.StatusOK, gin.H{
+		"items":      items,
+		"tombstones": tombstones,
+		"sync_token": encodeSyncToken(changes.Cursor),
+		"has_more":   changes.More,
+	})
+}
+
+// syncChange is a change a client made while offline. A base version of 0 creates the item;
+// any other change only applies while the item is still at the base version. A change with
+// field clocks merges instead: each field listed in them is set when its clock is later than
+// the one of the stored field.
+type syncChange struct {
+	ID          *domain.UUID       `json:"id"`
+	BaseVersion int64              `json:"base_version"`
+	Deleted     bool               `json:"deleted"`
+	Item        *todoInput         `json:"item"`
+	Clocks      domain.FieldClocks `json:"clocks"`
+}
+
+// syncResult is the outcome of one pushed change. A conflicting change gets the current state
+// of the item, and an applied one its new state.
+type syncResult struct {
+	ID     string          `json:"id,omitempty"`
+	Status int             `json:"status"`
+	Error  string          `json:"error,omitempty"`
+	Todo   *syncItemOutput `json:"todo,omitempty"`
+}
+
+func (r *syncResult) fail(status int, message string) {
+	r.Status, r.Error = status, message
+}
+
+// PushChanges handles applying the changes a client made offline. Every change is applied on
+// its own and gets a result: 200 or 201 when it was applied, 409 when the item was changed
+// since its base version, or the status of another failure.
+func (h *SyncHandler) PushChanges(c *gin.Context) {
+	ctx := c.Request.Context()
+	var input struct {
+		Changes []syncChange `json:"changes"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		helper.ResponseError(c, http.StatusBadRequest, "Invalid input", err)
+		return
+	}
+	if len(input.Changes) == 0 {
+		helper.ResponseError(c, http.StatusBadRequest, "changes are required", nil)
+		return
+	}
+	if len(input.Changes) > MaxBatchOperations {
+		helper.ResponseError(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("a push holds at most %d changes", MaxBatchOperations), nil)
+		return
+	}
+
+	results := make([]syncResult, len(input.Changes))
+	for i, change := range input.Changes {
+		results[i] = h.apply(ctx, change)
+	}
+	helper.SendSuccessResponse(c, http.StatusOK, gin.H{"results": results})
+}
+
+// apply writes a single change, returning its outcome
+func (h *SyncHandler) apply(ctx context.Context, change syncChange) syncResult {
+	var res syncResult
+	if change.ID != nil {
+		res.ID = change.ID.String()
+	}
+	in := service.SyncChange{ID: change.ID, BaseVersion: change.BaseVersion, Deleted: change.Deleted, Clocks: change.Clocks}
+	if change.Item != nil {
+		fields := change.Item.fields()
+		in.Item = &fields
+	}
+	todo, created, err := h.service.ApplyChange(ctx, in)
+	switch {
+	case err == nil && created:
+		res.Status = http.StatusCreated
+	case err == nil:
+		res.Status = http.StatusOK
+	case errors.Is(err, domain.ErrVersionConflict) || errors.Is(err, domain.ErrConflict):
+		res.fail(http.StatusConflict, "todo item was changed since its base version")
+	default:
+		res.fail(operationErrorStatus(err))
+	}
+	if todo != nil {
+		out := newSyncItemOutput(todo)
+		res.Todo = &out
+	}
+	return res
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taheri24/helitask/pkg/auth"
+	"github.com/taheri24/helitask/pkg/config"
+	"github.com/taheri24/helitask/pkg/domain"
+)
+
+// TestSync tests pulling the changes since a sync token and pushing offline changes
+func TestSync(t *testing.T) {
+	cfg := config.Default()
+	cfg.Auth.JWTSecret = "test-secret"
+	app, fxApp := setupAppWithConfig(t, "", cfg)
+	fxApp.RequireStart()
+	defer fxApp.RequireStop()
+	call := func(user, method, path, body string) (int, []byte) {
+		token, err := auth.SignHS256(auth.Claims{"sub": user, "workspace": "default"}, []byte(cfg.Auth.JWTSecret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, w := setupHTTP(method, path, body)
+		req.Header.Set("Authorization", "Bearer "+token)
+		app.ServeHTTP(w, req)
+		return w.Code, w.Body.Bytes()
+	}
+	create := func(user, description string) string {
+		status, body := call(user, "POST", "/api/v0/todo/", `{"description": "`+description+`", "due_date": "2025-03-01T10:00:00Z"}`)
+		assert.Equal(t, http.StatusCreated, status)
+		return extractJsonVal(body, "id")
+	}
+	type pulled struct {
+		Items      []todoOutput      `json:"items"`
+		Tombstones []tombstoneOutput `json:"tombstones"`
+		SyncToken  string            `json:"sync_token"`
+		HasMore    bool              `json:"has_more"`
+	}
+	pull := func(query string) pulled {
+		status, body := call("alice", "GET", "/api/v0/sync"+query, "")
+		assert.Equal(t, http.StatusOK, status, string(body))
+		var out pulled
+		assert.NoError(t, json.Unmarshal(body, &out))
+		return out
+	}
+	descriptions := func(items []todoOutput) []string {
+		var out []string
+		for _, item := range items {
+			out = append(out, item.Description)
+		}
+		return out
+	}
+
+	first, second := create("alice", "First"), create("alice", "Second")
+	create("bob", "Private to bob")
+	page := pull("?limit=1")
+	assert.Equal(t, []string{"First"}, descriptions(page.Items))
+	assert.True(t, page.HasMore)
+	page = pull("?limit=5&since=" + page.SyncToken)
+	assert.Equal(t, []string{"Second"}, descriptions(page.Items), "the items of bob are left out")
+	assert.False(t, page.HasMore)
+	token := page.SyncToken
+	assert.Empty(t, pull("?since="+token).Items, "nothing changed since the last pull")
+
+	status, _ := call("alice", "PUT", "/api/v0/todo/"+first, `{"description": "First, edited", "due_date": "2025-03-01T10:00:00Z"}`)
+	assert.Equal(t, http.StatusOK, status)
+	status, _ = call("alice", "DELETE", "/api/v0/todo/"+second, "")
+	assert.Equal(t, http.StatusNoContent, status)
+	page = pull("?since=" + token)
+	assert.Equal(t, []string{"First, edited"}, descriptions(page.Items))
+	assert.Equal(t, int64(2), page.Items[0].Version)
+	if assert.Len(t, page.Tombstones, 1) {
+		assert.Equal(t, second, page.Tombstones[0].ID)
+	}
+	assert.Empty(t, pull("").Tombstones, "a first sync gets no tombstones")
+
+	status, _ = call("alice", "GET", "/api/v0/sync?since=nope", "")
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	offline := domain.NewUUID().String()
+	status, body := call("alice", "POST", "/api/v0/sync", `{"changes": [
+		{"id": "`+offline+`", "item": {"description": "Made offline", "due_date": "2025-03-02T10:00:00Z"}},
+		{"id": "`+first+`", "base_version": 2, "item": {"description": "First, edited offline", "due_date": "2025-03-01T10:00:00Z", "completed": true}},
+		{"id": "`+first+`", "base_version": 2, "item": {"description": "First, edited on another device", "due_date": "2025-03-01T10:00:00Z"}},
+		{"id": "`+second+`", "base_version": 1, "deleted": true},
+		{"item": {"description": "No id", "due_date": "2025-03-01T10:00:00Z"}},
+		{"id": "`+domain.NewUUID().String()+`", "base_version": 4, "item": {"description": "Unknown", "due_date": "2025-03-01T10:00:00Z"}}
+	]}`)
+	assert.Equal(t, http.StatusOK, status, string(body))
+	var pushed struct {
+		Results []syncResult `json:"results"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &pushed))
+	var statuses []int
+	for _, res := range pushed.Results {
+		statuses = append(statuses, res.Status)
+	}
+	assert.Equal(t, []int{http.StatusCreated, http.StatusOK, http.StatusConflict, http.StatusOK, http.StatusBadRequest, http.StatusNotFound}, statuses)
+	assert.Equal(t, int64(3), pushed.Results[1].Todo.Version)
+	assert.Equal(t, "First, edited offline", pushed.Results[2].Todo.Description, "a conflict comes with the current item")
+	assert.NotEmpty(t, pushed.Results[3].Todo.DeletedAt, "deleting a deleted item changes nothing")
+
+	page = pull("?limit=2&since=" + page.SyncToken)
+	assert.Equal(t, []string{"Made offline", "First, edited offline"}, descriptions(page.Items))
+	assert.False(t, page.HasMore, "a full last page has no more changes after it")
+}
+
+// TestSyncMerge tests that concurrent offline edits of different fields of an item both survive
+func TestSyncMerge(t *testing.T) {
+	app, fxApp := setupApp(t, "")
+	fxApp.RequireStart()
+	defer fxApp.RequireStop()
+	push := func(body string) syncResult {
+		req, w := setupHTTP("POST", "/api/v0/sync", body)
+		app.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var out struct {
+			Results []syncResult `json:"results"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &out))
+		return out.Results[0]
+	}
+	now := time.Now().UnixMilli()
+	clock := func(offset int64, node string) string {
+		return domain.HLC{Wall: now + offset, Node: node}.String()
+	}
+
+	req, w := setupHTTP("POST", "/api/v0/todo/", `{"description": "Shared", "due_date": "2025-03-01T10:00:00Z"}`)
+	app.ServeHTTP(w, req)
+	id := extractJsonVal(w.Body.Bytes(), "id")
+
+	// the phone completes the item and the laptop renames it, both from version 1
+	phone := push(`{"changes": [{"id": "` + id + `", "base_version": 1,
+		"item": {"description": "Shared", "due_date": "2025-03-01T10:00:00Z", "completed": true},
+		"clocks": {"completed": "` + clock(1000, "phone") + `"}}]}`)
+	assert.Equal(t, http.StatusOK, phone.Status)
+	laptop := push(`{"changes": [{"id": "` + id + `", "base_version": 1,
+		"item": {"description": "Renamed", "due_date": "2025-03-01T10:00:00Z", "completed": false},
+		"clocks": {"description": "` + clock(2000, "laptop") + `", "completed": "` + clock(-1000, "laptop") + `"}}]}`)
+	assert.Equal(t, http.StatusOK, laptop.Status, "a merge is no conflict")
+	assert.Equal(t, "Renamed", laptop.Todo.Description)
+	assert.True(t, laptop.Todo.Completed, "the earlier reopening by the laptop loses to the phone")
+	assert.Equal(t, int64(3), laptop.Todo.Version)
+	assert.Equal(t, "phone", laptop.Todo.Clocks[domain.FieldCompleted].Node)
+
+	stale := push(`{"changes": [{"id": "` + id + `", "item": {"description": "Old", "due_date": "2025-03-01T10:00:00Z"},
+		"clocks": {"description": "` + clock(-5000, "watch") + `"}}]}`)
+	assert.Equal(t, http.StatusOK, stale.Status)
+	assert.Equal(t, int64(3), stale.Todo.Version, "a write older than every field changes nothing")
+
+	future := push(`{"changes": [{"id": "` + id + `", "item": {"description": "Future", "due_date": "2025-03-01T10:00:00Z"},
+		"clocks": {"description": "` + clock(int64(time.Hour/time.Millisecond), "watch") + `"}}]}`)
+	assert.Equal(t, http.StatusBadRequest, future.Status, "clocks far ahead of the server are refused")
+
+	// a later edit through the REST API moves the clock of the field it changes
+	req, w = setupHTTP("PUT", "/api/v0/todo/"+id, `{"description": "Renamed on the web", "due_date": "2025-03-01T10:00:00Z", "completed": true}`)
+	app.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	late := push(`{"changes": [{"id": "` + id + `", "item": {"description": "Laptop again", "due_date": "2025-03-01T10:00:00Z"},
+		"clocks": {"description": "` + clock(2000, "laptop2") + `"}}]}`)
+	assert.Equal(t, "Renamed on the web", late.Todo.Description, "the server clock passed the clocks it saw")
+}
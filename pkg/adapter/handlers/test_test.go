@@ -10,18 +10,27 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/spyzhov/ajson"
+	"github.com/taheri24/helitask/pkg/config"
+	"github.com/taheri24/helitask/pkg/events"
 	"github.com/taheri24/helitask/pkg/logger"
+	"github.com/taheri24/helitask/pkg/policy"
 	"github.com/taheri24/helitask/pkg/ports/storage"
 	"github.com/taheri24/helitask/pkg/ports/storage/sqlite"
+	"github.com/taheri24/helitask/pkg/service"
 	"go.uber.org/fx"
 	"go.uber.org/fx/fxtest"
 )
 
 func setupApp(t *testing.T, datasetFn string) (*gin.Engine, *fxtest.App) {
+	return setupAppWithConfig(t, datasetFn, config.Default())
+}
+
+func setupAppWithConfig(t *testing.T, datasetFn string, cfg *config.Config, options ...fx.Option) (*gin.Engine, *fxtest.App) {
 	db, app := sqlite.NewDb(t, datasetFn).Debug(), gin.New()
 	app.Use(handlerNameInHeader)
-	fxApp := fxtest.New(t, fx.NopLogger, fx.Provide(logger.Nop), fx.Supply(db, app), storage.Module, Module)
-	return app, fxApp
+	options = append([]fx.Option{fx.NopLogger, fx.Provide(logger.Nop), fx.Supply(db, app, cfg),
+		storage.Module, storage.CacheModule, policy.Module, service.Module, events.Module, Module}, options...)
+	return app, fxtest.New(t, options...)
 }
 
 func setupHTTP(httpMethod, path, body string) (*http.Request, *httptest.ResponseRecorder) {
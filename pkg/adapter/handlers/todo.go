@@ -1,36 +1,58 @@
 package handlers
 
 import (
+	"context"
 	"errors"
-	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/taheri24/helitask/pkg/domain"
-)
-
-const (
-	// MaxDescriptionLength is the maximum allowed length for todo item descriptions
-	MaxDescriptionLength = 1000
-	// MinDescriptionLength is the minimum required length for todo item descriptions
-	MinDescriptionLength = 1
+	"github.com/taheri24/helitask/pkg/service"
 )
 
 // TodoHandler struct for HTTP requests
 type TodoHandler struct {
-	repository domain.TodoRepository
+	service *service.TodoService
+}
+
+// todoInput is the representation of a todo item accepted from clients
+type todoInput struct {
+	ID          *domain.UUID `json:"id"`
+	Description string       `json:"description"`
+	DueDate     time.Time    `json:"due_date"`
+	ListID      *domain.UUID `json:"list_id"`
+	Completed   bool         `json:"completed"`
+}
+
+// fields returns the fields of the item in, for the todo service
+func (in *todoInput) fields() service.TodoInput {
+	return service.TodoInput{ListID: in.ListID, Description: in.Description, DueDate: in.DueDate, Completed: in.Completed}
+}
+
+// todoOutput is the representation of a todo item sent to clients
+type todoOutput struct {
+	ID          string       `json:"id"`
+	ListID      *domain.UUID `json:"list_id,omitempty"`
+	Description string       `json:"description"`
+	DueDate     string       `json:"due_date"`
+	Completed   bool         `json:"completed"`
+	Version     int64        `json:"version"`
+	DeletedAt   string       `json:"deleted_at,omitempty"`
+}
+
+func newTodoOutput(dao *domain.TodoItem) todoOutput {
+	out := todoOutput{ID: dao.ID.String(), ListID: dao.ListID, Description: dao.Description, DueDate: dao.DueDate.String(), Completed: dao.Completed, Version: dao.Version}
+	if dao.DeletedAt.Valid {
+		out.DeletedAt = dao.DeletedAt.Time.String()
+	}
+	return out
 }
 
 // CreateTodoItem handles creating a new TodoItem
 func (h *TodoHandler) CreateTodoItem(c *gin.Context) {
 	logger := helper.GetLogger(c)
-
-	ctx := c.Request.Context()
-	var input struct {
-		Description string    `json:"description"`
-		DueDate     time.Time `json:"due_date"`
-	}
+	var input todoInput
 
 	logger.Verbose("Received request to create TodoItem")
 
@@ -38,62 +60,143 @@ func (h *TodoHandler) CreateTodoItem(c *gin.Context) {
 		helper.ResponseError(c, http.StatusBadRequest, "Invalid input", err)
 		return
 	}
+	todo, err := h.service.Create(c.Request.Context(), input.ID, input.fields())
+	if err != nil {
+		h.responseServiceError(c, "Failed to save todo item", err)
+		return
+	}
+	logger.Verbose("Created TodoItem with ID:", todo.ID)
+	helper.SendCreatedResponse(c, todo.ID.String())
+}
 
-	// Validate description
-	if len(input.Description) < MinDescriptionLength {
-		helper.ResponseError(c, http.StatusBadRequest, "description is required", nil)
+// PutTodoItem handles creating or replacing the TodoItem with the ID of the path.
+// It responds 201 when the item was created and 200 when it was replaced.
+func (h *TodoHandler) PutTodoItem(c *gin.Context) {
+	id, err := domain.ParseUUID(c.Param("id"))
+	if err != nil {
+		helper.ResponseError(c, http.StatusBadRequest, "Invalid UUID", err)
+		return
+	}
+
+	var input todoInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		helper.ResponseError(c, http.StatusBadRequest, "Invalid input", err)
 		return
 	}
-	if len(input.Description) > MaxDescriptionLength {
-		helper.ResponseError(c, http.StatusBadRequest, "description exceeds maximum length", nil)
+	if input.ID != nil && *input.ID != id {
+		helper.ResponseError(c, http.StatusBadRequest, "id does not match the path", nil)
 		return
 	}
 
-	// Validate due_date (must not be zero time)
-	if input.DueDate.IsZero() {
-		helper.ResponseError(c, http.StatusBadRequest, "due_date is required", nil)
+	todo, created, err := h.service.Put(c.Request.Context(), id, input.fields())
+	if err != nil {
+		h.responseServiceError(c, "Failed to save todo item", err)
+		return
+	}
+	if created {
+		helper.SendCreatedResponse(c, todo.ID.String())
 		return
 	}
+	helper.SendSuccessResponse(c, http.StatusOK, newTodoOutput(todo))
+}
 
-	todo := domain.TodoItem{
-		ID:          domain.NewUUID(),
-		Description: input.Description,
-		DueDate:     input.DueDate,
+// responseServiceError maps the errors of the todo service onto responses, naming the failed
+// operation in message
+func (h *TodoHandler) responseServiceError(c *gin.Context, message string, err error) {
+	if errors.Is(err, service.ErrInvalidInput) {
+		helper.ResponseError(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	if helper.ResponseAuthError(c, err) {
+		return
 	}
+	status, message := serviceErrorStatus(err, message)
+	if status == http.StatusConflict {
+		err = nil
+	}
+	helper.ResponseError(c, status, message, err)
+}
 
-	logger.Verbose("Creating TodoItem with ID:", todo.ID)
+// saveErrorStatus returns the status code and message answering a failed repository write
+func saveErrorStatus(err error) (int, string) {
+	return serviceErrorStatus(err, "Failed to save todo item")
+}
 
-	if err := h.repository.Create(ctx, &todo); err != nil {
-		wrappedErr := fmt.Errorf("failed to save todo item: %w", err)
-		helper.ResponseError(c, http.StatusInternalServerError, "Failed to save todo item", wrappedErr)
-		return
+// serviceErrorStatus returns the status code and message answering a failed operation,
+// whose unexpected failures and timeouts are told with failure
+func serviceErrorStatus(err error, failure string) (int, string) {
+	switch {
+	case errors.Is(err, domain.ErrConflict):
+		return http.StatusConflict, "a todo item with this id already exists"
+	case errors.Is(err, domain.ErrVersionConflict):
+		return http.StatusConflict, "todo item was changed since this version"
+	case errors.Is(err, domain.ErrRecordNotFound):
+		return http.StatusNotFound, "record not found"
+	case errors.Is(err, domain.ErrTodoQuotaExceeded):
+		return http.StatusForbidden, "Workspace quota exceeded"
+	case errors.Is(err, domain.ErrDescriptionTooLarge):
+		return http.StatusRequestEntityTooLarge, "Description is too large for the workspace"
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout, failure + " in time"
 	}
-	helper.SendCreatedResponse(c, todo.ID.String())
+	return http.StatusInternalServerError, failure
+}
+
+// operationErrorStatus returns the status code and message of a failed operation of a batch
+// or of a sync push, which are answered within the response of the request
+func operationErrorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, service.ErrInvalidInput):
+		return http.StatusBadRequest, err.Error()
+	case errors.Is(err, domain.ErrUnauthenticated):
+		return http.StatusUnauthorized, err.Error()
+	case errors.Is(err, domain.ErrForbidden):
+		return http.StatusForbidden, err.Error()
+	}
+	return saveErrorStatus(err)
 }
 
-// GetTodoItem handles retrieving a TodoItem by ID
+// GetTodoItem handles retrieving a TodoItem by ID.
+// Items in the trash are only found with the include_deleted=true query parameter, and the
+// as_of query parameter reads the item as it was at an earlier time.
 func (h *TodoHandler) GetTodoItem(c *gin.Context) {
 	ctx := c.Request.Context()
-	key := c.Param("id")
-	uuid, err := domain.ParseUUID(key)
+	if c.Query("include_deleted") == "true" {
+		ctx = domain.WithDeleted(ctx)
+	}
+	id, err := domain.ParseUUID(c.Param("id"))
 	if err != nil {
 		helper.ResponseError(c, http.StatusBadRequest, "Invalid UUID", err)
 		return
 	}
-	dao, err := h.repository.GetByID(ctx, domain.UUID(uuid))
-	if err != nil {
-		if errors.Is(err, domain.ErrRecordNotFound) {
-			helper.ResponseError(c, http.StatusNotFound, "record not found", err)
+	var todo *domain.TodoItem
+	if asOf := c.Query("as_of"); asOf != "" {
+		at, parseErr := time.Parse(time.RFC3339, asOf)
+		if parseErr != nil {
+			helper.ResponseError(c, http.StatusBadRequest, "as_of must be an RFC 3339 time", parseErr)
 			return
 		}
-		helper.ResponseError(c, http.StatusInternalServerError, "Failed to fetch todo item", err)
+		todo, err = h.service.GetAsOf(ctx, id, at)
+	} else {
+		todo, err = h.service.Get(ctx, id)
+	}
+	if err != nil {
+		h.responseServiceError(c, "Failed to fetch todo item", err)
 		return
 	}
-	var output = struct {
-		ID          string `json:"id"`
-		Description string `json:"description"`
-		DueDate     string `json:"due_date"`
-	}{dao.ID.String(), dao.Description, dao.DueDate.String()}
+	helper.SendSuccessResponse(c, http.StatusOK, newTodoOutput(todo))
+}
 
-	helper.SendSuccessResponse(c, http.StatusOK, output)
+// DeleteTodoItem handles moving a TodoItem to the trash by ID
+func (h *TodoHandler) DeleteTodoItem(c *gin.Context) {
+	id, err := domain.ParseUUID(c.Param("id"))
+	if err != nil {
+		helper.ResponseError(c, http.StatusBadRequest, "Invalid UUID", err)
+		return
+	}
+	if err := h.service.Delete(c.Request.Context(), id); err != nil {
+		h.responseServiceError(c, "Failed to delete todo item", err)
+		return
+	}
+	c.Status(http.StatusNoContent)
 }
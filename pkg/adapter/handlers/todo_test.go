@@ -1,12 +1,17 @@
 package handlers
 
 import (
+	"encoding/json"
+	"expvar"
 	"fmt"
 	"net/http"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/taheri24/helitask/pkg/config"
+	"github.com/taheri24/helitask/pkg/service"
 )
 
 // TestCreateTodoItem tests the CreateTodoItem handler
@@ -66,7 +71,7 @@ func TestCreateTodoItemValidation(t *testing.T) {
 		},
 		{
 			name:           "Description too long",
-			input:          fmt.Sprintf(`{"description": "%s", "due_date": "2025-12-31T23:59:59Z"}`, strings.Repeat("a", MaxDescriptionLength+1)),
+			input:          fmt.Sprintf(`{"description": "%s", "due_date": "2025-12-31T23:59:59Z"}`, strings.Repeat("a", service.MaxDescriptionLength+1)),
 			expectedStatus: http.StatusBadRequest,
 			errorContains:  "description exceeds maximum length",
 		},
@@ -96,7 +101,7 @@ func TestCreateTodoItemValidation(t *testing.T) {
 		},
 		{
 			name:           "Description at maximum length",
-			input:          fmt.Sprintf(`{"description": "%s", "due_date": "2025-12-31T23:59:59Z"}`, strings.Repeat("a", MaxDescriptionLength)),
+			input:          fmt.Sprintf(`{"description": "%s", "due_date": "2025-12-31T23:59:59Z"}`, strings.Repeat("a", service.MaxDescriptionLength)),
 			expectedStatus: http.StatusCreated,
 			errorContains:  "",
 		},
@@ -201,3 +206,140 @@ func TestGetTodoItem(t *testing.T) {
 	})
 
 }
+
+// TestCreateTodoItemWithClientID tests creating todo items with identifiers generated by clients
+func TestCreateTodoItemWithClientID(t *testing.T) {
+	app, fxApp := setupApp(t, "")
+	fxApp.RequireStart()
+	defer fxApp.RequireStop()
+
+	type TestCase struct {
+		name           string
+		workspace      string
+		id             string
+		expectedStatus int
+	}
+	testCases := []TestCase{
+		{"Client id", "", "6f1d2c3b-4a5e-4f60-8a7b-9c0d1e2f3a4b", http.StatusCreated},
+		{"Duplicate client id", "", "6f1d2c3b-4a5e-4f60-8a7b-9c0d1e2f3a4b", http.StatusConflict},
+		{"Same client id in another workspace", "team-a", "6f1d2c3b-4a5e-4f60-8a7b-9c0d1e2f3a4b", http.StatusCreated},
+		{"Nil UUID", "", "00000000-0000-0000-0000-000000000000", http.StatusBadRequest},
+		{"Invalid UUID", "", "not-a-uuid", http.StatusBadRequest},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			input := fmt.Sprintf(`{"id": "%s", "description": "Test Todo", "due_date": "2025-12-31T23:59:59Z"}`, tc.id)
+			req, w := setupHTTP("POST", "/api/v0/todo/", input)
+			if tc.workspace != "" {
+				req.Header.Set("X-Workspace-ID", tc.workspace)
+			}
+			app.ServeHTTP(w, req)
+			if !assert.Equal(t, tc.expectedStatus, w.Code) {
+				t.Log(w.Body.String())
+			}
+			if w.Code == http.StatusCreated {
+				assert.Equal(t, tc.id, extractJsonVal(w.Body.Bytes(), "id"))
+			}
+		})
+	}
+}
+
+// TestPutTodoItem tests the create-or-replace semantics of PutTodoItem
+func TestPutTodoItem(t *testing.T) {
+	app, fxApp := setupApp(t, "sample1.sql")
+	fxApp.RequireStart()
+	defer fxApp.RequireStop()
+	h := &TodoHandler{}
+
+	type TestCase struct {
+		name           string
+		workspace      string
+		uuid           string
+		input          string
+		expectedStatus int
+	}
+	testCases := []TestCase{
+		{"Replace existing", "", "3f6c1a4e-9966-4f1c-a2a9-1b8df67f8cc3", `{"description": "Buy more groceries", "due_date": "2025-03-02T10:00:00Z"}`, http.StatusOK},
+		{"Create missing", "", "5b7e0a52-4c1f-4d2e-9f8a-6b3c2d1e0f9a", `{"description": "Water plants", "due_date": "2025-03-04T08:00:00Z"}`, http.StatusCreated},
+		{"Replace created", "", "5b7e0a52-4c1f-4d2e-9f8a-6b3c2d1e0f9a", `{"description": "Water the plants", "due_date": "2025-03-04T08:00:00Z"}`, http.StatusOK},
+		{"Id taken in another workspace", "team-a", "3f6c1a4e-9966-4f1c-a2a9-1b8df67f8cc3", `{"description": "Buy groceries", "due_date": "2025-03-02T10:00:00Z"}`, http.StatusCreated},
+		{"Mismatching body id", "", "3f6c1a4e-9966-4f1c-a2a9-1b8df67f8cc3", `{"id": "5b7e0a52-4c1f-4d2e-9f8a-6b3c2d1e0f9a", "description": "Buy groceries", "due_date": "2025-03-02T10:00:00Z"}`, http.StatusBadRequest},
+		{"Invalid input", "", "3f6c1a4e-9966-4f1c-a2a9-1b8df67f8cc3", `{"description": "", "due_date": "2025-03-02T10:00:00Z"}`, http.StatusBadRequest},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, w := setupHTTP("PUT", "/api/v0/todo/"+tc.uuid, tc.input)
+			if tc.workspace != "" {
+				req.Header.Set("X-Workspace-ID", tc.workspace)
+			}
+			app.ServeHTTP(w, req)
+			if !assert.Equal(t, tc.expectedStatus, w.Code) {
+				t.Log(w.Body.String())
+			}
+			assert.Equal(t, w.Header().Get("X-Handler-Name"), extractFuncShortName(h.PutTodoItem))
+		})
+	}
+
+	req, w := setupHTTP("GET", "/api/v0/todo/5b7e0a52-4c1f-4d2e-9f8a-6b3c2d1e0f9a", "")
+	app.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "Water the plants", extractJsonVal(w.Body.Bytes(), "description"))
+}
+
+// TestTodoItemTimeout tests that queries exceeding their timeout are answered with a 504 naming
+// the operation that failed
+func TestTodoItemTimeout(t *testing.T) {
+	cfg := config.Default()
+	cfg.DB.ReadTimeout = time.Nanosecond
+	app, fxApp := setupAppWithConfig(t, "sample1.sql", cfg)
+	fxApp.RequireStart()
+	defer fxApp.RequireStop()
+
+	for method, message := range map[string]string{"GET": "Failed to fetch todo item in time", "DELETE": "Failed to delete todo item in time"} {
+		req, w := setupHTTP(method, "/api/v0/todo/3f6c1a4e-9966-4f1c-a2a9-1b8df67f8cc3", "")
+		app.ServeHTTP(w, req)
+		if !assert.Equal(t, http.StatusGatewayTimeout, w.Code) {
+			t.Log(w.Body.String())
+		}
+		assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+		assert.True(t, strings.HasPrefix(extractJsonVal(w.Body.Bytes(), "error"), message), w.Body.String())
+	}
+}
+
+// TestTodoItemCache tests that reads are cached and that writes through the API invalidate them
+func TestTodoItemCache(t *testing.T) {
+	app, fxApp := setupApp(t, "")
+	fxApp.RequireStart()
+	defer fxApp.RequireStop()
+	cacheStats := func() (hits, misses int) {
+		var stats struct{ Hits, Misses int }
+		assert.NoError(t, json.Unmarshal([]byte(expvar.Get("todo_cache").String()), &stats))
+		return stats.Hits, stats.Misses
+	}
+	req, w := setupHTTP("GET", "/debug/vars", "")
+	app.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code, "the variables are only served by the admin server")
+	get := func(id string) string {
+		req, w := setupHTTP("GET", "/api/v0/todo/"+id, "")
+		app.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		return extractJsonVal(w.Body.Bytes(), "description")
+	}
+
+	req, w = setupHTTP("POST", "/api/v0/todo/", `{"description": "Cached Todo", "due_date": "2025-12-31T23:59:59Z"}`)
+	app.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	id := extractJsonVal(w.Body.Bytes(), "id")
+
+	hits, misses := cacheStats()
+	assert.Equal(t, "Cached Todo", get(id))
+	assert.Equal(t, "Cached Todo", get(id))
+	newHits, newMisses := cacheStats()
+	assert.Equal(t, 1, newHits-hits)
+	assert.Equal(t, 1, newMisses-misses)
+
+	req, w = setupHTTP("PUT", "/api/v0/todo/"+id, `{"description": "Updated Todo", "due_date": "2025-12-31T23:59:59Z"}`)
+	app.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "Updated Todo", get(id))
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/taheri24/helitask/pkg/domain"
+	"github.com/taheri24/helitask/pkg/service"
+)
+
+// parsePage reads the limit and offset query parameters, answering the request when they are invalid
+func parsePage(c *gin.Context) (domain.Page, bool) {
+	page := domain.Page{Limit: domain.DefaultPageSize}
+	var err error
+	if v := c.Query("limit"); v != "" {
+		if page.Limit, err = strconv.Atoi(v); err != nil || page.Limit < 1 || page.Limit > domain.MaxPageSize {
+			helper.ResponseError(c, http.StatusBadRequest, "limit must be between 1 and "+strconv.Itoa(domain.MaxPageSize), nil)
+			return page, false
+		}
+	}
+	if v := c.Query("offset"); v != "" {
+		if page.Offset, err = strconv.Atoi(v); err != nil || page.Offset < 0 {
+			helper.ResponseError(c, http.StatusBadRequest, "offset must not be negative", nil)
+			return page, false
+		}
+	}
+	return page, true
+}
+
+// ListTrash handles listing the deleted TodoItems the caller may read, most recently deleted first
+func (h *TodoHandler) ListTrash(c *gin.Context) {
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+	deleted, err := h.service.ListDeleted(c.Request.Context(), page)
+	if err != nil {
+		h.responseServiceError(c, "Failed to list deleted todo items", err)
+		return
+	}
+	helper.SendSuccessResponse(c, http.StatusOK, newTodoPageOutput(deleted))
+}
+
+// newTodoPageOutput is the representation of a page of a listing sent to clients
+func newTodoPageOutput(page *service.TodoPage) gin.H {
+	items := make([]todoOutput, len(page.Items))
+	for i, todo := range page.Items {
+		items[i] = newTodoOutput(todo)
+	}
+	out := gin.H{"items": items}
+	if page.More {
+		out["next_offset"] = page.Next
+	}
+	return out
+}
+
+// RestoreTodoItem handles taking a TodoItem out of the trash
+func (h *TodoHandler) RestoreTodoItem(c *gin.Context) {
+	id, err := domain.ParseUUID(c.Param("id"))
+	if err != nil {
+		helper.ResponseError(c, http.StatusBadRequest, "Invalid UUID", err)
+		return
+	}
+	todo, err := h.service.Restore(c.Request.Context(), id)
+	if errors.Is(err, domain.ErrRecordNotFound) {
+		helper.ResponseError(c, http.StatusNotFound, "record not found in trash", nil)
+		return
+	} else if err != nil {
+		h.responseServiceError(c, "Failed to restore todo item", err)
+		return
+	}
+	helper.SendSuccessResponse(c, http.StatusOK, newTodoOutput(todo))
+}
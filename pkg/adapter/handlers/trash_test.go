@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taheri24/helitask/pkg/auth"
+	"github.com/taheri24/helitask/pkg/config"
+)
+
+// TestTodoTrash tests deleting todo items into the trash and restoring them
+func TestTodoTrash(t *testing.T) {
+	cfg := config.Default()
+	cfg.Auth.JWTSecret = "test-secret"
+	app, fxApp := setupAppWithConfig(t, "", cfg)
+	fxApp.RequireStart()
+	defer fxApp.RequireStop()
+	tokenOf := func(user string) string {
+		token, err := auth.SignHS256(auth.Claims{"sub": user, "workspace": "default"}, []byte(cfg.Auth.JWTSecret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return "Bearer " + token
+	}
+	doWithBody := func(method, path, user, body string) (int, []byte) {
+		req, w := setupHTTP(method, path, body)
+		req.Header.Set("Authorization", tokenOf(user))
+		app.ServeHTTP(w, req)
+		return w.Code, w.Body.Bytes()
+	}
+	do := func(method, path, user string) (int, []byte) {
+		return doWithBody(method, path, user, "")
+	}
+
+	_, body := doWithBody("POST", "/api/v0/todo/", "alice", `{"description": "Trashed Todo", "due_date": "2025-12-31T23:59:59Z"}`)
+	id := extractJsonVal(body, "id")
+	status, _ := do("DELETE", "/api/v0/todo/"+id, "alice")
+	assert.Equal(t, http.StatusNoContent, status)
+
+	status, _ = do("GET", "/api/v0/todo/"+id, "alice")
+	assert.Equal(t, http.StatusNotFound, status)
+	status, body = do("GET", "/api/v0/todo/"+id+"?include_deleted=true", "alice")
+	if assert.Equal(t, http.StatusOK, status) {
+		assert.NotEmpty(t, extractJsonVal(body, "deleted_at"))
+	}
+
+	status, body = do("GET", "/api/v0/todo/trash", "alice")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, string(body), id)
+	status, body = do("GET", "/api/v0/todo/trash", "bob")
+	assert.Equal(t, http.StatusOK, status)
+	assert.NotContains(t, string(body), id, "the trash only lists items the caller may read")
+	status, _ = do("GET", "/api/v0/todo/trash?limit=1000", "alice")
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, _ = do("POST", "/api/v0/todo/"+id+"/restore", "bob")
+	assert.Equal(t, http.StatusForbidden, status)
+	status, _ = do("POST", "/api/v0/todo/"+id+"/restore", "alice")
+	assert.Equal(t, http.StatusOK, status)
+	status, _ = do("POST", "/api/v0/todo/"+id+"/restore", "alice")
+	assert.Equal(t, http.StatusNotFound, status)
+	status, body = do("GET", "/api/v0/todo/"+id, "alice")
+	if assert.Equal(t, http.StatusOK, status) {
+		assert.NotContains(t, string(body), "deleted_at")
+	}
+}
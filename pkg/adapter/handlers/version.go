@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/taheri24/helitask/pkg/domain"
+	"github.com/taheri24/helitask/pkg/service"
+)
+
+// revertInput is the body of a revert request
+type revertInput struct {
+	Version int64 `json:"version" binding:"required,min=1"`
+}
+
+// RevertTodoItem handles restoring the fields of an earlier version of a TodoItem.
+// The revert is itself recorded as a new version.
+func (h *TodoHandler) RevertTodoItem(c *gin.Context) {
+	id, err := domain.ParseUUID(c.Param("id"))
+	if err != nil {
+		helper.ResponseError(c, http.StatusBadRequest, "Invalid UUID", err)
+		return
+	}
+	var input revertInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		helper.ResponseError(c, http.StatusBadRequest, "Invalid input", err)
+		return
+	}
+	reverted, err := h.service.Revert(c.Request.Context(), id, input.Version)
+	switch {
+	case errors.Is(err, service.ErrVersionNotFound):
+		helper.ResponseError(c, http.StatusNotFound, "version not found", nil)
+		return
+	case errors.Is(err, domain.ErrDeletedVersion):
+		helper.ResponseError(c, http.StatusUnprocessableEntity, "cannot revert to a deleted version", nil)
+		return
+	case err != nil:
+		h.responseServiceError(c, "Failed to save todo item", err)
+		return
+	}
+	helper.SendSuccessResponse(c, http.StatusOK, newTodoOutput(reverted))
+}
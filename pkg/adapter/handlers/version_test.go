@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taheri24/helitask/pkg/auth"
+	"github.com/taheri24/helitask/pkg/config"
+)
+
+// TestTodoVersions tests reading a todo item as of an earlier time and reverting it to an earlier version
+func TestTodoVersions(t *testing.T) {
+	cfg := config.Default()
+	cfg.Auth.JWTSecret = "test-secret"
+	app, fxApp := setupAppWithConfig(t, "", cfg)
+	fxApp.RequireStart()
+	defer fxApp.RequireStop()
+	do := func(method, path, user, body string) *httptest.ResponseRecorder {
+		req, w := setupHTTP(method, path, body)
+		token, err := auth.SignHS256(auth.Claims{"sub": user, "workspace": "default"}, []byte(cfg.Auth.JWTSecret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		app.ServeHTTP(w, req)
+		return w
+	}
+	now := func() string { return url.QueryEscape(time.Now().Format(time.RFC3339Nano)) }
+
+	w := do("POST", "/api/v0/todo/", "alice", `{"description": "First", "due_date": "2025-03-01T10:00:00Z"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	id := extractJsonVal(w.Body.Bytes(), "id")
+	afterCreate := now()
+	w = do("PUT", "/api/v0/todo/"+id, "alice", `{"description": "Second", "due_date": "2025-03-01T10:00:00Z"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"version":2`)
+
+	w = do("GET", "/api/v0/todo/"+id+"?as_of="+afterCreate, "alice", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "First", extractJsonVal(w.Body.Bytes(), "description"))
+	assert.Contains(t, w.Body.String(), `"version":1`)
+	w = do("GET", "/api/v0/todo/"+id+"?as_of=2000-01-01T00:00:00Z", "alice", "")
+	assert.Equal(t, http.StatusNotFound, w.Code, "the item did not exist yet")
+	w = do("GET", "/api/v0/todo/"+id+"?as_of=yesterday", "alice", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = do("GET", "/api/v0/todo/"+id+"?as_of="+afterCreate, "bob", "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = do("POST", "/api/v0/todo/"+id+"/revert", "bob", `{"version": 1}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = do("POST", "/api/v0/todo/"+id+"/revert", "alice", `{"version": 7}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = do("POST", "/api/v0/todo/"+id+"/revert", "alice", `{"version": 1}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "First", extractJsonVal(w.Body.Bytes(), "description"))
+	assert.Contains(t, w.Body.String(), `"version":3`)
+
+	w = do("GET", "/api/v0/todo/"+id+"/history?limit=1", "alice", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"action":"reverted"`)
+
+	w = do("DELETE", "/api/v0/todo/"+id, "alice", "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	w = do("POST", "/api/v0/todo/"+id+"/restore", "alice", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = do("POST", "/api/v0/todo/"+id+"/revert", "alice", `{"version": 4}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code, "version 4 is the deleted item")
+}
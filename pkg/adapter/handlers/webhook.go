@@ -0,0 +1,287 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/taheri24/helitask/pkg/config"
+	"github.com/taheri24/helitask/pkg/domain"
+	"github.com/taheri24/helitask/pkg/webhooks"
+)
+
+// WebhookHandler struct for HTTP requests on webhooks and their deliveries
+type WebhookHandler struct {
+	webhooks   domain.WebhookRepository
+	deliveries domain.WebhookDeliveryRepository
+	policy     domain.Policy
+	cfg        config.WebhookConfig
+}
+
+// webhookInput is the representation of a webhook accepted from clients
+type webhookInput struct {
+	URL    string             `json:"url"`
+	Events []domain.EventType `json:"events"`
+	// Secret is generated when a webhook is created without one, and kept when an update omits it
+	Secret string `json:"secret"`
+	Active *bool  `json:"active"`
+}
+
+// validate checks the URL and the event types of the input
+func (in *webhookInput) validate(cfg config.WebhookConfig) error {
+	if err := webhooks.ValidateURL(in.URL, cfg); err != nil {
+		return err
+	}
+	for _, eventType := range in.Events {
+		if !eventType.Valid() {
+			return fmt.Errorf("unknown event type %q", eventType)
+		}
+	}
+	return nil
+}
+
+// webhookOutput is the representation of a webhook sent to clients; the secret is only sent on creation
+type webhookOutput struct {
+	ID        string             `json:"id"`
+	URL       string             `json:"url"`
+	Events    []domain.EventType `json:"events"`
+	Active    bool               `json:"active"`
+	Secret    string             `json:"secret,omitempty"`
+	CreatedAt string             `json:"created_at"`
+}
+
+func newWebhookOutput(webhook *domain.Webhook) webhookOutput {
+	events := webhook.Events
+	if events == nil {
+		events = []domain.EventType{}
+	}
+	return webhookOutput{ID: webhook.ID.String(), URL: webhook.URL, Events: events, Active: webhook.Active,
+		CreatedAt: webhook.CreatedAt.UTC().Format(time.RFC3339)}
+}
+
+// deliveryOutput is the representation of a webhook delivery sent to clients
+type deliveryOutput struct {
+	ID             uint64                `json:"id"`
+	EventID        string                `json:"event_id"`
+	EventType      domain.EventType      `json:"event_type"`
+	Status         domain.DeliveryStatus `json:"status"`
+	Attempts       int                   `json:"attempts"`
+	NextAttemptAt  string                `json:"next_attempt_at,omitempty"`
+	LastStatusCode int                   `json:"last_status_code,omitempty"`
+	LastError      string                `json:"last_error,omitempty"`
+	CreatedAt      string                `json:"created_at"`
+	DeliveredAt    string                `json:"delivered_at,omitempty"`
+}
+
+func newDeliveryOutput(delivery *domain.WebhookDelivery) deliveryOutput {
+	out := deliveryOutput{
+		ID:             delivery.ID,
+		EventID:        delivery.EventID.String(),
+		EventType:      delivery.EventType,
+		Status:         delivery.Status,
+		Attempts:       delivery.Attempts,
+		LastStatusCode: delivery.LastStatusCode,
+		LastError:      delivery.LastError,
+		CreatedAt:      delivery.CreatedAt.UTC().Format(time.RFC3339Nano),
+	}
+	if delivery.Status == domain.DeliveryPending {
+		out.NextAttemptAt = delivery.NextAttemptAt.UTC().Format(time.RFC3339Nano)
+	}
+	if delivery.DeliveredAt != nil {
+		out.DeliveredAt = delivery.DeliveredAt.UTC().Format(time.RFC3339Nano)
+	}
+	return out
+}
+
+// CreateWebhook handles subscribing a URL to the todo events of the workspace
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	ctx := c.Request.Context()
+	var input webhookInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		helper.ResponseError(c, http.StatusBadRequest, "Invalid input", err)
+		return
+	}
+	if err := input.validate(h.cfg); err != nil {
+		helper.ResponseError(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	if !helper.Authorize(c, h.policy, domain.ActionManageWebhook, domain.Resource{}) {
+		return
+	}
+
+	webhook := domain.Webhook{
+		ID:        domain.NewUUID(),
+		OwnerID:   domain.PrincipalFromContext(ctx).UserID,
+		URL:       input.URL,
+		Secret:    input.Secret,
+		Events:    input.Events,
+		Active:    input.Active == nil || *input.Active,
+		CreatedAt: time.Now(),
+	}
+	if webhook.Secret == "" {
+		webhook.Secret = webhooks.NewSecret()
+	}
+	if err := h.webhooks.Create(ctx, &webhook); err != nil {
+		helper.ResponseError(c, http.StatusInternalServerError, "Failed to save webhook", err)
+		return
+	}
+	out := newWebhookOutput(&webhook)
+	out.Secret = webhook.Secret
+	helper.SendSuccessResponse(c, http.StatusCreated, out)
+}
+
+// ListWebhooks handles listing the webhooks of the caller
+func (h *WebhookHandler) ListWebhooks(c *gin.Context) {
+	ctx := c.Request.Context()
+	if !helper.Authorize(c, h.policy, domain.ActionManageWebhook, domain.Resource{}) {
+		return
+	}
+	list, err := h.webhooks.List(ctx, domain.PrincipalFromContext(ctx).UserID)
+	if err != nil {
+		helper.ResponseError(c, http.StatusInternalServerError, "Failed to list webhooks", err)
+		return
+	}
+	items := make([]webhookOutput, len(list))
+	for i, webhook := range list {
+		items[i] = newWebhookOutput(webhook)
+	}
+	helper.SendSuccessResponse(c, http.StatusOK, gin.H{"items": items})
+}
+
+// GetWebhook handles retrieving a webhook of the caller by ID
+func (h *WebhookHandler) GetWebhook(c *gin.Context) {
+	webhook, ok := h.lookup(c)
+	if !ok {
+		return
+	}
+	helper.SendSuccessResponse(c, http.StatusOK, newWebhookOutput(webhook))
+}
+
+// PutWebhook handles replacing the URL, events and state of a webhook, and optionally its secret
+func (h *WebhookHandler) PutWebhook(c *gin.Context) {
+	ctx := c.Request.Context()
+	var input webhookInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		helper.ResponseError(c, http.StatusBadRequest, "Invalid input", err)
+		return
+	}
+	if err := input.validate(h.cfg); err != nil {
+		helper.ResponseError(c, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	webhook, ok := h.lookup(c)
+	if !ok {
+		return
+	}
+	webhook.URL, webhook.Events, webhook.Active = input.URL, input.Events, input.Active == nil || *input.Active
+	if input.Secret != "" {
+		webhook.Secret = input.Secret
+	}
+	if err := h.webhooks.Update(ctx, webhook); err != nil {
+		h.responseLookupError(c, err, "Failed to update webhook")
+		return
+	}
+	helper.SendSuccessResponse(c, http.StatusOK, newWebhookOutput(webhook))
+}
+
+// DeleteWebhook handles removing a webhook together with its delivery log
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	webhook, ok := h.lookup(c)
+	if !ok {
+		return
+	}
+	if err := h.webhooks.Delete(c.Request.Context(), webhook.ID); err != nil {
+		h.responseLookupError(c, err, "Failed to delete webhook")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListDeliveries handles listing the delivery log of a webhook, most recent first.
+// The status query parameter narrows it to pending, delivered or dead deliveries.
+func (h *WebhookHandler) ListDeliveries(c *gin.Context) {
+	status := domain.DeliveryStatus(c.Query("status"))
+	switch status {
+	case "", domain.DeliveryPending, domain.DeliveryDelivered, domain.DeliveryDead:
+	default:
+		helper.ResponseError(c, http.StatusBadRequest, "status must be pending, delivered or dead", nil)
+		return
+	}
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+	webhook, ok := h.lookup(c)
+	if !ok {
+		return
+	}
+	deliveries, err := h.deliveries.List(c.Request.Context(), webhook.ID, status, page)
+	if err != nil {
+		helper.ResponseError(c, http.StatusInternalServerError, "Failed to list webhook deliveries", err)
+		return
+	}
+	items := make([]deliveryOutput, len(deliveries))
+	for i, delivery := range deliveries {
+		items[i] = newDeliveryOutput(delivery)
+	}
+	out := gin.H{"items": items}
+	if len(deliveries) == page.Limit {
+		out["next_offset"] = page.Offset + page.Limit
+	}
+	helper.SendSuccessResponse(c, http.StatusOK, out)
+}
+
+// RetryDelivery handles sending a dead delivery again
+func (h *WebhookHandler) RetryDelivery(c *gin.Context) {
+	deliveryID, err := strconv.ParseUint(c.Param("delivery_id"), 10, 64)
+	if err != nil {
+		helper.ResponseError(c, http.StatusBadRequest, "Invalid delivery id", err)
+		return
+	}
+	webhook, ok := h.lookup(c)
+	if !ok {
+		return
+	}
+	if err := h.deliveries.Retry(c.Request.Context(), webhook.ID, deliveryID, time.Now()); err != nil {
+		if errors.Is(err, domain.ErrRecordNotFound) {
+			helper.ResponseError(c, http.StatusNotFound, "dead delivery not found", nil)
+			return
+		}
+		helper.ResponseError(c, http.StatusInternalServerError, "Failed to retry webhook delivery", err)
+		return
+	}
+	c.Status(http.StatusAccepted)
+}
+
+// lookup reads the webhook of the id path parameter, answering the request when it is
+// missing or not managed by the caller
+func (h *WebhookHandler) lookup(c *gin.Context) (*domain.Webhook, bool) {
+	id, err := domain.ParseUUID(c.Param("id"))
+	if err != nil {
+		helper.ResponseError(c, http.StatusBadRequest, "Invalid UUID", err)
+		return nil, false
+	}
+	if !helper.Authorize(c, h.policy, domain.ActionManageWebhook, domain.Resource{}) {
+		return nil, false
+	}
+	webhook, err := h.webhooks.GetByID(c.Request.Context(), id)
+	if err != nil {
+		h.responseLookupError(c, err, "Failed to fetch webhook")
+		return nil, false
+	}
+	if !helper.Authorize(c, h.policy, domain.ActionManageWebhook, domain.Resource{OwnerID: webhook.OwnerID}) {
+		return nil, false
+	}
+	return webhook, true
+}
+
+func (h *WebhookHandler) responseLookupError(c *gin.Context, err error, message string) {
+	if errors.Is(err, domain.ErrRecordNotFound) {
+		helper.ResponseError(c, http.StatusNotFound, "webhook not found", nil)
+		return
+	}
+	helper.ResponseError(c, http.StatusInternalServerError, message, err)
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taheri24/helitask/pkg/auth"
+	"github.com/taheri24/helitask/pkg/config"
+)
+
+// TestWebhooks tests managing webhooks and reading their delivery log
+func TestWebhooks(t *testing.T) {
+	cfg := config.Default()
+	cfg.Auth.JWTSecret = "test-secret"
+	app, fxApp := setupAppWithConfig(t, "", cfg)
+	fxApp.RequireStart()
+	defer fxApp.RequireStop()
+	do := func(method, path, user, body string) *httptest.ResponseRecorder {
+		req, w := setupHTTP(method, path, body)
+		if user != "" {
+			token, err := auth.SignHS256(auth.Claims{"sub": user, "workspace": "default"}, []byte(cfg.Auth.JWTSecret))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		app.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("POST", "/api/v0/webhooks/", "", `{"url": "https://example.com/hook"}`)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = do("POST", "/api/v0/webhooks/", "alice", `{"url": "ftp://example.com/hook"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = do("POST", "/api/v0/webhooks/", "alice", `{"url": "https://example.com/hook", "events": ["todo.exploded"]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	for _, target := range []string{"http://127.0.0.1:8080/hook", "http://169.254.169.254/latest/meta-data", "http://[::ffff:10.0.0.1]/hook", "http://localhost/hook"} {
+		w = do("POST", "/api/v0/webhooks/", "alice", `{"url": "`+target+`"}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code, "%s is not a public target", target)
+	}
+
+	w = do("POST", "/api/v0/webhooks/", "alice", `{"url": "https://example.com/hook", "events": ["todo.completed"]}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	id := extractJsonVal(w.Body.Bytes(), "id")
+	assert.NotEmpty(t, extractJsonVal(w.Body.Bytes(), "secret"), "a secret is generated and returned once")
+
+	w = do("GET", "/api/v0/webhooks/"+id, "alice", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "secret")
+	w = do("GET", "/api/v0/webhooks/"+id, "bob", "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = do("GET", "/api/v0/webhooks/", "bob", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"items": []}`, w.Body.String())
+
+	w = do("PUT", "/api/v0/webhooks/"+id, "alice", `{"url": "https://example.com/other", "active": false}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = do("GET", "/api/v0/webhooks/", "alice", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var list struct {
+		Items []struct {
+			URL    string   `json:"url"`
+			Events []string `json:"events"`
+			Active bool     `json:"active"`
+		} `json:"items"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	if assert.Len(t, list.Items, 1) {
+		assert.Equal(t, "https://example.com/other", list.Items[0].URL)
+		assert.Empty(t, list.Items[0].Events)
+		assert.False(t, list.Items[0].Active)
+	}
+
+	w = do("GET", "/api/v0/webhooks/"+id+"/deliveries", "alice", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"items": []}`, w.Body.String())
+	w = do("GET", "/api/v0/webhooks/"+id+"/deliveries?status=lost", "alice", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = do("POST", "/api/v0/webhooks/"+id+"/deliveries/1/retry", "alice", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = do("DELETE", "/api/v0/webhooks/"+id, "bob", "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = do("DELETE", "/api/v0/webhooks/"+id, "alice", "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	w = do("GET", "/api/v0/webhooks/"+id, "alice", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
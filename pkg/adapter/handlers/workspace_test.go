@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taheri24/helitask/pkg/auth"
+	"github.com/taheri24/helitask/pkg/config"
+)
+
+// TestWorkspaceIsolation tests that todo items are only visible inside their own workspace
+func TestWorkspaceIsolation(t *testing.T) {
+	cfg := config.Default()
+	cfg.Tenant.BaseDomain = "todo.example.com"
+	app, fxApp := setupAppWithConfig(t, "", cfg)
+	fxApp.RequireStart()
+	defer fxApp.RequireStop()
+
+	input := `{"description": "Team A Todo", "due_date": "2025-12-31T23:59:59Z"}`
+	req, w := setupHTTP("POST", "/api/v0/todo/", input)
+	req.Header.Set("X-Workspace-ID", "team-a")
+	app.ServeHTTP(w, req)
+	if !assert.Equal(t, http.StatusCreated, w.Code) {
+		t.Log(w.Body.String())
+	}
+	id := extractJsonVal(w.Body.Bytes(), "id")
+
+	type TestCase struct {
+		name           string
+		host           string
+		workspace      string
+		expectedStatus int
+	}
+	testCases := []TestCase{
+		{"Same workspace by header", "", "team-a", http.StatusOK},
+		{"Same workspace by subdomain", "team-a.todo.example.com", "", http.StatusOK},
+		{"Other workspace by header", "", "team-b", http.StatusNotFound},
+		{"Other workspace by subdomain", "team-b.todo.example.com:8080", "", http.StatusNotFound},
+		{"Default workspace", "", "", http.StatusNotFound},
+		{"Invalid workspace", "", "Team_A!", http.StatusBadRequest},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, w := setupHTTP("GET", "/api/v0/todo/"+id, "")
+			if tc.host != "" {
+				req.Host = tc.host
+			}
+			if tc.workspace != "" {
+				req.Header.Set("X-Workspace-ID", tc.workspace)
+			}
+			app.ServeHTTP(w, req)
+			if !assert.Equal(t, tc.expectedStatus, w.Code) {
+				t.Log(w.Body.String())
+			}
+		})
+	}
+}
+
+// TestWorkspaceFromTokenClaim tests that the workspace claim of a bearer token is honoured
+func TestWorkspaceFromTokenClaim(t *testing.T) {
+	cfg := config.Default()
+	cfg.Auth.JWTSecret = "test-secret"
+	app, fxApp := setupAppWithConfig(t, "", cfg)
+	fxApp.RequireStart()
+	defer fxApp.RequireStop()
+
+	token, err := auth.SignHS256(auth.Claims{"sub": "alice", "workspace": "team-a"}, []byte(cfg.Auth.JWTSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := `{"description": "Team A Todo", "due_date": "2025-12-31T23:59:59Z"}`
+	req, w := setupHTTP("POST", "/api/v0/todo/", input)
+	req.Header.Set("Authorization", "Bearer "+token)
+	app.ServeHTTP(w, req)
+	if !assert.Equal(t, http.StatusCreated, w.Code) {
+		t.Log(w.Body.String())
+	}
+	id := extractJsonVal(w.Body.Bytes(), "id")
+
+	req, w = setupHTTP("GET", "/api/v0/todo/"+id, "")
+	req.Header.Set("Authorization", "Bearer "+token)
+	app.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	req, w = setupHTTP("GET", "/api/v0/todo/"+id, "")
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("X-Workspace-ID", "team-b")
+	app.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	req, w = setupHTTP("GET", "/api/v0/todo/"+id, "")
+	req.Header.Set("Authorization", "Bearer "+token+"x")
+	app.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+// TestWorkspaceOfAnonymousRequests tests that with bearer tokens in use, only authenticated
+// callers and trusted gateways can name a workspace
+func TestWorkspaceOfAnonymousRequests(t *testing.T) {
+	cfg := config.Default()
+	cfg.Auth.JWTSecret = "test-secret"
+	cfg.Tenant.TrustedGateways = []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+	app, fxApp := setupAppWithConfig(t, "", cfg)
+	fxApp.RequireStart()
+	defer fxApp.RequireStop()
+	call := func(claims auth.Claims, workspace, remoteAddr string) int {
+		req, w := setupHTTP("GET", "/api/v0/todo/trash", "")
+		if claims != nil {
+			token, err := auth.SignHS256(claims, []byte(cfg.Auth.JWTSecret))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		if workspace != "" {
+			req.Header.Set("X-Workspace-ID", workspace)
+		}
+		if remoteAddr != "" {
+			req.RemoteAddr = remoteAddr
+		}
+		app.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, call(nil, "team-a", ""), "anonymous callers cannot pick a workspace")
+	assert.Equal(t, http.StatusForbidden, call(auth.Claims{"sub": "alice"}, "team-a", ""), "tokens must name the workspace")
+	assert.Equal(t, http.StatusOK, call(auth.Claims{"sub": "alice", "workspace": "team-a"}, "team-a", ""))
+	assert.Equal(t, http.StatusOK, call(nil, "team-a", "10.1.2.3:4567"), "trusted gateways name the workspace")
+
+	cfg.Tenant.Claim = ""
+	app, fxApp = setupAppWithConfig(t, "", cfg)
+	fxApp.RequireStart()
+	defer fxApp.RequireStop()
+	input := `{"description": "Team A Todo", "due_date": "2025-12-31T23:59:59Z"}`
+	req, w := setupHTTP("POST", "/api/v0/todo/", input)
+	req.Header.Set("X-Workspace-ID", "team-a")
+	app.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	req, w = setupHTTP("GET", "/api/v0/todo/"+extractJsonVal(w.Body.Bytes(), "id"), "")
+	app.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, "the header of anonymous callers is ignored")
+}
+
+// TestWorkspaceQuota tests the per-workspace limits on todo counts and description sizes
+func TestWorkspaceQuota(t *testing.T) {
+	cfg := config.Default()
+	cfg.Tenant.Quota = config.QuotaConfig{MaxTodos: 1, MaxDescriptionSize: 20}
+	cfg.Tenant.Quotas = map[string]config.QuotaConfig{"team-a": {MaxTodos: 2}}
+	app, fxApp := setupAppWithConfig(t, "", cfg)
+	fxApp.RequireStart()
+	defer fxApp.RequireStop()
+
+	type TestCase struct {
+		name           string
+		workspace      string
+		description    string
+		expectedStatus int
+	}
+	testCases := []TestCase{
+		{"Description over size quota", "", "a description longer than twenty bytes", http.StatusRequestEntityTooLarge},
+		{"First todo", "", "Test Todo", http.StatusCreated},
+		{"Second todo over count quota", "", "Test Todo", http.StatusForbidden},
+		{"Override first todo", "team-a", "a description longer than twenty bytes", http.StatusCreated},
+		{"Override second todo", "team-a", "Test Todo", http.StatusCreated},
+		{"Override third todo", "team-a", "Test Todo", http.StatusForbidden},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			input := `{"description": "` + tc.description + `", "due_date": "2025-12-31T23:59:59Z"}`
+			req, w := setupHTTP("POST", "/api/v0/todo/", input)
+			if tc.workspace != "" {
+				req.Header.Set("X-Workspace-ID", tc.workspace)
+			}
+			app.ServeHTTP(w, req)
+			if !assert.Equal(t, tc.expectedStatus, w.Code) {
+				t.Log(w.Body.String())
+			}
+		})
+	}
+
+	t.Run("Restore over count quota", func(t *testing.T) {
+		do := func(method, path, body string) *httptest.ResponseRecorder {
+			req, w := setupHTTP(method, path, body)
+			req.Header.Set("X-Workspace-ID", "team-b")
+			app.ServeHTTP(w, req)
+			return w
+		}
+		input := `{"description": "Test Todo", "due_date": "2025-12-31T23:59:59Z"}`
+		first := extractJsonVal(do("POST", "/api/v0/todo/", input).Body.Bytes(), "id")
+		assert.Equal(t, http.StatusNoContent, do("DELETE", "/api/v0/todo/"+first, "").Code)
+		assert.Equal(t, http.StatusCreated, do("POST", "/api/v0/todo/", input).Code, "items in the trash do not count")
+		w := do("POST", "/api/v0/todo/"+first+"/restore", "")
+		assert.Equal(t, http.StatusForbidden, w.Code, "restoring an item counts it again")
+	})
+}
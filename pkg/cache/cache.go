@@ -0,0 +1,112 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/taheri24/helitask/pkg/config"
+)
+
+// Store holds cached values by key until their TTL runs out.
+// The LRU store is local to one process; an external implementation (e.g. Redis or
+// memcached) lets several replicas share entries and see each other's invalidations.
+type Store interface {
+	// Get returns the value stored for key, and false when there is none or it expired
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+}
+
+type entry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+// LRU is an in-process Store holding a bounded number of entries,
+// evicting the least recently used one when it is full
+type LRU struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List
+	entries  map[string]*list.Element
+	now      func() time.Time
+}
+
+// NewStore creates the Store configured in cfg
+func NewStore(cfg *config.Config) Store {
+	return NewLRU(cfg.Cache.Size)
+}
+
+// NewLRU creates an in-process Store holding up to capacity entries
+func NewLRU(capacity int) *LRU {
+	return newLRU(capacity, time.Now)
+}
+
+func newLRU(capacity int, now func() time.Time) *LRU {
+	return &LRU{capacity: max(capacity, 1), order: list.New(), entries: map[string]*list.Element{}, now: now}
+}
+
+// Get implements Store
+func (s *LRU) Get(_ context.Context, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	el, ok := s.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	e := el.Value.(*entry)
+	if !s.now().Before(e.expiresAt) {
+		s.remove(el)
+		return nil, false, nil
+	}
+	s.order.MoveToFront(el)
+	return e.value, true, nil
+}
+
+// Set implements Store
+func (s *LRU) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expiresAt := s.now().Add(ttl)
+	if el, ok := s.entries[key]; ok {
+		e := el.Value.(*entry)
+		e.value, e.expiresAt = value, expiresAt
+		s.order.MoveToFront(el)
+		return nil
+	}
+	s.entries[key] = s.order.PushFront(&entry{key: key, value: value, expiresAt: expiresAt})
+	for s.order.Len() > s.capacity {
+		s.remove(s.order.Back())
+	}
+	return nil
+}
+
+// Delete implements Store
+func (s *LRU) Delete(_ context.Context, keys ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range keys {
+		if el, ok := s.entries[key]; ok {
+			s.remove(el)
+		}
+	}
+	return nil
+}
+
+// Len returns the number of entries held, including expired ones not evicted yet
+func (s *LRU) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.order.Len()
+}
+
+func (s *LRU) remove(el *list.Element) {
+	s.order.Remove(el)
+	delete(s.entries, el.Value.(*entry).key)
+}
@@ -0,0 +1,42 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLRU(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	s := newLRU(2, func() time.Time { return now })
+	ctx := t.Context()
+	get := func(key string) string {
+		value, ok, err := s.Get(ctx, key)
+		assert.NoError(t, err)
+		if !ok {
+			return "<none>"
+		}
+		return string(value)
+	}
+
+	assert.NoError(t, s.Set(ctx, "a", []byte("1"), time.Minute))
+	assert.NoError(t, s.Set(ctx, "b", []byte("2"), time.Minute))
+	assert.Equal(t, "1", get("a"))
+
+	// "b" is the least recently used entry now
+	assert.NoError(t, s.Set(ctx, "c", []byte("3"), time.Minute))
+	assert.Equal(t, "<none>", get("b"))
+	assert.Equal(t, "1", get("a"))
+	assert.Equal(t, "3", get("c"))
+
+	assert.NoError(t, s.Set(ctx, "c", []byte("4"), time.Second))
+	assert.Equal(t, "4", get("c"))
+	now = now.Add(time.Second)
+	assert.Equal(t, "<none>", get("c"), "expired entries are gone")
+	assert.Equal(t, 1, s.Len())
+
+	assert.NoError(t, s.Delete(ctx, "a", "missing"))
+	assert.Equal(t, "<none>", get("a"))
+	assert.Equal(t, 0, s.Len())
+}
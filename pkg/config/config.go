@@ -2,25 +2,194 @@ package config
 
 import (
 	"fmt"
+	"net/netip"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
 // Config represents the application configuration
 type Config struct {
-	DB     DatabaseConfig
-	Server ServerConfig
+	DB          DatabaseConfig
+	Server      ServerConfig
+	Auth        AuthConfig
+	Tenant      TenantConfig
+	RateLimit   RateLimitConfig
+	Idempotency IdempotencyConfig
+	Cache       CacheConfig
+	Trash       TrashConfig
+	Events      EventsConfig
+	Webhook     WebhookConfig
+	Stream      StreamConfig
 }
 
 // DatabaseConfig holds database-related settings
 type DatabaseConfig struct {
 	DSN string
+	// ReadTimeout bounds every query reading todo items; zero disables it
+	ReadTimeout time.Duration
+	// WriteTimeout bounds every statement writing a single todo item; zero disables it
+	WriteTimeout time.Duration
+	// BatchTimeout bounds the multi-row inserts of batch requests; zero disables it
+	BatchTimeout time.Duration
+
+	// MaxOpenConns and MaxIdleConns size the connection pool; zero leaves the driver defaults
+	MaxOpenConns int
+	MaxIdleConns int
+	// ConnMaxLifetime and ConnMaxIdleTime recycle pooled connections; zero keeps them forever
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+
+	// ConnectTimeout is the deadline for reaching the database at startup
+	ConnectTimeout time.Duration
+	// RetryBackoff is the delay before the first connection retry, doubled up to RetryMaxBackoff
+	RetryBackoff    time.Duration
+	RetryMaxBackoff time.Duration
+
+	// ReplicaDSNs lists read replicas of the database; reads use the primary when it is empty
+	ReplicaDSNs []string
+	// ReplicaCheckInterval is how often unhealthy replicas are probed to bring them back
+	ReplicaCheckInterval time.Duration
+	// ReadYourWrites is how long a client reads from the primary after a write; zero disables pinning
+	ReadYourWrites time.Duration
 }
 
 // ServerConfig holds the server-related settings
 type ServerConfig struct {
 	Port string
+	// GRPCPort is the port of the gRPC API; the gRPC API is not served when it is empty
+	GRPCPort string
+	// AdminAddr is the address of the internal endpoints, such as /debug/vars; a bare port
+	// listens on localhost only, and nothing is served when it is empty
+	AdminAddr string
+	// ShutdownTimeout is how long the running requests and open connections are given to finish on shutdown
+	ShutdownTimeout time.Duration
+	// TrustedProxies are the addresses and CIDR ranges of the proxies whose forwarding headers
+	// name the client IP; the headers of every other peer are ignored
+	TrustedProxies []string
+}
+
+// AuthConfig holds the settings used to verify bearer tokens
+type AuthConfig struct {
+	// JWTSecret is the HS256 key for bearer tokens; tokens are ignored when it is empty
+	JWTSecret string
+	// APIKeys are the keys API key clients may send; other keys are ignored
+	APIKeys []string
+}
+
+// TenantConfig holds the settings used to resolve and limit workspaces
+type TenantConfig struct {
+	// Header names the request header carrying the workspace id
+	Header string
+	// Claim names the bearer token claim carrying the workspace id
+	Claim string
+	// BaseDomain enables subdomain resolution, e.g. acme.todo.example.com for "todo.example.com"
+	BaseDomain string
+	// Default is the workspace used when a request does not name one
+	Default string
+	// TrustedGateways are the addresses of the gateways that may name the workspace of
+	// anonymous requests by header or subdomain
+	TrustedGateways []netip.Prefix
+	// Quota applies to every workspace without an entry in Quotas
+	Quota QuotaConfig
+	// Quotas overrides Quota for individual workspaces
+	Quotas map[string]QuotaConfig
+}
+
+// QuotaConfig bounds what a single workspace may store; zero means unlimited
+type QuotaConfig struct {
+	MaxTodos           int
+	MaxDescriptionSize int
+}
+
+// QuotaFor returns the quota applied to the given workspace
+func (t TenantConfig) QuotaFor(workspace string) QuotaConfig {
+	if q, ok := t.Quotas[workspace]; ok {
+		return q
+	}
+	return t.Quota
+}
+
+// RateLimitConfig holds the token bucket limits applied per API key, user or client IP
+type RateLimitConfig struct {
+	Enabled bool
+	// ReadRate and ReadBurst limit safe requests (GET, HEAD, OPTIONS), in requests per second
+	ReadRate  float64
+	ReadBurst int
+	// WriteRate and WriteBurst limit every other request, in requests per second
+	WriteRate  float64
+	WriteBurst int
+}
+
+// IdempotencyConfig holds the settings of Idempotency-Key handling
+type IdempotencyConfig struct {
+	// TTL is how long the response of a request is replayed for its key
+	TTL time.Duration
+}
+
+// CacheConfig holds the settings of the read-through cache of todo items
+type CacheConfig struct {
+	Enabled bool
+	// Size is the number of entries kept by the in-process store
+	Size int
+	// TTL is how long a todo item is served from the cache
+	TTL time.Duration
+	// NegativeTTL is how long the absence of a todo item is remembered
+	NegativeTTL time.Duration
+}
+
+// TrashConfig holds the settings of the trash of deleted todo items
+type TrashConfig struct {
+	// Retention is how long deleted items can be restored before they are purged
+	Retention time.Duration
+	// PurgeInterval is how often items past their retention are purged; zero disables purging
+	PurgeInterval time.Duration
+}
+
+// EventsConfig holds the settings of the relay publishing the domain events of the outbox
+type EventsConfig struct {
+	// RelayInterval is how often the outbox is polled for unpublished events; zero disables the relay
+	RelayInterval time.Duration
+	// BatchSize is the number of events published per poll
+	BatchSize int
+	// MaxAttempts is the number of failed attempts after which the relay gives up on an event,
+	// so that it does not hold back the events after it; zero retries every event forever
+	MaxAttempts int
+	// Retention is how long published and dead events are kept in the outbox
+	Retention time.Duration
+	// Log publishes every event to the application log as well
+	Log bool
+}
+
+// WebhookConfig holds the settings of the delivery of events to webhooks
+type WebhookConfig struct {
+	// PollInterval is how often due deliveries are sent; zero disables delivery
+	PollInterval time.Duration
+	// BatchSize is the number of deliveries sent per poll
+	BatchSize int
+	// Timeout bounds every delivery request
+	Timeout time.Duration
+	// MaxAttempts is the number of failed attempts after which a delivery is dead
+	MaxAttempts int
+	// RetryBackoff is the delay before the first retry, doubling with every further attempt up to RetryMaxBackoff
+	RetryBackoff    time.Duration
+	RetryMaxBackoff time.Duration
+	// AllowPrivateTargets lets webhooks target private, loopback and link-local addresses,
+	// which are refused by default so that webhooks cannot reach the internal network
+	AllowPrivateTargets bool
+}
+
+// StreamConfig holds the settings of the event streams sent to clients
+type StreamConfig struct {
+	// ReplaySize is the number of recent events kept for clients resuming a stream
+	ReplaySize int
+	// ClientBuffer is the number of events queued for a client before it is disconnected as too slow
+	ClientBuffer int
+	// Heartbeat is how often an idle stream is kept alive; zero disables heartbeats
+	Heartbeat time.Duration
 }
 
 func fileSize(fn string) int64 {
@@ -52,16 +221,229 @@ func LoadConfig(env string) (*Config, error) {
 		return nil, fmt.Errorf("error loading .env file: %s", err)
 	}
 	viper.AutomaticEnv()
-	// Set default values if necessary
+	setDefaults()
+
+	return fromViper()
+}
+
+// Default returns the configuration built from the defaults and the process environment only
+func Default() *Config {
+	viper.AutomaticEnv()
+	setDefaults()
+	cfg, err := fromViper()
+	if err != nil {
+		panic(err)
+	}
+	return cfg
+}
+
+// setDefaults registers the default value of every setting
+func setDefaults() {
 	viper.SetDefault("DB_DSN", "localhost:5432")
+	viper.SetDefault("DB_READ_TIMEOUT", "5s")
+	viper.SetDefault("DB_WRITE_TIMEOUT", "10s")
+	viper.SetDefault("DB_BATCH_TIMEOUT", "30s")
+	viper.SetDefault("DB_MAX_OPEN_CONNS", 25)
+	viper.SetDefault("DB_MAX_IDLE_CONNS", 5)
+	viper.SetDefault("DB_CONN_MAX_LIFETIME", "30m")
+	viper.SetDefault("DB_CONN_MAX_IDLE_TIME", "5m")
+	viper.SetDefault("DB_CONNECT_TIMEOUT", "60s")
+	viper.SetDefault("DB_RETRY_BACKOFF", "500ms")
+	viper.SetDefault("DB_RETRY_MAX_BACKOFF", "10s")
+	viper.SetDefault("DB_REPLICA_CHECK_INTERVAL", "5s")
+	viper.SetDefault("DB_READ_YOUR_WRITES", "5s")
 	viper.SetDefault("PORT", "8080")
+	viper.SetDefault("GRPC_PORT", "9090")
+	viper.SetDefault("ADMIN_ADDR", "6060")
+	viper.SetDefault("SHUTDOWN_TIMEOUT", "15s")
+	viper.SetDefault("TENANT_HEADER", "X-Workspace-ID")
+	viper.SetDefault("TENANT_CLAIM", "workspace")
+	viper.SetDefault("TENANT_DEFAULT", "default")
+	viper.SetDefault("RATE_LIMIT_ENABLED", true)
+	viper.SetDefault("RATE_LIMIT_READ_RPS", 20)
+	viper.SetDefault("RATE_LIMIT_READ_BURST", 40)
+	viper.SetDefault("RATE_LIMIT_WRITE_RPS", 5)
+	viper.SetDefault("RATE_LIMIT_WRITE_BURST", 10)
+	viper.SetDefault("IDEMPOTENCY_TTL", "24h")
+	viper.SetDefault("CACHE_ENABLED", true)
+	viper.SetDefault("CACHE_SIZE", 10000)
+	viper.SetDefault("CACHE_TTL", "1m")
+	viper.SetDefault("CACHE_NEGATIVE_TTL", "5s")
+	viper.SetDefault("TRASH_RETENTION", "720h")
+	viper.SetDefault("TRASH_PURGE_INTERVAL", "1h")
+	viper.SetDefault("EVENTS_RELAY_INTERVAL", "1s")
+	viper.SetDefault("EVENTS_BATCH_SIZE", 100)
+	viper.SetDefault("EVENTS_MAX_ATTEMPTS", 10)
+	viper.SetDefault("EVENTS_RETENTION", "168h")
+	viper.SetDefault("EVENTS_LOG", false)
+	viper.SetDefault("WEBHOOK_POLL_INTERVAL", "1s")
+	viper.SetDefault("WEBHOOK_BATCH_SIZE", 20)
+	viper.SetDefault("WEBHOOK_TIMEOUT", "10s")
+	viper.SetDefault("WEBHOOK_MAX_ATTEMPTS", 8)
+	viper.SetDefault("WEBHOOK_RETRY_BACKOFF", "10s")
+	viper.SetDefault("WEBHOOK_RETRY_MAX_BACKOFF", "1h")
+	viper.SetDefault("WEBHOOK_ALLOW_PRIVATE_TARGETS", false)
+	viper.SetDefault("STREAM_REPLAY_SIZE", 1000)
+	viper.SetDefault("STREAM_CLIENT_BUFFER", 64)
+	viper.SetDefault("STREAM_HEARTBEAT", "15s")
+}
+
+func fromViper() (*Config, error) {
+	quotas, err := parseQuotas(viper.GetString("TENANT_QUOTAS"))
+	if err != nil {
+		return nil, err
+	}
+	gateways, err := parsePrefixes(viper.GetString("TENANT_TRUSTED_GATEWAYS"))
+	if err != nil {
+		return nil, err
+	}
 
 	return &Config{
 		DB: DatabaseConfig{
-			DSN: viper.GetString("DB_DSN"),
+			DSN:          viper.GetString("DB_DSN"),
+			ReadTimeout:  viper.GetDuration("DB_READ_TIMEOUT"),
+			WriteTimeout: viper.GetDuration("DB_WRITE_TIMEOUT"),
+			BatchTimeout: viper.GetDuration("DB_BATCH_TIMEOUT"),
+
+			MaxOpenConns:    viper.GetInt("DB_MAX_OPEN_CONNS"),
+			MaxIdleConns:    viper.GetInt("DB_MAX_IDLE_CONNS"),
+			ConnMaxLifetime: viper.GetDuration("DB_CONN_MAX_LIFETIME"),
+			ConnMaxIdleTime: viper.GetDuration("DB_CONN_MAX_IDLE_TIME"),
+
+			ConnectTimeout:  viper.GetDuration("DB_CONNECT_TIMEOUT"),
+			RetryBackoff:    viper.GetDuration("DB_RETRY_BACKOFF"),
+			RetryMaxBackoff: viper.GetDuration("DB_RETRY_MAX_BACKOFF"),
+
+			ReplicaDSNs:          splitList(viper.GetString("DB_REPLICA_DSNS")),
+			ReplicaCheckInterval: viper.GetDuration("DB_REPLICA_CHECK_INTERVAL"),
+			ReadYourWrites:       viper.GetDuration("DB_READ_YOUR_WRITES"),
 		},
 		Server: ServerConfig{
-			Port: viper.GetString("PORT"),
+			Port:            viper.GetString("PORT"),
+			GRPCPort:        viper.GetString("GRPC_PORT"),
+			AdminAddr:       viper.GetString("ADMIN_ADDR"),
+			ShutdownTimeout: viper.GetDuration("SHUTDOWN_TIMEOUT"),
+			TrustedProxies:  splitList(viper.GetString("TRUSTED_PROXIES")),
+		},
+		Auth: AuthConfig{
+			JWTSecret: viper.GetString("AUTH_JWT_SECRET"),
+			APIKeys:   splitList(viper.GetString("AUTH_API_KEYS")),
+		},
+		Tenant: TenantConfig{
+			Header:          viper.GetString("TENANT_HEADER"),
+			Claim:           viper.GetString("TENANT_CLAIM"),
+			BaseDomain:      viper.GetString("TENANT_BASE_DOMAIN"),
+			Default:         viper.GetString("TENANT_DEFAULT"),
+			TrustedGateways: gateways,
+			Quota: QuotaConfig{
+				MaxTodos:           viper.GetInt("TENANT_MAX_TODOS"),
+				MaxDescriptionSize: viper.GetInt("TENANT_MAX_DESCRIPTION_SIZE"),
+			},
+			Quotas: quotas,
+		},
+		RateLimit: RateLimitConfig{
+			Enabled:    viper.GetBool("RATE_LIMIT_ENABLED"),
+			ReadRate:   viper.GetFloat64("RATE_LIMIT_READ_RPS"),
+			ReadBurst:  viper.GetInt("RATE_LIMIT_READ_BURST"),
+			WriteRate:  viper.GetFloat64("RATE_LIMIT_WRITE_RPS"),
+			WriteBurst: viper.GetInt("RATE_LIMIT_WRITE_BURST"),
+		},
+		Idempotency: IdempotencyConfig{
+			TTL: viper.GetDuration("IDEMPOTENCY_TTL"),
+		},
+		Cache: CacheConfig{
+			Enabled:     viper.GetBool("CACHE_ENABLED"),
+			Size:        viper.GetInt("CACHE_SIZE"),
+			TTL:         viper.GetDuration("CACHE_TTL"),
+			NegativeTTL: viper.GetDuration("CACHE_NEGATIVE_TTL"),
+		},
+		Trash: TrashConfig{
+			Retention:     viper.GetDuration("TRASH_RETENTION"),
+			PurgeInterval: viper.GetDuration("TRASH_PURGE_INTERVAL"),
+		},
+		Events: EventsConfig{
+			RelayInterval: viper.GetDuration("EVENTS_RELAY_INTERVAL"),
+			BatchSize:     viper.GetInt("EVENTS_BATCH_SIZE"),
+			MaxAttempts:   viper.GetInt("EVENTS_MAX_ATTEMPTS"),
+			Retention:     viper.GetDuration("EVENTS_RETENTION"),
+			Log:           viper.GetBool("EVENTS_LOG"),
+		},
+		Webhook: WebhookConfig{
+			PollInterval:        viper.GetDuration("WEBHOOK_POLL_INTERVAL"),
+			BatchSize:           viper.GetInt("WEBHOOK_BATCH_SIZE"),
+			Timeout:             viper.GetDuration("WEBHOOK_TIMEOUT"),
+			MaxAttempts:         viper.GetInt("WEBHOOK_MAX_ATTEMPTS"),
+			RetryBackoff:        viper.GetDuration("WEBHOOK_RETRY_BACKOFF"),
+			RetryMaxBackoff:     viper.GetDuration("WEBHOOK_RETRY_MAX_BACKOFF"),
+			AllowPrivateTargets: viper.GetBool("WEBHOOK_ALLOW_PRIVATE_TARGETS"),
+		},
+		Stream: StreamConfig{
+			ReplaySize:   viper.GetInt("STREAM_REPLAY_SIZE"),
+			ClientBuffer: viper.GetInt("STREAM_CLIENT_BUFFER"),
+			Heartbeat:    viper.GetDuration("STREAM_HEARTBEAT"),
 		},
 	}, nil
 }
+
+// parseQuotas reads per-workspace quotas written as "acme=500:2000,beta=100:0",
+// where each value is "<max todos>:<max description size>"
+func parseQuotas(s string) (map[string]QuotaConfig, error) {
+	quotas := map[string]QuotaConfig{}
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, limits, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid TENANT_QUOTAS entry %q", entry)
+		}
+		todos, size, _ := strings.Cut(limits, ":")
+		var q QuotaConfig
+		var err error
+		if q.MaxTodos, err = atoiOrZero(todos); err != nil {
+			return nil, fmt.Errorf("invalid TENANT_QUOTAS entry %q: %w", entry, err)
+		}
+		if q.MaxDescriptionSize, err = atoiOrZero(size); err != nil {
+			return nil, fmt.Errorf("invalid TENANT_QUOTAS entry %q: %w", entry, err)
+		}
+		quotas[strings.TrimSpace(name)] = q
+	}
+	return quotas, nil
+}
+
+// parsePrefixes reads a comma-separated list of addresses and CIDR ranges
+func parsePrefixes(s string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, entry := range splitList(s) {
+		if addr, err := netip.ParseAddr(entry); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TENANT_TRUSTED_GATEWAYS entry %q: %w", entry, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// splitList reads a comma separated list, dropping empty entries
+func splitList(s string) []string {
+	var list []string
+	for _, entry := range strings.Split(s, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			list = append(list, entry)
+		}
+	}
+	return list
+}
+
+func atoiOrZero(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(s)
+}
@@ -0,0 +1,51 @@
+package di
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/taheri24/helitask/pkg/config"
+	"github.com/taheri24/helitask/pkg/logger"
+)
+
+// backoff returns the delay before retry number attempt (starting at 0): an exponential
+// delay capped at max, with full jitter so that restarted replicas do not retry in lockstep
+func backoff(attempt int, base, max time.Duration) time.Duration {
+	d := base
+	for i := 0; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if max > 0 && d > max {
+		d = max
+	}
+	return rand.N(d) + 1
+}
+
+// connectWithRetry calls ping until it succeeds, backing off between attempts,
+// and gives up with the last error once cfg.ConnectTimeout has passed.
+// A zero cfg.RetryBackoff disables retries.
+func connectWithRetry(ctx context.Context, cfg config.DatabaseConfig, logger logger.Logger, ping func(context.Context) error) error {
+	if cfg.ConnectTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.ConnectTimeout)
+		defer cancel()
+	}
+	for attempt := 0; ; attempt++ {
+		err := ping(ctx)
+		if err == nil {
+			return nil
+		}
+		if cfg.RetryBackoff <= 0 {
+			return err
+		}
+		delay := backoff(attempt, cfg.RetryBackoff, cfg.RetryMaxBackoff)
+		logger.Info("Database is not reachable yet", "attempt", attempt+1, "retryIn", delay, "err", err)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("database not reachable after %d attempts: %w", attempt+1, err)
+		case <-time.After(delay):
+		}
+	}
+}
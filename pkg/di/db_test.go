@@ -0,0 +1,59 @@
+package di
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taheri24/helitask/pkg/config"
+	"github.com/taheri24/helitask/pkg/logger"
+)
+
+func TestBackoff(t *testing.T) {
+	base, max := 100*time.Millisecond, time.Second
+	for attempt, limit := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		limit *= time.Millisecond
+		for range 20 {
+			d := backoff(attempt, base, max)
+			assert.Greater(t, d, time.Duration(0))
+			assert.LessOrEqual(t, d, limit, "attempt %d", attempt)
+		}
+	}
+}
+
+func TestConnectWithRetry(t *testing.T) {
+	errDown := errors.New("connection refused")
+	cfg := config.DatabaseConfig{ConnectTimeout: time.Second, RetryBackoff: time.Millisecond, RetryMaxBackoff: 5 * time.Millisecond}
+
+	t.Run("Database comes up", func(t *testing.T) {
+		attempts := 0
+		err := connectWithRetry(t.Context(), cfg, logger.Nop(), func(context.Context) error {
+			if attempts++; attempts < 4 {
+				return errDown
+			}
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 4, attempts)
+	})
+
+	t.Run("Deadline passes", func(t *testing.T) {
+		cfg := cfg
+		cfg.ConnectTimeout = 30 * time.Millisecond
+		started := time.Now()
+		err := connectWithRetry(t.Context(), cfg, logger.Nop(), func(context.Context) error { return errDown })
+		assert.ErrorIs(t, err, errDown)
+		assert.Less(t, time.Since(started), time.Second)
+	})
+
+	t.Run("Retries disabled", func(t *testing.T) {
+		cfg := cfg
+		cfg.RetryBackoff = 0
+		attempts := 0
+		err := connectWithRetry(t.Context(), cfg, logger.Nop(), func(context.Context) error { attempts++; return errDown })
+		assert.ErrorIs(t, err, errDown)
+		assert.Equal(t, 1, attempts)
+	})
+}
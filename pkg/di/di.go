@@ -1,6 +1,8 @@
 package di
 
 import (
+	"context"
+
 	"github.com/taheri24/helitask/pkg/config"
 	"github.com/taheri24/helitask/pkg/logger"
 	"github.com/taheri24/helitask/pkg/ports/storage/postgres"
@@ -21,19 +23,26 @@ func ProvideLogger(cfg *config.Config) logger.Logger {
 	return log
 }
 
-// ProvideDB establishes the database connection and provides a TodoRepository
+// ProvideDB establishes the database connection and provides a TodoRepository.
+// While the database is starting up, connecting is retried until cfg.DB.ConnectTimeout;
+// once connected, the pool replaces broken connections on its own.
 func ProvideDB(cfg *config.Config, logger logger.Logger) (*gorm.DB, error) {
 	if cfg == nil {
 		panic("cfg==nil")
 	}
-	db, err := postgres.NewDB(cfg.DB.DSN)
+	db, err := postgres.Open(cfg.DB.DSN)
 	if err != nil {
 		logger.Error("Failed to connect to database", err)
 		return nil, err
 	}
-	sqlDb, _ := db.DB()
-	if err := sqlDb.Ping(); err != nil {
+	sqlDb, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	postgres.ConfigurePool(sqlDb, cfg.DB)
+	if err := connectWithRetry(context.Background(), cfg.DB, logger, sqlDb.PingContext); err != nil {
 		logger.Error("Database connection failed", err)
+		sqlDb.Close()
 		return nil, err
 	}
 
@@ -0,0 +1,114 @@
+package domain
+
+import (
+	"context"
+	"reflect"
+	"time"
+)
+
+// AuditAction names the kind of change an AuditEntry records
+type AuditAction string
+
+const (
+	AuditCreated   AuditAction = "created"
+	AuditUpdated   AuditAction = "updated"
+	AuditCompleted AuditAction = "completed"
+	AuditReopened  AuditAction = "reopened"
+	AuditDeleted   AuditAction = "deleted"
+	AuditRestored  AuditAction = "restored"
+	AuditReverted  AuditAction = "reverted"
+)
+
+// FieldChange holds the values of a field before and after a change; nil stands for no value
+type FieldChange struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// AuditEntry records one change of a todo item. Entries are only ever appended.
+type AuditEntry struct {
+	// ID grows with every entry, ordering the entries of an item
+	ID          uint64 `gorm:"primarykey;autoIncrement"`
+	WorkspaceID string `gorm:"column:workspace_id;not null;index:idx_todo_audit_log_item,priority:1"`
+	TodoID      UUID   `gorm:"column:todo_id;not null;index:idx_todo_audit_log_item,priority:2"`
+	// Version is the version of the item the change created
+	Version   int64                  `gorm:"column:version;not null;default:1"`
+	Action    AuditAction            `gorm:"column:action;not null"`
+	ActorID   string                 `gorm:"column:actor_id"`
+	RequestID string                 `gorm:"column:request_id"`
+	At        time.Time              `gorm:"column:at;not null"`
+	Changes   map[string]FieldChange `gorm:"column:changes;type:text;serializer:json"`
+}
+
+func (AuditEntry) TableName() string { return "todo_audit_log" }
+
+type AuditRepository interface {
+	// History returns the entries of a todo item, most recent first
+	History(ctx context.Context, todoID UUID, page Page) ([]*AuditEntry, error)
+}
+
+// todoFields reads the audited fields of a todo item, keyed by their JSON names
+func todoFields(todo *TodoItem) map[string]any {
+	if todo == nil {
+		return map[string]any{}
+	}
+	fields := map[string]any{
+		"list_id":     nil,
+		"owner_id":    todo.OwnerID,
+		"description": todo.Description,
+		"due_date":    todo.DueDate.UTC(),
+		"completed":   todo.Completed,
+		"deleted_at":  nil,
+	}
+	if todo.ListID != nil {
+		fields["list_id"] = todo.ListID.String()
+	}
+	if todo.DeletedAt.Valid {
+		fields["deleted_at"] = todo.DeletedAt.Time.UTC()
+	}
+	return fields
+}
+
+// DiffTodo returns the fields that differ between two states of a todo item;
+// before is nil for a created item
+func DiffTodo(before, after *TodoItem) map[string]FieldChange {
+	old, cur := todoFields(before), todoFields(after)
+	changes := map[string]FieldChange{}
+	for name, value := range cur {
+		prev, ok := old[name]
+		if ok && sameValue(prev, value) || !ok && value == nil {
+			continue
+		}
+		changes[name] = FieldChange{Before: prev, After: value}
+	}
+	return changes
+}
+
+func sameValue(a, b any) bool {
+	if at, ok := a.(time.Time); ok {
+		bt, ok := b.(time.Time)
+		return ok && at.Equal(bt)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// NewAuditEntry describes the change of a todo item from before to after, made by the caller of ctx.
+// before is nil for a created item. Updates flipping Completed are recorded as status changes.
+func NewAuditEntry(ctx context.Context, action AuditAction, before, after *TodoItem, at time.Time) *AuditEntry {
+	if action == AuditUpdated && before != nil && before.Completed != after.Completed {
+		action = AuditReopened
+		if after.Completed {
+			action = AuditCompleted
+		}
+	}
+	return &AuditEntry{
+		WorkspaceID: after.WorkspaceID,
+		TodoID:      after.ID,
+		Version:     after.Version,
+		Action:      action,
+		ActorID:     PrincipalFromContext(ctx).UserID,
+		RequestID:   RequestIDFromContext(ctx),
+		At:          at,
+		Changes:     DiffTodo(before, after),
+	}
+}
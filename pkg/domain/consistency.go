@@ -0,0 +1,36 @@
+package domain
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrVersionConflict is returned by a write expecting another version of the item than the current one
+var ErrVersionConflict = errors.New("todo item was changed by another write")
+
+type primaryKey struct{}
+
+// WithPrimary marks ctx so that its reads go to the primary database and see every committed write
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// UsesPrimary reports whether the reads of ctx must go to the primary database
+func UsesPrimary(ctx context.Context) bool {
+	primary, _ := ctx.Value(primaryKey{}).(bool)
+	return primary
+}
+
+type expectedVersionKey struct{}
+
+// WithExpectedVersion marks ctx so that its writes of an existing todo item fail with
+// ErrVersionConflict unless the item is still at the given version
+func WithExpectedVersion(ctx context.Context, version int64) context.Context {
+	return context.WithValue(ctx, expectedVersionKey{}, version)
+}
+
+// ExpectedVersion returns the version the writes of ctx expect, if any
+func ExpectedVersion(ctx context.Context) (int64, bool) {
+	version, ok := ctx.Value(expectedVersionKey{}).(int64)
+	return version, ok
+}
@@ -0,0 +1,190 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// EventType names a kind of change other services may react to
+type EventType string
+
+const (
+	TodoCreated   EventType = "todo.created"
+	TodoUpdated   EventType = "todo.updated"
+	TodoCompleted EventType = "todo.completed"
+	TodoDeleted   EventType = "todo.deleted"
+)
+
+// Valid reports whether t is one of the known event types
+func (t EventType) Valid() bool {
+	switch t {
+	case TodoCreated, TodoUpdated, TodoCompleted, TodoDeleted:
+		return true
+	}
+	return false
+}
+
+// TodoSnapshot is the state of a todo item carried by its events
+type TodoSnapshot struct {
+	ID          UUID       `json:"id"`
+	ListID      *UUID      `json:"list_id,omitempty"`
+	OwnerID     string     `json:"owner_id,omitempty"`
+	Description string     `json:"description"`
+	DueDate     time.Time  `json:"due_date"`
+	Completed   bool       `json:"completed"`
+	Version     int64      `json:"version"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+}
+
+// Event is a domain event raised by a change of a todo item
+type Event struct {
+	ID   UUID      `json:"id"`
+	Type EventType `json:"type"`
+	// Sequence orders the events of the workspace by the time their changes were committed; it is
+	// the sequence of the version the change recorded
+	Sequence    uint64       `json:"sequence"`
+	WorkspaceID string       `json:"workspace_id"`
+	ActorID     string       `json:"actor_id,omitempty"`
+	RequestID   string       `json:"request_id,omitempty"`
+	At          time.Time    `json:"at"`
+	Todo        TodoSnapshot `json:"todo"`
+}
+
+// NewTodoEvent returns an event of eventType raised by a change of todo, which is its state after the change
+func NewTodoEvent(ctx context.Context, eventType EventType, todo *TodoItem, at time.Time) *Event {
+	snapshot := TodoSnapshot{
+		ID:          todo.ID,
+		ListID:      todo.ListID,
+		OwnerID:     todo.OwnerID,
+		Description: todo.Description,
+		DueDate:     todo.DueDate,
+		Completed:   todo.Completed,
+		Version:     todo.Version,
+	}
+	if todo.DeletedAt.Valid {
+		deletedAt := todo.DeletedAt.Time
+		snapshot.DeletedAt = &deletedAt
+	}
+	return &Event{
+		ID:          NewUUID(),
+		Type:        eventType,
+		WorkspaceID: todo.WorkspaceID,
+		ActorID:     PrincipalFromContext(ctx).UserID,
+		RequestID:   RequestIDFromContext(ctx),
+		At:          at,
+		Todo:        snapshot,
+	}
+}
+
+// The operations below change a TodoItem and return the event the change raises, or nil when
+// nothing changed. Each change takes the next version of the item; the sequence of the event
+// is left to the store, which numbers the changes as they are committed.
+
+// Create starts the history of the new item t, raising TodoCreated
+func (t *TodoItem) Create(ctx context.Context, at time.Time) *Event {
+	t.Version = 1
+	return NewTodoEvent(ctx, TodoCreated, t, at)
+}
+
+// Write merges the fields of values whose clock in clocks is later than the stored one, see
+// Merge. It raises TodoCompleted when the write completes t, and TodoUpdated otherwise.
+func (t *TodoItem) Write(ctx context.Context, values *TodoItem, clocks FieldClocks, at time.Time) *Event {
+	completed := t.Completed
+	if !t.Merge(values, clocks) {
+		return nil
+	}
+	if !completed && t.Completed {
+		return t.changed(ctx, TodoCompleted, at)
+	}
+	return t.changed(ctx, TodoUpdated, at)
+}
+
+// Delete moves t to the trash, raising TodoDeleted
+func (t *TodoItem) Delete(ctx context.Context, at time.Time) *Event {
+	t.DeletedAt = gorm.DeletedAt{Time: at, Valid: true}
+	return t.changed(ctx, TodoDeleted, at)
+}
+
+// Restore takes t out of the trash, raising TodoUpdated
+func (t *TodoItem) Restore(ctx context.Context, at time.Time) *Event {
+	t.DeletedAt = gorm.DeletedAt{}
+	return t.changed(ctx, TodoUpdated, at)
+}
+
+// Revert copies the fields of an earlier version over t, stamping those that change with clock,
+// and raises TodoUpdated. A version of the item in the trash fails with ErrDeletedVersion.
+func (t *TodoItem) Revert(ctx context.Context, version *TodoVersion, clock HLC, at time.Time) (*Event, error) {
+	if version.DeletedAt != nil {
+		return nil, ErrDeletedVersion
+	}
+	before := *t
+	t.ListID, t.Description, t.DueDate, t.Completed = version.ListID, version.Description, version.DueDate, version.Completed
+	if len(DiffTodo(&before, t)) == 0 {
+		return nil, nil
+	}
+	t.Stamp(&before, func() HLC { return clock })
+	return t.changed(ctx, TodoUpdated, at), nil
+}
+
+func (t *TodoItem) changed(ctx context.Context, eventType EventType, at time.Time) *Event {
+	t.Version++
+	return NewTodoEvent(ctx, eventType, t, at)
+}
+
+// EventPublisher delivers domain events to their consumers. Events may be delivered more
+// than once, so consumers should ignore the ids they have already seen.
+type EventPublisher interface {
+	Publish(ctx context.Context, event *Event) error
+}
+
+// OutboxMessage is an event stored in the transaction of the change raising it, waiting to
+// be published. Its ID orders the messages of every workspace by the time they were stored.
+type OutboxMessage struct {
+	ID          uint64     `gorm:"primarykey;autoIncrement"`
+	EventID     UUID       `gorm:"column:event_id;not null;uniqueIndex"`
+	WorkspaceID string     `gorm:"column:workspace_id;not null"`
+	Type        EventType  `gorm:"column:type;not null"`
+	Payload     *Event     `gorm:"column:payload;type:text;serializer:json"`
+	CreatedAt   time.Time  `gorm:"column:created_at;not null"`
+	Attempts    int        `gorm:"column:attempts;not null;default:0"`
+	LastError   string     `gorm:"column:last_error"`
+	PublishedAt *time.Time `gorm:"column:published_at;index"`
+	// DeadAt is set when the relay gave up on the message after its last attempt
+	DeadAt *time.Time `gorm:"column:dead_at"`
+}
+
+func (OutboxMessage) TableName() string { return "outbox" }
+
+// NewOutboxMessage wraps event for the outbox
+func NewOutboxMessage(event *Event) *OutboxMessage {
+	return &OutboxMessage{
+		EventID:     event.ID,
+		WorkspaceID: event.WorkspaceID,
+		Type:        event.Type,
+		Payload:     event,
+		CreatedAt:   event.At,
+	}
+}
+
+// Event returns the stored event
+func (m *OutboxMessage) Event() *Event {
+	event := *m.Payload
+	return &event
+}
+
+// OutboxRepository is read by the relay publishing stored events. It is not scoped to a workspace.
+type OutboxRepository interface {
+	// Pending returns up to limit messages that are neither published nor dead, in the order
+	// they were stored, and claims them for the transaction of ctx. It returns none while
+	// another transaction holds the oldest of them.
+	Pending(ctx context.Context, limit int) ([]*OutboxMessage, error)
+	MarkPublished(ctx context.Context, id uint64, at time.Time) error
+	// MarkFailed counts a failed attempt to publish the message
+	MarkFailed(ctx context.Context, id uint64, cause error) error
+	// MarkDead counts the last failed attempt to publish the message, which is not pending anymore
+	MarkDead(ctx context.Context, id uint64, cause error, at time.Time) error
+	// PurgePublished removes the messages published or dead before the given time
+	PurgePublished(ctx context.Context, before time.Time) (int64, error)
+}
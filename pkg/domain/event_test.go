@@ -0,0 +1,49 @@
+package domain
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTodoItemOperationsRaiseEvents(t *testing.T) {
+	ctx := WithPrincipal(context.Background(), Principal{UserID: "alice"})
+	at := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	clock := HLC{Wall: at.UnixMilli(), Node: "server"}
+	todo := &TodoItem{WorkspaceID: "team-a", ID: NewUUID(), Description: "Draft"}
+
+	event := todo.Create(ctx, at)
+	assert.Equal(t, TodoCreated, event.Type)
+	assert.Equal(t, int64(1), event.Todo.Version)
+	assert.Equal(t, "team-a", event.WorkspaceID)
+	assert.Equal(t, "alice", event.ActorID)
+
+	event = todo.Write(ctx, &TodoItem{Description: "Final"}, FieldClocks{FieldDescription: clock}, at)
+	assert.Equal(t, TodoUpdated, event.Type)
+	assert.Equal(t, "Final", event.Todo.Description)
+	assert.Nil(t, todo.Write(ctx, &TodoItem{Description: "Stale"}, FieldClocks{FieldDescription: clock}, at), "writes that lose every field raise nothing")
+
+	later := HLC{Wall: clock.Wall + 1, Node: "server"}
+	event = todo.Write(ctx, &TodoItem{Completed: true}, FieldClocks{FieldCompleted: later}, at)
+	assert.Equal(t, TodoCompleted, event.Type)
+	assert.Equal(t, int64(3), todo.Version)
+
+	event = todo.Delete(ctx, at)
+	assert.Equal(t, TodoDeleted, event.Type)
+	assert.Equal(t, &at, event.Todo.DeletedAt)
+	event = todo.Restore(ctx, at)
+	assert.Equal(t, TodoUpdated, event.Type)
+	assert.Nil(t, event.Todo.DeletedAt)
+
+	event, err := todo.Revert(ctx, &TodoVersion{Description: "Draft"}, HLC{Wall: clock.Wall + 2, Node: "server"}, at)
+	assert.NoError(t, err)
+	assert.Equal(t, TodoUpdated, event.Type)
+	assert.Equal(t, TodoSnapshot{ID: todo.ID, Description: "Draft", Version: 6}, event.Todo)
+	event, err = todo.Revert(ctx, &TodoVersion{Description: "Draft"}, HLC{Wall: clock.Wall + 3, Node: "server"}, at)
+	assert.NoError(t, err)
+	assert.Nil(t, event, "reverting to the current state raises nothing")
+	_, err = todo.Revert(ctx, &TodoVersion{DeletedAt: &at}, later, at)
+	assert.ErrorIs(t, err, ErrDeletedVersion)
+}
@@ -0,0 +1,9 @@
+package domain
+
+import "context"
+
+// HealthChecker reports whether a dependency of the service can be used
+type HealthChecker interface {
+	// Check returns nil when the dependency answers, or the error that kept it from answering
+	Check(ctx context.Context) error
+}
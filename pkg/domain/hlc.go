@@ -0,0 +1,237 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrInvalidHLC is returned when parsing a malformed hybrid logical clock timestamp
+var ErrInvalidHLC = errors.New("invalid hybrid logical clock timestamp")
+
+// HLC is a hybrid logical clock timestamp. It orders the writes of all nodes like their wall
+// clocks do, while never going backwards on a node, and Node tells apart writes made at the same
+// time on different nodes. Each write has its own timestamp, so two timestamps are only equal
+// for the same write.
+type HLC struct {
+	// Wall is the physical part, in milliseconds since the Unix epoch
+	Wall int64
+	// Logical counts the writes of the same millisecond
+	Logical uint32
+	Node    string
+}
+
+// Compare returns -1, 0 or +1 when h is before, the same as, or after other
+func (h HLC) Compare(other HLC) int {
+	switch {
+	case h.Wall != other.Wall:
+		return cmpInt(h.Wall, other.Wall)
+	case h.Logical != other.Logical:
+		return cmpInt(h.Logical, other.Logical)
+	}
+	return strings.Compare(h.Node, other.Node)
+}
+
+func cmpInt[T int64 | uint32](a, b T) int {
+	if a < b {
+		return -1
+	}
+	return 1
+}
+
+// After reports whether h is later than other
+func (h HLC) After(other HLC) bool {
+	return h.Compare(other) > 0
+}
+
+// IsZero reports whether h is the zero timestamp, which is before all others
+func (h HLC) IsZero() bool {
+	return h == HLC{}
+}
+
+// Time returns the physical part of h
+func (h HLC) Time() time.Time {
+	return time.UnixMilli(h.Wall)
+}
+
+// String formats h as <wall>-<logical>-<node>
+func (h HLC) String() string {
+	return strconv.FormatInt(h.Wall, 10) + "-" + strconv.FormatUint(uint64(h.Logical), 10) + "-" + h.Node
+}
+
+// ParseHLC reads a timestamp formatted by HLC.String
+func ParseHLC(s string) (HLC, error) {
+	parts := strings.SplitN(s, "-", 3)
+	if len(parts) != 3 || parts[2] == "" {
+		return HLC{}, ErrInvalidHLC
+	}
+	wall, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || wall < 0 {
+		return HLC{}, ErrInvalidHLC
+	}
+	logical, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil {
+		return HLC{}, ErrInvalidHLC
+	}
+	return HLC{Wall: wall, Logical: uint32(logical), Node: parts[2]}, nil
+}
+
+func (h HLC) MarshalText() ([]byte, error) {
+	return []byte(h.String()), nil
+}
+
+func (h *HLC) UnmarshalText(text []byte) error {
+	parsed, err := ParseHLC(string(text))
+	if err != nil {
+		return err
+	}
+	*h = parsed
+	return nil
+}
+
+// HLCClock hands out the timestamps of the writes of one node
+type HLCClock struct {
+	mu   sync.Mutex
+	last HLC
+	now  func() time.Time
+}
+
+// NewHLCClock creates the clock of node
+func NewHLCClock(node string) *HLCClock {
+	return &HLCClock{last: HLC{Node: node}, now: time.Now}
+}
+
+// Now returns a timestamp after every timestamp the clock handed out or observed
+func (c *HLCClock) Now() HLC {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if wall := c.now().UnixMilli(); wall > c.last.Wall {
+		c.last.Wall, c.last.Logical = wall, 0
+	} else {
+		c.last.Logical++
+	}
+	return c.last
+}
+
+// Observe moves the clock past a timestamp received from another node, so that the writes
+// made after it here are ordered after it
+func (c *HLCClock) Observe(remote HLC) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if remote.Wall > c.last.Wall || remote.Wall == c.last.Wall && remote.Logical > c.last.Logical {
+		c.last.Wall, c.last.Logical = remote.Wall, remote.Logical
+	}
+}
+
+// Fields of a todo item that are last-writer-wins registers, by their JSON names
+const (
+	FieldDescription = "description"
+	FieldDueDate     = "due_date"
+	FieldCompleted   = "completed"
+	FieldListID      = "list_id"
+)
+
+// RegisterFields are the fields of a todo item that merge on their own
+var RegisterFields = []string{FieldDescription, FieldDueDate, FieldCompleted, FieldListID}
+
+// FieldClocks holds the timestamp of the last write of each register field of a todo item
+type FieldClocks map[string]HLC
+
+// Join returns the latest timestamp of every field of c and other
+func (c FieldClocks) Join(other FieldClocks) FieldClocks {
+	joined := maps.Clone(c)
+	if joined == nil {
+		joined = FieldClocks{}
+	}
+	for field, clock := range other {
+		if clock.After(joined[field]) {
+			joined[field] = clock
+		}
+	}
+	return joined
+}
+
+// Value stores the clocks as JSON
+func (c FieldClocks) Value() (driver.Value, error) {
+	if len(c) == 0 {
+		return nil, nil
+	}
+	b, err := json.Marshal(c)
+	return string(b), err
+}
+
+// Scan reads the clocks stored by Value
+func (c *FieldClocks) Scan(src any) error {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		*c = nil
+		return nil
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return fmt.Errorf("cannot scan %T into FieldClocks", src)
+	}
+	return json.Unmarshal(data, c)
+}
+
+// Merge applies the fields of values whose clock in clocks is later than the one of t, so that
+// the last write of every field wins. Merging the same writes in any order, any number of times,
+// gives the same item. It reports whether t changed.
+func (t *TodoItem) Merge(values *TodoItem, clocks FieldClocks) bool {
+	changed := false
+	for field, clock := range clocks {
+		if !clock.After(t.Clocks[field]) {
+			continue
+		}
+		switch field {
+		case FieldDescription:
+			t.Description = values.Description
+		case FieldDueDate:
+			t.DueDate = values.DueDate
+		case FieldCompleted:
+			t.Completed = values.Completed
+		case FieldListID:
+			t.ListID = values.ListID
+		default:
+			continue
+		}
+		if !changed {
+			// the clocks of t may be shared with other copies of the item
+			t.Clocks = maps.Clone(t.Clocks)
+			if t.Clocks == nil {
+				t.Clocks = FieldClocks{}
+			}
+			changed = true
+		}
+		t.Clocks[field] = clock
+	}
+	return changed
+}
+
+// Stamp sets the clock of every register field that changed from before without a later clock
+// to the time of now, the clock of the node making the write
+func (t *TodoItem) Stamp(before *TodoItem, now func() HLC) {
+	diff := DiffTodo(before, t)
+	t.Clocks = maps.Clone(t.Clocks)
+	if t.Clocks == nil {
+		t.Clocks = FieldClocks{}
+	}
+	for _, field := range RegisterFields {
+		var prev HLC
+		if before != nil {
+			prev = before.Clocks[field]
+		}
+		if _, ok := diff[field]; (ok || before == nil) && !t.Clocks[field].After(prev) {
+			t.Clocks[field] = now()
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHLCClock(t *testing.T) {
+	now := time.UnixMilli(1_000)
+	clock := NewHLCClock("server")
+	clock.now = func() time.Time { return now }
+
+	first, second := clock.Now(), clock.Now()
+	assert.Equal(t, HLC{Wall: 1_000, Logical: 0, Node: "server"}, first)
+	assert.True(t, second.After(first), "timestamps of the same millisecond are counted")
+
+	clock.Observe(HLC{Wall: 5_000, Logical: 7, Node: "phone"})
+	assert.Equal(t, HLC{Wall: 5_000, Logical: 8, Node: "server"}, clock.Now(), "a clock ahead of the wall clock is followed")
+	now = time.UnixMilli(9_000)
+	assert.Equal(t, HLC{Wall: 9_000, Logical: 0, Node: "server"}, clock.Now())
+
+	parsed, err := ParseHLC(second.String())
+	assert.NoError(t, err)
+	assert.Equal(t, second, parsed)
+	parsed, err = ParseHLC("12-3-node-with-dashes")
+	assert.NoError(t, err)
+	assert.Equal(t, "node-with-dashes", parsed.Node)
+	for _, invalid := range []string{"", "12-3", "x-3-node", "12-x-node", "12-3-"} {
+		_, err := ParseHLC(invalid)
+		assert.ErrorIs(t, err, ErrInvalidHLC, invalid)
+	}
+}
+
+// permutations returns every order of the indexes below n
+func permutations(n int) [][]int {
+	if n == 0 {
+		return [][]int{{}}
+	}
+	var out [][]int
+	for _, perm := range permutations(n - 1) {
+		for i := 0; i <= len(perm); i++ {
+			next := append(append(append([]int{}, perm[:i]...), n-1), perm[i:]...)
+			out = append(out, next)
+		}
+	}
+	return out
+}
+
+func TestTodoMerge(t *testing.T) {
+	at := func(wall int64, node string) HLC { return HLC{Wall: wall, Node: node} }
+	listA, listB := NewUUID(), NewUUID()
+	base := TodoItem{Description: "Base", DueDate: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+		Clocks: FieldClocks{FieldDescription: at(1, "server"), FieldDueDate: at(1, "server")}}
+
+	type write struct {
+		values TodoItem
+		clocks FieldClocks
+	}
+	writes := []write{
+		// the phone renames the item and completes it offline
+		{TodoItem{Description: "Phone", Completed: true}, FieldClocks{FieldDescription: at(10, "phone"), FieldCompleted: at(10, "phone")}},
+		// the laptop moves its due date and list, and renames it later than the phone
+		{TodoItem{DueDate: time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC), ListID: &listA}, FieldClocks{FieldDueDate: at(11, "laptop"), FieldListID: at(11, "laptop")}},
+		{TodoItem{Description: "Laptop"}, FieldClocks{FieldDescription: at(12, "laptop")}},
+		// the tablet moves it to another list at the same millisecond; the node breaks the tie
+		{TodoItem{ListID: &listB}, FieldClocks{FieldListID: at(11, "tablet")}},
+		// a write older than the stored one loses
+		{TodoItem{Description: "Stale", Completed: false}, FieldClocks{FieldDescription: at(0, "watch"), FieldCompleted: at(5, "watch")}},
+		// the same write again changes nothing
+		{TodoItem{Description: "Phone", Completed: true}, FieldClocks{FieldDescription: at(10, "phone"), FieldCompleted: at(10, "phone")}},
+	}
+
+	var expected *TodoItem
+	for _, order := range permutations(len(writes)) {
+		merged := base
+		for _, i := range order {
+			merged.Merge(&writes[i].values, writes[i].clocks)
+		}
+		if expected == nil {
+			expected = &merged
+			continue
+		}
+		if !assert.Equal(t, *expected, merged, "merging in the order %v", order) {
+			break
+		}
+	}
+	assert.Equal(t, "Laptop", expected.Description)
+	assert.Equal(t, time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC), expected.DueDate)
+	assert.True(t, expected.Completed, "edits of different fields both survive")
+	assert.Equal(t, &listB, expected.ListID)
+	assert.Equal(t, FieldClocks{FieldDescription: at(1, "server"), FieldDueDate: at(1, "server")}, base.Clocks, "merging leaves other copies of the item alone")
+
+	assert.False(t, expected.Merge(&writes[0].values, writes[0].clocks), "a merged write is not applied again")
+}
+
+func TestTodoStamp(t *testing.T) {
+	var ticks int64
+	now := func() HLC { ticks++; return HLC{Wall: ticks, Node: "server"} }
+	created := &TodoItem{Description: "New", Clocks: FieldClocks{FieldCompleted: {Wall: 100, Node: "phone"}}}
+	created.Stamp(nil, now)
+	assert.Len(t, created.Clocks, len(RegisterFields), "a created item gets a clock for every field")
+	assert.Equal(t, HLC{Wall: 100, Node: "phone"}, created.Clocks[FieldCompleted], "clocks of the writer are kept")
+
+	updated := *created
+	updated.Description = "Renamed"
+	updated.Stamp(created, now)
+	assert.True(t, updated.Clocks[FieldDescription].After(created.Clocks[FieldDescription]))
+	assert.Equal(t, created.Clocks[FieldDueDate], updated.Clocks[FieldDueDate], "unchanged fields keep their clock")
+	assert.Equal(t, "New", created.Description)
+}
@@ -0,0 +1,38 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// IdempotencyRecord remembers the outcome of a request sent with an Idempotency-Key header.
+// A record with a zero StatusCode belongs to a request that is still being processed.
+type IdempotencyRecord struct {
+	WorkspaceID string    `gorm:"column:workspace_id;primaryKey"`
+	ClientID    string    `gorm:"column:client_id;primaryKey"`
+	Key         string    `gorm:"column:key;primaryKey"`
+	Fingerprint string    `gorm:"column:fingerprint;not null"`
+	StatusCode  int       `gorm:"column:status_code"`
+	Body        []byte    `gorm:"column:body"`
+	ExpiresAt   time.Time `gorm:"column:expires_at;index"`
+}
+
+func (IdempotencyRecord) TableName() string {
+	return "idempotency_keys"
+}
+
+// Completed reports whether the response of the request has been stored
+func (r *IdempotencyRecord) Completed() bool {
+	return r.StatusCode != 0
+}
+
+type IdempotencyRepository interface {
+	// Reserve stores a pending record, failing with ErrConflict while an unexpired record holds the key
+	Reserve(ctx context.Context, rec *IdempotencyRecord) error
+	Get(ctx context.Context, clientID, key string) (*IdempotencyRecord, error)
+	// Complete stores the response of a reserved request
+	Complete(ctx context.Context, clientID, key string, statusCode int, body []byte) error
+	// Release drops a reservation so that the request can be retried
+	Release(ctx context.Context, clientID, key string) error
+	PurgeExpired(ctx context.Context, now time.Time) (int64, error)
+}
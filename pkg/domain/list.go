@@ -0,0 +1,77 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrConflict is returned when a write collides with an existing record
+var ErrConflict = errors.New("conflicting record")
+
+// SameList reports whether a and b are the same list, where nil is no list
+func SameList(a, b *UUID) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// Role is the level of access a member has on a shared list
+type Role string
+
+const (
+	RoleViewer Role = "viewer"
+	RoleEditor Role = "editor"
+	RoleAdmin  Role = "admin"
+)
+
+var roleRanks = map[Role]int{RoleViewer: 1, RoleEditor: 2, RoleAdmin: 3}
+
+// Valid reports whether r is one of the known roles
+func (r Role) Valid() bool {
+	_, ok := roleRanks[r]
+	return ok
+}
+
+// Includes reports whether r grants at least the access of required
+func (r Role) Includes(required Role) bool {
+	return r.Valid() && roleRanks[r] >= roleRanks[required]
+}
+
+// List is a shared collection of todo items, keyed by its workspace and its id like TodoItem
+type List struct {
+	WorkspaceID string    `gorm:"column:workspace_id;primaryKey;not null;default:default;uniqueIndex:idx_lists_workspace_name,priority:1"`
+	ID          UUID      `gorm:"column:id;primaryKey"`
+	Name        string    `gorm:"column:name;not null;uniqueIndex:idx_lists_workspace_name,priority:2"`
+	CreatedBy   string    `gorm:"column:created_by;not null"`
+	CreatedAt   time.Time `gorm:"column:created_at"`
+}
+
+// Membership grants a user a role on a list
+type Membership struct {
+	WorkspaceID string    `gorm:"column:workspace_id;primaryKey;not null;default:default"`
+	ListID      UUID      `gorm:"column:list_id;primaryKey"`
+	UserID      string    `gorm:"column:user_id;primaryKey"`
+	Role        Role      `gorm:"column:role;not null"`
+	CreatedAt   time.Time `gorm:"column:created_at"`
+}
+
+func (Membership) TableName() string {
+	return "list_memberships"
+}
+
+type ListRepository interface {
+	// Create stores the list and makes its creator an admin member
+	Create(ctx context.Context, list *List) error
+	GetByID(ctx context.Context, id UUID) (*List, error)
+	// GetByIDs returns the lists with the given ids in one read, in no particular order
+	GetByIDs(ctx context.Context, ids []UUID) ([]*List, error)
+}
+
+type MembershipRepository interface {
+	Get(ctx context.Context, listID UUID, userID string) (*Membership, error)
+	List(ctx context.Context, listID UUID) ([]Membership, error)
+	Add(ctx context.Context, m *Membership) error
+	UpdateRole(ctx context.Context, listID UUID, userID string, role Role) error
+}
@@ -0,0 +1,51 @@
+package domain
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	// ErrForbidden is returned when the principal may not perform an action
+	ErrForbidden = errors.New("forbidden")
+	// ErrUnauthenticated is returned when an action requires an authenticated principal
+	ErrUnauthenticated = errors.New("authentication required")
+)
+
+// Action names an operation subject to authorization
+type Action string
+
+const (
+	ActionCreateTodo    Action = "todo:create"
+	ActionReadTodo      Action = "todo:read"
+	ActionUpdateTodo    Action = "todo:update"
+	ActionDeleteTodo    Action = "todo:delete"
+	ActionCreateList    Action = "list:create"
+	ActionReadList      Action = "list:read"
+	ActionManageMembers Action = "list:manage_members"
+	// ActionManageWebhook is checked against a Resource owned by the webhook owner
+	ActionManageWebhook Action = "webhook:manage"
+)
+
+// Resource describes what an action is performed on
+type Resource struct {
+	// ListID is the list the resource belongs to, if any
+	ListID *UUID
+	// OwnerID is the user owning an item outside of any list; empty for anonymous items
+	OwnerID string
+}
+
+// TodoResource describes a todo item for authorization
+func TodoResource(todo *TodoItem) Resource {
+	return Resource{ListID: todo.ListID, OwnerID: todo.OwnerID}
+}
+
+// ListResource describes a list for authorization
+func ListResource(id UUID) Resource {
+	return Resource{ListID: &id}
+}
+
+// Policy decides whether the principal of ctx may perform an action on a resource
+type Policy interface {
+	Authorize(ctx context.Context, action Action, resource Resource) error
+}
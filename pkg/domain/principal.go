@@ -0,0 +1,26 @@
+package domain
+
+import "context"
+
+// Principal is the authenticated caller of a request; a zero Principal is anonymous
+type Principal struct {
+	UserID string
+}
+
+// Anonymous reports whether the principal could not be authenticated
+func (p Principal) Anonymous() bool {
+	return p.UserID == ""
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the given principal
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext returns the principal carried by ctx, or an anonymous one
+func PrincipalFromContext(ctx context.Context) Principal {
+	p, _ := ctx.Value(principalKey{}).(Principal)
+	return p
+}
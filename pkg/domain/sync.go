@@ -0,0 +1,37 @@
+package domain
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrResyncRequired is returned for a sync cursor from before changes that are no longer kept.
+// The client has to sync again from the start.
+var ErrResyncRequired = errors.New("sync token is too old, a full sync is required")
+
+// ChangeCounter holds the last change sequence given out in a workspace. Every write of todo
+// items takes the next numbers from it in the transaction of the write, which keeps the counter
+// locked until the write commits, so the changes of a workspace commit in sequence order.
+type ChangeCounter struct {
+	WorkspaceID string `gorm:"column:workspace_id;primarykey"`
+	Value       int64  `gorm:"column:value;not null;default:0"`
+	// PurgedThrough is the sequence of the latest change removed by a purge of the trash. A sync
+	// cursor before it may have missed the deletion of a purged item.
+	PurgedThrough int64 `gorm:"column:purged_through;not null;default:0"`
+}
+
+func (ChangeCounter) TableName() string { return "change_counters" }
+
+// SyncCursor is a position in the changes of a workspace, after the change of TodoID with Sequence.
+// The zero cursor is the position before all changes.
+type SyncCursor struct {
+	Sequence int64
+	TodoID   UUID
+}
+
+type SyncRepository interface {
+	// Changes returns the current versions of the items changed after cursor, in the order of
+	// their last change. A version whose DeletedAt is set is the tombstone of a deleted item.
+	// A cursor from before the changes removed by a purge fails with ErrResyncRequired.
+	Changes(ctx context.Context, after SyncCursor, limit int) ([]*TodoVersion, error)
+}
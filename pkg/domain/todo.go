@@ -3,15 +3,94 @@ package domain
 import (
 	"context"
 	"time"
+
+	"gorm.io/gorm"
 )
 
+// TodoItem is keyed by its workspace and its id, so that the ids of one workspace say nothing
+// about those of another
 type TodoItem struct {
-	ID          UUID      `gorm:"id,primarykey"`
+	WorkspaceID string `gorm:"column:workspace_id;primaryKey;not null;default:default"`
+	ID          UUID   `gorm:"column:id;primaryKey"`
+	// ListID is the shared list of the item. It stays optional so that items created before
+	// lists, and personal items nobody else should see, need no list; those are governed by
+	// OwnerID instead, which is empty only for items created while authentication is off.
+	ListID      *UUID     `gorm:"column:list_id;index"`
+	OwnerID     string    `gorm:"column:owner_id"`
 	Description string    `gorm:"description"`
 	DueDate     time.Time `gorm:"due_date"`
+	Completed   bool      `gorm:"column:completed;not null;default:false"`
+	// Version counts the writes of the item, starting at 1
+	Version int64 `gorm:"column:version;not null;default:1"`
+	// Clocks are the timestamps of the last writes of the register fields, by which concurrent
+	// offline edits merge
+	Clocks FieldClocks `gorm:"column:field_clocks;type:text"`
+	// DeletedAt is set while the item is in the trash
+	DeletedAt gorm.DeletedAt `gorm:"column:deleted_at;index"`
+}
+
+const (
+	// DefaultPageSize is the number of entries of a listing when the request does not ask for another
+	DefaultPageSize = 50
+	// MaxPageSize is the largest number of entries of a listing
+	MaxPageSize = 200
+)
+
+// Page selects a slice of a listing
+type Page struct {
+	Limit  int
+	Offset int
+}
+
+// TodoFilter narrows a listing of todo items; zero fields match every item
+type TodoFilter struct {
+	ListID    *UUID
+	OwnerID   string
+	Completed *bool
 }
 
 type TodoRepository interface {
 	Create(ctx context.Context, todo *TodoItem) error
+	// CreateMany stores all items with multi-row inserts
+	CreateMany(ctx context.Context, todos []*TodoItem) error
+	// GetByID fails with ErrRecordNotFound for items in the trash, unless ctx comes from WithDeleted
 	GetByID(ctx context.Context, id UUID) (*TodoItem, error)
+	// GetByIDs returns the items with the given ids in one read, in no particular order; ids
+	// without an item, or of items in the trash unless ctx comes from WithDeleted, are left out
+	GetByIDs(ctx context.Context, ids []UUID) ([]*TodoItem, error)
+	// Update sets the fields of an existing item whose clocks are later than the stored ones,
+	// as TodoItem.Merge does, leaving todo holding the stored item. It fails with
+	// ErrRecordNotFound when there is none.
+	Update(ctx context.Context, todo *TodoItem) error
+	// Delete moves an item to the trash, failing with ErrRecordNotFound when there is none
+	Delete(ctx context.Context, id UUID) error
+	// List returns the items outside the trash matching filter, by due date and then id
+	List(ctx context.Context, filter TodoFilter, page Page) ([]*TodoItem, error)
+	// ListDeleted returns the items in the trash, most recently deleted first
+	ListDeleted(ctx context.Context, page Page) ([]*TodoItem, error)
+	// Restore takes an item out of the trash, failing with ErrRecordNotFound when it is not there
+	Restore(ctx context.Context, id UUID) error
+	// PurgeDeleted permanently removes the items of every workspace deleted before the given time
+	PurgeDeleted(ctx context.Context, before time.Time) (int64, error)
+	// GetAsOf returns the item as it was at the given time, failing with ErrRecordNotFound when it
+	// did not exist then; an item in the trash at that time is only found when ctx comes from WithDeleted
+	GetAsOf(ctx context.Context, id UUID, at time.Time) (*TodoItem, error)
+	// Revert writes the fields of an earlier version over the item, stamping the fields it changes
+	// with at, and records the result as a new version. It fails with ErrRecordNotFound when the
+	// item or the version does not exist, and with ErrDeletedVersion when the item was deleted in
+	// that version.
+	Revert(ctx context.Context, id UUID, version int64, at HLC) (*TodoItem, error)
+}
+
+type deletedKey struct{}
+
+// WithDeleted marks ctx so that GetByID also finds items in the trash
+func WithDeleted(ctx context.Context) context.Context {
+	return context.WithValue(ctx, deletedKey{}, true)
+}
+
+// IncludesDeleted reports whether the reads of ctx also find items in the trash
+func IncludesDeleted(ctx context.Context) bool {
+	deleted, _ := ctx.Value(deletedKey{}).(bool)
+	return deleted
 }
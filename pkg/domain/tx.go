@@ -0,0 +1,9 @@
+package domain
+
+import "context"
+
+// TxManager runs units of work atomically. Repositories called with the context passed to fn
+// take part in the transaction; nested calls run in a savepoint of the enclosing transaction.
+type TxManager interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
@@ -0,0 +1,75 @@
+package domain
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrDeletedVersion is returned when reverting to a version in which the item was deleted
+var ErrDeletedVersion = errors.New("version is a deleted state of the item")
+
+// TodoVersion is the state of a todo item between ValidFrom and ValidTo.
+// Every write of an item closes its current version and opens the next one,
+// so the versions of an item cover its whole life without gaps.
+type TodoVersion struct {
+	WorkspaceID string      `gorm:"column:workspace_id;primarykey;index:idx_todo_item_versions_sequence,priority:1"`
+	TodoID      UUID        `gorm:"column:todo_id;primarykey"`
+	Version     int64       `gorm:"column:version;primarykey;autoIncrement:false"`
+	ListID      *UUID       `gorm:"column:list_id"`
+	OwnerID     string      `gorm:"column:owner_id"`
+	Description string      `gorm:"column:description"`
+	DueDate     time.Time   `gorm:"column:due_date"`
+	Completed   bool        `gorm:"column:completed;not null;default:false"`
+	DeletedAt   *time.Time  `gorm:"column:deleted_at"`
+	Clocks      FieldClocks `gorm:"column:field_clocks;type:text"`
+	ValidFrom   time.Time   `gorm:"column:valid_from;not null"`
+	// Sequence orders the versions of the workspace by the time they were committed. Versions
+	// backfilled by the migration have none.
+	Sequence int64 `gorm:"column:sequence;not null;default:0;index:idx_todo_item_versions_sequence,priority:2"`
+	// ValidTo is nil for the current version
+	ValidTo *time.Time `gorm:"column:valid_to"`
+}
+
+func (TodoVersion) TableName() string { return "todo_item_versions" }
+
+// NewTodoVersion records the state of todo starting at the given time
+func NewTodoVersion(todo *TodoItem, at time.Time) *TodoVersion {
+	v := &TodoVersion{
+		WorkspaceID: todo.WorkspaceID,
+		TodoID:      todo.ID,
+		Version:     todo.Version,
+		ListID:      todo.ListID,
+		OwnerID:     todo.OwnerID,
+		Description: todo.Description,
+		DueDate:     todo.DueDate,
+		Completed:   todo.Completed,
+		Clocks:      todo.Clocks,
+		ValidFrom:   at,
+	}
+	if todo.DeletedAt.Valid {
+		deletedAt := todo.DeletedAt.Time
+		v.DeletedAt = &deletedAt
+	}
+	return v
+}
+
+// Item returns the todo item as it was in this version
+func (v *TodoVersion) Item() *TodoItem {
+	todo := &TodoItem{
+		ID:          v.TodoID,
+		WorkspaceID: v.WorkspaceID,
+		ListID:      v.ListID,
+		OwnerID:     v.OwnerID,
+		Description: v.Description,
+		DueDate:     v.DueDate,
+		Completed:   v.Completed,
+		Version:     v.Version,
+		Clocks:      v.Clocks,
+	}
+	if v.DeletedAt != nil {
+		todo.DeletedAt = gorm.DeletedAt{Time: *v.DeletedAt, Valid: true}
+	}
+	return todo
+}
@@ -0,0 +1,104 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+)
+
+// ErrClaimLost is returned when the outcome of an attempt is saved after its claim expired
+var ErrClaimLost = errors.New("webhook delivery was claimed again")
+
+// Webhook subscribes a URL to the todo events of a workspace. Deliveries are signed with Secret
+// and only carry the events of items the owner of the webhook may read.
+type Webhook struct {
+	ID          UUID   `gorm:"column:id;primaryKey"`
+	WorkspaceID string `gorm:"column:workspace_id;not null;index"`
+	OwnerID     string `gorm:"column:owner_id;not null"`
+	URL         string `gorm:"column:url;not null"`
+	Secret      string `gorm:"column:secret;not null"`
+	// Events lists the event types delivered; empty subscribes to all of them
+	Events    []EventType `gorm:"column:events;type:text;serializer:json"`
+	Active    bool        `gorm:"column:active;not null;default:true"`
+	CreatedAt time.Time   `gorm:"column:created_at"`
+}
+
+func (Webhook) TableName() string { return "webhooks" }
+
+// Wants reports whether the webhook subscribes to events of the given type
+func (w *Webhook) Wants(eventType EventType) bool {
+	return w.Active && (len(w.Events) == 0 || slices.Contains(w.Events, eventType))
+}
+
+// DeliveryStatus is the state of the delivery of an event to a webhook
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliveryDelivered DeliveryStatus = "delivered"
+	// DeliveryDead is a delivery that failed every attempt; it is only retried on request
+	DeliveryDead DeliveryStatus = "dead"
+)
+
+// WebhookDelivery is the delivery of one event to one webhook, and the log of its attempts
+type WebhookDelivery struct {
+	ID          uint64         `gorm:"primarykey;autoIncrement"`
+	WorkspaceID string         `gorm:"column:workspace_id;not null"`
+	WebhookID   UUID           `gorm:"column:webhook_id;not null;uniqueIndex:idx_webhook_deliveries_event,priority:1"`
+	EventID     UUID           `gorm:"column:event_id;not null;uniqueIndex:idx_webhook_deliveries_event,priority:2"`
+	EventType   EventType      `gorm:"column:event_type;not null"`
+	Payload     []byte         `gorm:"column:payload;not null"`
+	Status      DeliveryStatus `gorm:"column:status;not null;index:idx_webhook_deliveries_due,priority:1"`
+	Attempts    int            `gorm:"column:attempts;not null;default:0"`
+	// NextAttemptAt is when a pending delivery is due
+	NextAttemptAt  time.Time  `gorm:"column:next_attempt_at;not null;index:idx_webhook_deliveries_due,priority:2"`
+	LastStatusCode int        `gorm:"column:last_status_code"`
+	LastError      string     `gorm:"column:last_error"`
+	CreatedAt      time.Time  `gorm:"column:created_at;not null"`
+	DeliveredAt    *time.Time `gorm:"column:delivered_at"`
+}
+
+func (WebhookDelivery) TableName() string { return "webhook_deliveries" }
+
+// DeliveryClaim is the state of a delivery when it was claimed
+type DeliveryClaim struct {
+	Attempts int
+	Until    time.Time
+}
+
+// Claim returns the claim of a delivery as returned by Claim
+func (d *WebhookDelivery) Claim() DeliveryClaim {
+	return DeliveryClaim{Attempts: d.Attempts, Until: d.NextAttemptAt}
+}
+
+// WebhookRepository stores the webhooks of the workspace of ctx
+type WebhookRepository interface {
+	Create(ctx context.Context, webhook *Webhook) error
+	GetByID(ctx context.Context, id UUID) (*Webhook, error)
+	// List returns the webhooks owned by ownerID, oldest first
+	List(ctx context.Context, ownerID string) ([]*Webhook, error)
+	Update(ctx context.Context, webhook *Webhook) error
+	// Delete removes a webhook together with its deliveries
+	Delete(ctx context.Context, id UUID) error
+	// Subscribed returns the active webhooks of the workspace subscribed to eventType
+	Subscribed(ctx context.Context, eventType EventType) ([]*Webhook, error)
+}
+
+// WebhookDeliveryRepository stores the deliveries of webhooks
+type WebhookDeliveryRepository interface {
+	// Enqueue stores pending deliveries, skipping those of an event already enqueued for the webhook
+	Enqueue(ctx context.Context, deliveries []*WebhookDelivery) error
+	// Claim returns up to limit pending deliveries of every workspace due at now, and postpones
+	// them by lease so that no other worker claims them meanwhile. The NextAttemptAt of the
+	// claimed deliveries is the end of their lease.
+	Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*WebhookDelivery, error)
+	// Save stores the outcome of an attempt at a delivery claimed as claim, failing with
+	// ErrClaimLost when the delivery was changed since, e.g. claimed again after the lease ended
+	Save(ctx context.Context, delivery *WebhookDelivery, claim DeliveryClaim) error
+	// List returns the deliveries of a webhook in the workspace of ctx, most recent first,
+	// optionally only those with the given status
+	List(ctx context.Context, webhookID UUID, status DeliveryStatus, page Page) ([]*WebhookDelivery, error)
+	// Retry makes a dead delivery pending again, failing with ErrRecordNotFound for other deliveries
+	Retry(ctx context.Context, webhookID UUID, id uint64, now time.Time) error
+}
@@ -0,0 +1,52 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"regexp"
+)
+
+// DefaultWorkspaceID is the workspace used when a request does not name one
+const DefaultWorkspaceID = "default"
+
+var (
+	// ErrTodoQuotaExceeded is returned when a workspace already holds its maximum number of todos
+	ErrTodoQuotaExceeded = errors.New("workspace todo quota exceeded")
+	// ErrDescriptionTooLarge is returned when a description exceeds the workspace size quota
+	ErrDescriptionTooLarge = errors.New("description exceeds workspace quota")
+)
+
+var workspaceIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+// ValidWorkspaceID reports whether id may name a workspace: up to 63 lowercase letters,
+// digits and dashes, not starting with a dash
+func ValidWorkspaceID(id string) bool {
+	return workspaceIDPattern.MatchString(id)
+}
+
+// Quota bounds what a single workspace may store; zero values mean unlimited
+type Quota struct {
+	MaxTodos           int
+	MaxDescriptionSize int
+}
+
+// Workspace is the tenant that owns todo items; repositories only ever see the data of one workspace
+type Workspace struct {
+	ID    string
+	Quota Quota
+}
+
+type workspaceKey struct{}
+
+// WithWorkspace returns a copy of ctx scoped to the given workspace
+func WithWorkspace(ctx context.Context, ws Workspace) context.Context {
+	return context.WithValue(ctx, workspaceKey{}, ws)
+}
+
+// WorkspaceFromContext returns the workspace carried by ctx, falling back to the default workspace
+func WorkspaceFromContext(ctx context.Context) Workspace {
+	if ws, ok := ctx.Value(workspaceKey{}).(Workspace); ok && ws.ID != "" {
+		return ws
+	}
+	return Workspace{ID: DefaultWorkspaceID}
+}
@@ -0,0 +1,125 @@
+package events
+
+import (
+	"context"
+	"sync"
+
+	"github.com/taheri24/helitask/pkg/config"
+	"github.com/taheri24/helitask/pkg/domain"
+)
+
+// Filter selects the events of a subscription. It runs while the broker is locked, so it must
+// not block.
+type Filter func(event *domain.Event) bool
+
+// Broker fans the events of the bus out to the subscriptions of client streams and keeps the
+// most recent events, so that a client reconnecting with the sequence of the last event it got
+// can be sent the events it missed. Sequences are numbered per workspace, so the broker tracks
+// its position in each workspace on its own.
+type Broker struct {
+	mu           sync.Mutex
+	recent       []*domain.Event
+	replaySize   int
+	clientBuffer int
+	// last and evicted hold per workspace the sequence of the last event seen, and the
+	// sequence up to which events are no longer kept
+	last, evicted map[string]uint64
+	subscriptions map[*Subscription]struct{}
+}
+
+// Subscription is a stream of the events matching a filter. Its channel is closed when the
+// subscription is closed, or when the client fell too far behind to keep up.
+type Subscription struct {
+	broker *Broker
+	filter Filter
+	events chan *domain.Event
+}
+
+// NewBroker creates a Broker fed by bus
+func NewBroker(bus *Bus, cfg *config.Config) *Broker {
+	b := &Broker{
+		replaySize:    max(cfg.Stream.ReplaySize, 0),
+		clientBuffer:  max(cfg.Stream.ClientBuffer, 1),
+		last:          map[string]uint64{},
+		evicted:       map[string]uint64{},
+		subscriptions: map[*Subscription]struct{}{},
+	}
+	bus.Subscribe(b.publish)
+	return b
+}
+
+// publish hands event to the matching subscriptions without waiting for them. The relay
+// publishes the events of a workspace in the order of their sequence, so an event at or below
+// the last sequence seen in its workspace was delivered again and is dropped.
+func (b *Broker) publish(_ context.Context, event *domain.Event) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	last, seen := b.last[event.WorkspaceID]
+	if seen && event.Sequence <= last {
+		return nil
+	}
+	if !seen {
+		// the events of the workspace before the first one seen are unknown
+		b.evicted[event.WorkspaceID] = event.Sequence - 1
+	}
+	b.last[event.WorkspaceID] = event.Sequence
+	if b.replaySize > 0 {
+		if len(b.recent) == b.replaySize {
+			b.evicted[b.recent[0].WorkspaceID] = b.recent[0].Sequence
+			b.recent = append(b.recent[:0], b.recent[1:]...)
+		}
+		b.recent = append(b.recent, event)
+	} else {
+		b.evicted[event.WorkspaceID] = event.Sequence
+	}
+	for sub := range b.subscriptions {
+		if !sub.filter(event) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			b.drop(sub)
+		}
+	}
+	return nil
+}
+
+// Subscribe opens a subscription to the events matching filter. When after is the sequence of
+// the last event a client got in workspaceID, the recent events of the workspace after it are
+// returned for replay, and complete reports whether they are all the events the client missed.
+func (b *Broker) Subscribe(workspaceID string, after uint64, filter Filter) (sub *Subscription, replay []*domain.Event, complete bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	sub = &Subscription{broker: b, filter: filter, events: make(chan *domain.Event, b.clientBuffer)}
+	b.subscriptions[sub] = struct{}{}
+	if after == 0 {
+		return sub, nil, true
+	}
+	for _, event := range b.recent {
+		if event.WorkspaceID == workspaceID && event.Sequence > after && filter(event) {
+			replay = append(replay, event)
+		}
+	}
+	return sub, replay, after >= b.evicted[workspaceID]
+}
+
+// drop closes a subscription; the broker must be locked
+func (b *Broker) drop(sub *Subscription) {
+	if _, ok := b.subscriptions[sub]; ok {
+		delete(b.subscriptions, sub)
+		close(sub.events)
+	}
+}
+
+// Events returns the channel of the events of the subscription
+func (s *Subscription) Events() <-chan *domain.Event {
+	return s.events
+}
+
+// Close ends the subscription
+func (s *Subscription) Close() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	s.broker.drop(s)
+}
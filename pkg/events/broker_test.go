@@ -0,0 +1,69 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taheri24/helitask/pkg/config"
+	"github.com/taheri24/helitask/pkg/domain"
+)
+
+func TestBroker(t *testing.T) {
+	cfg := config.Default()
+	cfg.Stream.ReplaySize, cfg.Stream.ClientBuffer = 3, 2
+	bus := NewBus()
+	broker := NewBroker(bus, cfg)
+	publish := func(sequence uint64, workspace string) {
+		assert.NoError(t, bus.Publish(t.Context(), &domain.Event{Sequence: sequence, WorkspaceID: workspace, Type: domain.TodoUpdated}))
+	}
+	inWorkspace := func(workspace string) Filter {
+		return func(event *domain.Event) bool { return event.WorkspaceID == workspace }
+	}
+	sequences := func(events []*domain.Event) []uint64 {
+		var seqs []uint64
+		for _, event := range events {
+			seqs = append(seqs, event.Sequence)
+		}
+		return seqs
+	}
+
+	live, _, _ := broker.Subscribe("acme", 0, inWorkspace("acme"))
+	other, _, _ := broker.Subscribe("beta", 0, inWorkspace("beta"))
+	publish(10, "acme")
+	publish(3, "beta") // sequences are numbered per workspace
+	publish(3, "beta") // delivered again by the relay
+	publish(12, "acme")
+	assert.Equal(t, uint64(10), (<-live.Events()).Sequence)
+	assert.Equal(t, uint64(12), (<-live.Events()).Sequence, "other workspaces and repeated events are filtered out")
+	assert.Equal(t, uint64(3), (<-other.Events()).Sequence)
+	select {
+	case event := <-other.Events():
+		t.Fatalf("event %d of beta was delivered again", event.Sequence)
+	default:
+	}
+	other.Close()
+
+	sub, replay, complete := broker.Subscribe("acme", 10, inWorkspace("acme"))
+	assert.True(t, complete)
+	assert.Equal(t, []uint64{12}, sequences(replay))
+	sub.Close()
+	_, ok := <-sub.Events()
+	assert.False(t, ok, "a closed subscription has a closed channel")
+
+	_, _, complete = broker.Subscribe("acme", 5, inWorkspace("acme"))
+	assert.False(t, complete, "events before the first one seen are unknown")
+
+	publish(13, "acme")
+	_, replay, complete = broker.Subscribe("acme", 9, inWorkspace("acme"))
+	assert.False(t, complete, "event 10 was evicted from the replay buffer")
+	assert.Equal(t, []uint64{12, 13}, sequences(replay))
+
+	// a subscription that does not keep up is dropped
+	publish(14, "acme")
+	publish(15, "acme")
+	var got []uint64
+	for event := range live.Events() {
+		got = append(got, event.Sequence)
+	}
+	assert.Equal(t, []uint64{13, 14}, got)
+}
@@ -0,0 +1,33 @@
+package events
+
+import (
+	"github.com/taheri24/helitask/pkg/config"
+	"github.com/taheri24/helitask/pkg/domain"
+	"github.com/taheri24/helitask/pkg/logger"
+	"go.uber.org/fx"
+)
+
+// PublisherGroup is the fx value group other modules add their domain.EventPublisher to
+const PublisherGroup = `group:"event_publishers"`
+
+// PublisherParams are the publishers the relay delivers events to
+type PublisherParams struct {
+	fx.In
+	Bus    *Bus
+	Extra  []domain.EventPublisher `group:"event_publishers"`
+	Config *config.Config
+	Logger logger.Logger
+}
+
+// NewPublisher combines the in-process bus, the log publisher when enabled and the publishers of PublisherGroup
+func NewPublisher(p PublisherParams) domain.EventPublisher {
+	publishers := Publishers{p.Bus}
+	if p.Config.Events.Log {
+		publishers = append(publishers, NewLogPublisher(p.Logger))
+	}
+	return append(publishers, p.Extra...)
+}
+
+// Module provides the in-process Bus, the Broker of client streams, the combined
+// domain.EventPublisher and the Relay. The relay runs once StartRelay is invoked.
+var Module = fx.Module("events", fx.Provide(NewBus, NewBroker, NewPublisher, NewRelay))
@@ -0,0 +1,84 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/taheri24/helitask/pkg/domain"
+	"github.com/taheri24/helitask/pkg/logger"
+)
+
+// Handler consumes an event; an error makes the relay deliver the event again
+type Handler func(ctx context.Context, event *domain.Event) error
+
+// Bus is the in-process publisher, handing every event to the handlers subscribed to it
+type Bus struct {
+	mu       sync.RWMutex
+	next     int
+	handlers map[int]Handler
+}
+
+// NewBus creates a Bus without subscribers
+func NewBus() *Bus {
+	return &Bus{handlers: map[int]Handler{}}
+}
+
+// Subscribe adds a handler until the returned function is called
+func (b *Bus) Subscribe(handler Handler) (unsubscribe func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	id := b.next
+	b.next++
+	b.handlers[id] = handler
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.handlers, id)
+	}
+}
+
+// Publish implements domain.EventPublisher, calling every handler even when one fails
+func (b *Bus) Publish(ctx context.Context, event *domain.Event) error {
+	b.mu.RLock()
+	handlers := make([]Handler, 0, len(b.handlers))
+	for _, handler := range b.handlers {
+		handlers = append(handlers, handler)
+	}
+	b.mu.RUnlock()
+	var errs []error
+	for _, handler := range handlers {
+		errs = append(errs, handler(ctx, event))
+	}
+	return errors.Join(errs...)
+}
+
+// LogPublisher writes every event to the application log
+type LogPublisher struct {
+	logger logger.Logger
+}
+
+// NewLogPublisher creates a LogPublisher writing to logger
+func NewLogPublisher(logger logger.Logger) *LogPublisher {
+	return &LogPublisher{logger: logger}
+}
+
+// Publish implements domain.EventPublisher
+func (p *LogPublisher) Publish(_ context.Context, event *domain.Event) error {
+	p.logger.Info("Domain event", "type", event.Type, "id", event.ID.String(), "sequence", event.Sequence,
+		"workspace", event.WorkspaceID, "todo", event.Todo.ID.String(), "version", event.Todo.Version)
+	return nil
+}
+
+// Publishers fans every event out to several publishers. When one of them fails the event is
+// published again to all of them, which at-least-once delivery allows.
+type Publishers []domain.EventPublisher
+
+// Publish implements domain.EventPublisher
+func (ps Publishers) Publish(ctx context.Context, event *domain.Event) error {
+	var errs []error
+	for _, p := range ps {
+		errs = append(errs, p.Publish(ctx, event))
+	}
+	return errors.Join(errs...)
+}
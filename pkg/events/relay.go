@@ -0,0 +1,129 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/taheri24/helitask/pkg/config"
+	"github.com/taheri24/helitask/pkg/domain"
+	"github.com/taheri24/helitask/pkg/logger"
+	"go.uber.org/fx"
+)
+
+// purgeInterval is how often published events past their retention are removed
+const purgeInterval = time.Hour
+
+// Relay publishes the events of the outbox in the order they were stored. An event is only
+// marked published once the publisher accepted it, so a crash in between publishes it again.
+// A batch is claimed in a transaction that locks its messages until they are marked, so the
+// relays of several replicas take turns instead of publishing every event each.
+type Relay struct {
+	outbox      domain.OutboxRepository
+	publisher   domain.EventPublisher
+	tx          domain.TxManager
+	batchSize   int
+	maxAttempts int
+}
+
+// NewRelay creates a Relay from the outbox to publisher
+func NewRelay(outbox domain.OutboxRepository, publisher domain.EventPublisher, tx domain.TxManager, cfg *config.Config) *Relay {
+	return &Relay{outbox: outbox, publisher: publisher, tx: tx, batchSize: cfg.Events.BatchSize, maxAttempts: cfg.Events.MaxAttempts}
+}
+
+// RelayOnce publishes a batch of pending events and returns how many were published.
+// It stops at the first event the publisher fails on, so that later events are not published
+// before it; that event is tried again by the next call. Once an event has failed its last
+// attempt it is dead, and the events after it go on.
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	published := 0
+	var failed error
+	err := r.tx.WithinTx(ctx, func(ctx context.Context) error {
+		messages, err := r.outbox.Pending(ctx, r.batchSize)
+		if err != nil {
+			return err
+		}
+		var dead []error
+		for _, msg := range messages {
+			// a savepoint undoes what a failing publisher wrote, keeping the claim usable
+			err := r.tx.WithinTx(ctx, func(ctx context.Context) error {
+				return r.publisher.Publish(ctx, msg.Event())
+			})
+			if err != nil {
+				if r.maxAttempts > 0 && msg.Attempts+1 >= r.maxAttempts {
+					if markErr := r.outbox.MarkDead(ctx, msg.ID, err, time.Now()); markErr != nil {
+						return fmt.Errorf("failed to record dead event %d: %w", msg.ID, markErr)
+					}
+					dead = append(dead, fmt.Errorf("gave up publishing event %d after %d attempts: %w", msg.ID, msg.Attempts+1, err))
+					continue
+				}
+				if markErr := r.outbox.MarkFailed(ctx, msg.ID, err); markErr != nil {
+					return fmt.Errorf("failed to record failed event %d: %w", msg.ID, markErr)
+				}
+				failed = errors.Join(append(dead, fmt.Errorf("failed to publish event %d: %w", msg.ID, err))...)
+				return nil
+			}
+			if err := r.outbox.MarkPublished(ctx, msg.ID, time.Now()); err != nil {
+				return fmt.Errorf("failed to mark event %d published: %w", msg.ID, err)
+			}
+			published++
+		}
+		failed = errors.Join(dead...)
+		return nil
+	})
+	if err != nil {
+		return published, err
+	}
+	return published, failed
+}
+
+// StartRelay polls the outbox while the app runs, draining it before waiting for the next poll,
+// and removes the published events past their retention
+func StartRelay(lc fx.Lifecycle, relay *Relay, outbox domain.OutboxRepository, cfg *config.Config, logger logger.Logger) {
+	interval := cfg.Events.RelayInterval
+	if interval <= 0 {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go func() {
+				defer close(done)
+				ticker, purge := time.NewTicker(interval), time.NewTicker(purgeInterval)
+				defer ticker.Stop()
+				defer purge.Stop()
+				for {
+					select {
+					case <-ctx.Done():
+						return
+					case <-ticker.C:
+						for {
+							n, err := relay.RelayOnce(ctx)
+							if err != nil && ctx.Err() == nil {
+								logger.Error("Failed to relay events", err)
+							}
+							if err != nil || n < relay.batchSize {
+								break
+							}
+						}
+					case now := <-purge.C:
+						if _, err := outbox.PurgePublished(ctx, now.Add(-cfg.Events.Retention)); err != nil {
+							logger.Error("Failed to purge published events", err)
+						}
+					}
+				}
+			}()
+			return nil
+		},
+		OnStop: func(stopCtx context.Context) error {
+			cancel()
+			select {
+			case <-done:
+			case <-stopCtx.Done():
+			}
+			return nil
+		},
+	})
+}
@@ -0,0 +1,145 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taheri24/helitask/pkg/config"
+	"github.com/taheri24/helitask/pkg/domain"
+	"github.com/taheri24/helitask/pkg/logger"
+	"github.com/taheri24/helitask/pkg/ports/storage"
+	"github.com/taheri24/helitask/pkg/ports/storage/sqlite"
+)
+
+// flakyPublisher records the events it receives and fails while fail is set, and on poison
+type flakyPublisher struct {
+	fail     bool
+	poison   domain.UUID
+	received []*domain.Event
+}
+
+func (p *flakyPublisher) Publish(_ context.Context, event *domain.Event) error {
+	p.received = append(p.received, event)
+	if p.fail || event.ID == p.poison {
+		return errors.New("broker unavailable")
+	}
+	return nil
+}
+
+func TestRelay(t *testing.T) {
+	db := sqlite.NewDb(t, "")
+	cfg := config.Default()
+	repo := storage.NewTodoRepository(db, nil, logger.Nop(), cfg)
+	outbox := storage.NewOutboxRepository(db)
+	ctx := domain.WithPrincipal(t.Context(), domain.Principal{UserID: "alice"})
+
+	todo := &domain.TodoItem{ID: domain.NewUUID(), Description: "Evented", DueDate: time.Now()}
+	assert.NoError(t, repo.Create(ctx, todo))
+	clock := domain.NewHLCClock("test")
+	update := func(change func(todo *domain.TodoItem)) {
+		changed := *todo
+		change(&changed)
+		changed.Stamp(todo, clock.Now)
+		assert.NoError(t, repo.Update(ctx, &changed))
+		todo = &changed
+	}
+	update(func(todo *domain.TodoItem) { todo.Description = "Renamed" })
+	update(func(todo *domain.TodoItem) { todo.Completed = true })
+	assert.NoError(t, repo.Delete(ctx, todo.ID))
+
+	// the events of a rolled back change are rolled back with it
+	err := storage.NewTxManager(db).WithinTx(ctx, func(ctx context.Context) error {
+		if err := repo.Create(ctx, &domain.TodoItem{ID: domain.NewUUID(), Description: "Rolled back"}); err != nil {
+			return err
+		}
+		return errors.New("abort")
+	})
+	assert.Error(t, err)
+
+	publisher := &flakyPublisher{fail: true}
+	cfg.Events.BatchSize = 10
+	relay := NewRelay(outbox, publisher, storage.NewTxManager(db), cfg)
+	n, err := relay.RelayOnce(ctx)
+	assert.Error(t, err)
+	assert.Equal(t, 0, n, "the relay stops at the event it failed on")
+	pending, err := outbox.Pending(ctx, 10)
+	if assert.NoError(t, err) && assert.Len(t, pending, 4) {
+		assert.Equal(t, 1, pending[0].Attempts)
+		assert.Equal(t, "broker unavailable", pending[0].LastError)
+	}
+
+	publisher.fail = false
+	n, err = relay.RelayOnce(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, n)
+	var types []domain.EventType
+	for _, event := range publisher.received {
+		types = append(types, event.Type)
+	}
+	assert.Equal(t, []domain.EventType{domain.TodoCreated, domain.TodoCreated, domain.TodoUpdated, domain.TodoCompleted, domain.TodoDeleted}, types,
+		"the failed event is delivered again")
+	last := publisher.received[4]
+	assert.Equal(t, todo.ID, last.Todo.ID)
+	assert.Equal(t, int64(4), last.Todo.Version)
+	assert.NotNil(t, last.Todo.DeletedAt)
+	assert.Equal(t, "alice", last.ActorID)
+	assert.Greater(t, last.Sequence, publisher.received[3].Sequence)
+
+	n, err = relay.RelayOnce(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n, "published events are not published again")
+
+	purged, err := outbox.PurgePublished(ctx, time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), purged)
+
+	// an event failing every attempt is given up on, so that it does not hold back later events
+	cfg.Events.MaxAttempts = 2
+	relay = NewRelay(outbox, publisher, storage.NewTxManager(db), cfg)
+	poisoned := &domain.TodoItem{ID: domain.NewUUID(), Description: "Poisoned", DueDate: time.Now()}
+	assert.NoError(t, repo.Create(ctx, poisoned))
+	assert.NoError(t, repo.Delete(ctx, poisoned.ID))
+	pending, err = outbox.Pending(ctx, 10)
+	if assert.NoError(t, err) && assert.Len(t, pending, 2) {
+		publisher.poison = pending[0].EventID
+	}
+	n, err = relay.RelayOnce(ctx)
+	assert.Error(t, err)
+	assert.Equal(t, 0, n, "the event is retried before it is given up on")
+	n, err = relay.RelayOnce(ctx)
+	assert.Error(t, err)
+	assert.Equal(t, 1, n, "the events after a dead event are published")
+	pending, err = outbox.Pending(ctx, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, pending)
+	var dead domain.OutboxMessage
+	if assert.NoError(t, db.First(&dead, "event_id = ?", publisher.poison.String()).Error) {
+		assert.NotNil(t, dead.DeadAt)
+		assert.Nil(t, dead.PublishedAt)
+		assert.Equal(t, 2, dead.Attempts)
+	}
+	purged, err = outbox.PurgePublished(ctx, time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), purged, "dead events are purged after the retention as well")
+}
+
+func TestBus(t *testing.T) {
+	bus := NewBus()
+	var got []domain.EventType
+	unsubscribe := bus.Subscribe(func(_ context.Context, event *domain.Event) error {
+		got = append(got, event.Type)
+		return nil
+	})
+	bus.Subscribe(func(context.Context, *domain.Event) error { return errors.New("subscriber failed") })
+
+	err := Publishers{bus, NewLogPublisher(logger.Nop())}.Publish(t.Context(), &domain.Event{Type: domain.TodoCreated})
+	assert.Error(t, err, "a failing subscriber fails the publish")
+	assert.Equal(t, []domain.EventType{domain.TodoCreated}, got, "the other subscribers still get the event")
+
+	unsubscribe()
+	_ = bus.Publish(t.Context(), &domain.Event{Type: domain.TodoUpdated})
+	assert.Equal(t, []domain.EventType{domain.TodoCreated}, got)
+}
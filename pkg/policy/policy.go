@@ -0,0 +1,81 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/taheri24/helitask/pkg/domain"
+	"go.uber.org/fx"
+)
+
+// requiredRoles maps list scoped actions to the minimal role they require
+var requiredRoles = map[domain.Action]domain.Role{
+	domain.ActionReadTodo:      domain.RoleViewer,
+	domain.ActionReadList:      domain.RoleViewer,
+	domain.ActionCreateTodo:    domain.RoleEditor,
+	domain.ActionUpdateTodo:    domain.RoleEditor,
+	domain.ActionDeleteTodo:    domain.RoleEditor,
+	domain.ActionManageMembers: domain.RoleAdmin,
+}
+
+// Engine is the role based domain.Policy.
+// Items on a list are governed by the caller's membership role on that list;
+// items outside of any list may only be accessed by their owner, or by anyone when they have none.
+type Engine struct {
+	memberships domain.MembershipRepository
+}
+
+// NewEngine creates the policy engine backed by the membership repository
+func NewEngine(memberships domain.MembershipRepository) domain.Policy {
+	return &Engine{memberships: memberships}
+}
+
+// Authorize implements domain.Policy
+func (e *Engine) Authorize(ctx context.Context, action domain.Action, resource domain.Resource) error {
+	principal := domain.PrincipalFromContext(ctx)
+
+	if action == domain.ActionCreateList {
+		if principal.Anonymous() {
+			return domain.ErrUnauthenticated
+		}
+		return nil
+	}
+	// webhooks are managed by the user who created them only
+	if action == domain.ActionManageWebhook {
+		if principal.Anonymous() {
+			return domain.ErrUnauthenticated
+		}
+		if resource.OwnerID != "" && resource.OwnerID != principal.UserID {
+			return domain.ErrForbidden
+		}
+		return nil
+	}
+
+	if resource.ListID == nil {
+		if resource.OwnerID == "" || resource.OwnerID == principal.UserID {
+			return nil
+		}
+		return domain.ErrForbidden
+	}
+
+	required, ok := requiredRoles[action]
+	if !ok {
+		return fmt.Errorf("%w: unknown action %q", domain.ErrForbidden, action)
+	}
+	if principal.Anonymous() {
+		return domain.ErrUnauthenticated
+	}
+	membership, err := e.memberships.Get(ctx, *resource.ListID, principal.UserID)
+	if errors.Is(err, domain.ErrRecordNotFound) {
+		return domain.ErrForbidden
+	} else if err != nil {
+		return err
+	}
+	if !membership.Role.Includes(required) {
+		return fmt.Errorf("%w: %s requires the %s role", domain.ErrForbidden, action, required)
+	}
+	return nil
+}
+
+var Module = fx.Module("policy", fx.Provide(NewEngine))
@@ -0,0 +1,34 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/taheri24/helitask/pkg/domain"
+	"gorm.io/gorm"
+)
+
+// PostgresAuditRepository implements the AuditRepository interface.
+// Entries are appended by PostgresTodoRepository in the transactions of its writes.
+type PostgresAuditRepository struct {
+	DB    *gorm.DB
+	reads *ReadRouter
+}
+
+// NewAuditRepository creates a new instance of the PostgresAuditRepository
+func NewAuditRepository(db *gorm.DB, reads *ReadRouter) domain.AuditRepository {
+	return &PostgresAuditRepository{DB: db, reads: reads}
+}
+
+// History returns the audit entries of a todo item, most recent first
+func (r *PostgresAuditRepository) History(ctx context.Context, todoID domain.UUID, page domain.Page) ([]*domain.AuditEntry, error) {
+	var entries []*domain.AuditEntry
+	err := r.reads.read(ctx, func(db *gorm.DB) error {
+		return db.Scopes(inWorkspace(ctx)).Where("todo_id = ?", todoID.String()).
+			Order("id DESC").Limit(page.Limit).Offset(page.Offset).Find(&entries).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read todo history, %w", contextError(ctx, err))
+	}
+	return entries, nil
+}
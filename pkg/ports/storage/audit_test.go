@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taheri24/helitask/pkg/config"
+	"github.com/taheri24/helitask/pkg/domain"
+	"github.com/taheri24/helitask/pkg/logger"
+	"github.com/taheri24/helitask/pkg/ports/storage/sqlite"
+)
+
+func TestTodoAuditLog(t *testing.T) {
+	db := sqlite.NewDb(t, "")
+	reads := newReadRouter(db, nil, logger.Nop())
+	repo, audit := NewTodoRepository(db, reads, logger.Nop(), config.Default()), NewAuditRepository(db, reads)
+	ctx := domain.WithRequestID(domain.WithPrincipal(t.Context(), domain.Principal{UserID: "alice"}), "req-1")
+	dueDate := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	todo := &domain.TodoItem{ID: domain.NewUUID(), OwnerID: "alice", Description: "Audited", DueDate: dueDate}
+	history := func(page domain.Page) []*domain.AuditEntry {
+		entries, err := audit.History(ctx, todo.ID, page)
+		assert.NoError(t, err)
+		return entries
+	}
+	actions := func() []domain.AuditAction {
+		var actions []domain.AuditAction
+		for _, entry := range history(domain.Page{Limit: 10}) {
+			actions = append(actions, entry.Action)
+		}
+		return actions
+	}
+
+	assert.NoError(t, repo.Create(ctx, todo))
+	todo.DueDate = dueDate.Add(24 * time.Hour)
+	assert.NoError(t, update(ctx, repo, todo))
+	assert.NoError(t, update(ctx, repo, todo), "an update without changes")
+	todo.Completed = true
+	assert.NoError(t, update(ctx, repo, todo))
+	assert.NoError(t, repo.Delete(ctx, todo.ID))
+	assert.NoError(t, repo.Restore(ctx, todo.ID))
+	assert.Equal(t, []domain.AuditAction{domain.AuditRestored, domain.AuditDeleted, domain.AuditCompleted, domain.AuditUpdated, domain.AuditCreated}, actions())
+
+	entries := history(domain.Page{Limit: 1, Offset: 3})
+	if assert.Len(t, entries, 1) {
+		update := entries[0]
+		assert.Equal(t, "alice", update.ActorID)
+		assert.Equal(t, "req-1", update.RequestID)
+		assert.Equal(t, map[string]domain.FieldChange{"due_date": {
+			Before: dueDate.Format(time.RFC3339),
+			After:  todo.DueDate.Format(time.RFC3339),
+		}}, update.Changes)
+	}
+
+	// the entries of a rolled back change are rolled back with it
+	err := NewTxManager(db).WithinTx(ctx, func(ctx context.Context) error {
+		todo.Description = "Rolled back"
+		if err := update(ctx, repo, todo); err != nil {
+			return err
+		}
+		return errors.New("abort")
+	})
+	assert.Error(t, err)
+	assert.Len(t, actions(), 5)
+
+	other := domain.WithWorkspace(ctx, domain.Workspace{ID: "other"})
+	entries, err = audit.History(other, todo.ID, domain.Page{Limit: 10})
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+}
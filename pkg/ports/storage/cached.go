@@ -0,0 +1,231 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"sync/atomic"
+	"time"
+
+	"github.com/taheri24/helitask/pkg/cache"
+	"github.com/taheri24/helitask/pkg/config"
+	"github.com/taheri24/helitask/pkg/domain"
+	"github.com/taheri24/helitask/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// todoCacheStats publishes the hit and miss counters of every todo cache at the /debug/vars
+// endpoint of the admin server
+var todoCacheStats = expvar.NewMap("todo_cache")
+
+// CachedTodoRepository is a read-through cache in front of a TodoRepository.
+// Writes drop the entries of the items they touch; entries written by other
+// processes sharing the database only expire, unless the Store is shared as well.
+// Every method is spelled out rather than embedded, so that new write methods
+// of the interface do not bypass invalidation unnoticed.
+type CachedTodoRepository struct {
+	next   domain.TodoRepository
+	store  cache.Store
+	cfg    config.CacheConfig
+	logger logger.Logger
+
+	hits, misses atomic.Uint64
+}
+
+// NewCachedTodoRepository decorates repo with the cache configured in cfg.
+// It returns repo unchanged when caching is disabled.
+func NewCachedTodoRepository(repo domain.TodoRepository, store cache.Store, cfg *config.Config, logger logger.Logger) domain.TodoRepository {
+	if !cfg.Cache.Enabled || cfg.Cache.TTL <= 0 {
+		return repo
+	}
+	return &CachedTodoRepository{next: repo, store: store, cfg: cfg.Cache, logger: logger}
+}
+
+// todoCacheKey keys an item by workspace as well, as the same id may exist in several of them
+func todoCacheKey(ctx context.Context, id domain.UUID) string {
+	return "todo:" + domain.WorkspaceFromContext(ctx).ID + ":" + id.String()
+}
+
+// cacheBypassed reports whether the reads of ctx must skip the cache: reads in a transaction
+// may see uncommitted writes, reads pinned to the primary must see every committed one,
+// and the cache only holds items outside the trash
+func cacheBypassed(ctx context.Context) bool {
+	_, inTx := ctx.Value(txKey{}).(*gorm.DB)
+	return inTx || domain.UsesPrimary(ctx) || domain.IncludesDeleted(ctx)
+}
+
+// GetByID serves the item from the cache, fetching and caching it on a miss.
+// Missing items are remembered for the shorter NegativeTTL.
+func (r *CachedTodoRepository) GetByID(ctx context.Context, id domain.UUID) (*domain.TodoItem, error) {
+	if cacheBypassed(ctx) {
+		return r.next.GetByID(ctx, id)
+	}
+	key := todoCacheKey(ctx, id)
+	value, ok, err := r.store.Get(ctx, key)
+	if err != nil {
+		r.logger.Error("Failed to read todo cache", err)
+	}
+	if ok {
+		r.count(&r.hits, "hits")
+		if len(value) == 0 {
+			return nil, domain.ErrRecordNotFound
+		}
+		var todo domain.TodoItem
+		if err := json.Unmarshal(value, &todo); err == nil {
+			return &todo, nil
+		}
+		r.logger.Error("Failed to decode cached todo item", err)
+	} else {
+		r.count(&r.misses, "misses")
+	}
+
+	generation := r.generation(ctx, key)
+	todo, err := r.next.GetByID(ctx, id)
+	switch {
+	case errors.Is(err, domain.ErrRecordNotFound):
+		if r.cfg.NegativeTTL > 0 {
+			r.fill(ctx, key, []byte{}, generation)
+		}
+	case err == nil:
+		if value, err := json.Marshal(todo); err == nil {
+			r.fill(ctx, key, value, generation)
+		}
+	}
+	return todo, err
+}
+
+// generationKey holds a token replaced by every invalidation of the entry of key
+func generationKey(key string) string {
+	return key + ":generation"
+}
+
+// generation returns the token of the last invalidation of the entry of key
+func (r *CachedTodoRepository) generation(ctx context.Context, key string) string {
+	value, _, err := r.store.Get(ctx, generationKey(key))
+	if err != nil {
+		r.logger.Error("Failed to read todo cache", err)
+	}
+	return string(value)
+}
+
+// fill caches value read while the entry of key was at generation. When an invalidation
+// got in between, the value may predate the write behind it, so it is dropped again.
+func (r *CachedTodoRepository) fill(ctx context.Context, key string, value []byte, generation string) {
+	r.set(ctx, key, value)
+	if r.generation(ctx, key) != generation {
+		if err := r.store.Delete(ctx, key); err != nil {
+			r.logger.Error("Failed to invalidate todo cache", err)
+		}
+	}
+}
+
+// Create implements the TodoRepository interface, dropping a cached absence of the item
+func (r *CachedTodoRepository) Create(ctx context.Context, todo *domain.TodoItem) error {
+	defer r.invalidate(ctx, todo.ID)
+	return r.next.Create(ctx, todo)
+}
+
+// CreateMany implements the TodoRepository interface, dropping cached absences of the items
+func (r *CachedTodoRepository) CreateMany(ctx context.Context, todos []*domain.TodoItem) error {
+	ids := make([]domain.UUID, len(todos))
+	for i, todo := range todos {
+		ids[i] = todo.ID
+	}
+	defer r.invalidate(ctx, ids...)
+	return r.next.CreateMany(ctx, todos)
+}
+
+// Update implements the TodoRepository interface, dropping the cached item
+func (r *CachedTodoRepository) Update(ctx context.Context, todo *domain.TodoItem) error {
+	defer r.invalidate(ctx, todo.ID)
+	return r.next.Update(ctx, todo)
+}
+
+// Delete implements the TodoRepository interface, dropping the cached item
+func (r *CachedTodoRepository) Delete(ctx context.Context, id domain.UUID) error {
+	defer r.invalidate(ctx, id)
+	return r.next.Delete(ctx, id)
+}
+
+// GetByIDs implements the TodoRepository interface without caching, as its callers batch
+// their reads to make a single round trip
+func (r *CachedTodoRepository) GetByIDs(ctx context.Context, ids []domain.UUID) ([]*domain.TodoItem, error) {
+	return r.next.GetByIDs(ctx, ids)
+}
+
+// List implements the TodoRepository interface without caching
+func (r *CachedTodoRepository) List(ctx context.Context, filter domain.TodoFilter, page domain.Page) ([]*domain.TodoItem, error) {
+	return r.next.List(ctx, filter, page)
+}
+
+// ListDeleted implements the TodoRepository interface without caching
+func (r *CachedTodoRepository) ListDeleted(ctx context.Context, page domain.Page) ([]*domain.TodoItem, error) {
+	return r.next.ListDeleted(ctx, page)
+}
+
+// Restore implements the TodoRepository interface, dropping the cached absence of the item
+func (r *CachedTodoRepository) Restore(ctx context.Context, id domain.UUID) error {
+	defer r.invalidate(ctx, id)
+	return r.next.Restore(ctx, id)
+}
+
+// PurgeDeleted implements the TodoRepository interface; purged items were already absent from the cache
+func (r *CachedTodoRepository) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	return r.next.PurgeDeleted(ctx, before)
+}
+
+// GetAsOf implements the TodoRepository interface; earlier states are not cached
+func (r *CachedTodoRepository) GetAsOf(ctx context.Context, id domain.UUID, at time.Time) (*domain.TodoItem, error) {
+	return r.next.GetAsOf(ctx, id, at)
+}
+
+// Revert implements the TodoRepository interface, invalidating the cached item
+func (r *CachedTodoRepository) Revert(ctx context.Context, id domain.UUID, version int64, at domain.HLC) (*domain.TodoItem, error) {
+	defer r.invalidate(ctx, id)
+	return r.next.Revert(ctx, id, version, at)
+}
+
+// Stats returns the number of reads served from the cache and the number that missed it
+func (r *CachedTodoRepository) Stats() (hits, misses uint64) {
+	return r.hits.Load(), r.misses.Load()
+}
+
+func (r *CachedTodoRepository) count(counter *atomic.Uint64, name string) {
+	counter.Add(1)
+	todoCacheStats.Add(name, 1)
+}
+
+func (r *CachedTodoRepository) set(ctx context.Context, key string, value []byte) {
+	ttl := r.cfg.TTL
+	if len(value) == 0 {
+		ttl = r.cfg.NegativeTTL
+	}
+	if err := r.store.Set(ctx, key, value, ttl); err != nil {
+		r.logger.Error("Failed to write todo cache", err)
+	}
+}
+
+// invalidate drops the entries of ids and starts a new generation of them, whether the write
+// succeeded or not; a failed write may still have changed the item, e.g. when its commit timed out.
+// Inside a transaction the entries are dropped once it has ended, as a read outside of it
+// would otherwise cache the item as it was before the commit.
+func (r *CachedTodoRepository) invalidate(ctx context.Context, ids ...domain.UUID) {
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = todoCacheKey(ctx, id)
+	}
+	afterTx(ctx, func() {
+		ctx := context.WithoutCancel(ctx)
+		// a new generation makes reads that started before the write drop what they cache
+		generation := []byte(domain.NewUUID().String())
+		for _, key := range keys {
+			if err := r.store.Set(ctx, generationKey(key), generation, r.cfg.TTL); err != nil {
+				r.logger.Error("Failed to invalidate todo cache", err)
+			}
+		}
+		if err := r.store.Delete(ctx, keys...); err != nil {
+			r.logger.Error("Failed to invalidate todo cache", err)
+		}
+	})
+}
@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taheri24/helitask/pkg/cache"
+	"github.com/taheri24/helitask/pkg/config"
+	"github.com/taheri24/helitask/pkg/domain"
+	"github.com/taheri24/helitask/pkg/logger"
+	"github.com/taheri24/helitask/pkg/ports/storage/sqlite"
+)
+
+func TestCachedTodoRepository(t *testing.T) {
+	db, cfg := sqlite.NewDb(t, ""), config.Default()
+	cfg.Cache = config.CacheConfig{Enabled: true, Size: 10, TTL: time.Minute, NegativeTTL: time.Minute}
+	uncached := NewTodoRepository(db, newReadRouter(db, nil, logger.Nop()), logger.Nop(), cfg)
+	repo := NewCachedTodoRepository(uncached, cache.NewLRU(cfg.Cache.Size), cfg, logger.Nop()).(*CachedTodoRepository)
+	ctx := t.Context()
+	todo := &domain.TodoItem{ID: domain.NewUUID(), Description: "Cached", DueDate: time.Now().UTC()}
+	description := func(ctx context.Context) string {
+		stored, err := repo.GetByID(ctx, todo.ID)
+		if err != nil {
+			return err.Error()
+		}
+		return stored.Description
+	}
+	// changeBehindCache edits the row without going through the cache
+	changeBehindCache := func(description string) {
+		assert.NoError(t, db.Model(&domain.TodoItem{}).Where("id = ?", todo.ID.String()).Update("description", description).Error)
+	}
+
+	t.Run("Missing items are cached", func(t *testing.T) {
+		assert.ErrorIs(t, func() error { _, err := repo.GetByID(ctx, todo.ID); return err }(), domain.ErrRecordNotFound)
+		assert.NoError(t, db.Create(&domain.TodoItem{ID: todo.ID, WorkspaceID: domain.DefaultWorkspaceID, Description: "Behind"}).Error)
+		_, err := repo.GetByID(ctx, todo.ID)
+		assert.ErrorIs(t, err, domain.ErrRecordNotFound)
+		assert.NoError(t, db.Unscoped().Delete(&domain.TodoItem{}, "id = ?", todo.ID.String()).Error)
+	})
+
+	t.Run("Create drops the cached absence", func(t *testing.T) {
+		assert.NoError(t, repo.Create(ctx, todo))
+		assert.Equal(t, "Cached", description(ctx))
+	})
+
+	t.Run("Reads are served from the cache", func(t *testing.T) {
+		changeBehindCache("Changed behind the cache")
+		assert.Equal(t, "Cached", description(ctx))
+	})
+
+	t.Run("Reads pinned to the primary skip the cache", func(t *testing.T) {
+		assert.Equal(t, "Changed behind the cache", description(domain.WithPrimary(ctx)))
+	})
+
+	t.Run("Entries are kept per workspace", func(t *testing.T) {
+		other := domain.WithWorkspace(ctx, domain.Workspace{ID: "other"})
+		assert.Equal(t, domain.ErrRecordNotFound.Error(), description(other))
+	})
+
+	t.Run("Update drops the entry", func(t *testing.T) {
+		todo.Description = "Updated"
+		assert.NoError(t, update(ctx, repo, todo))
+		assert.Equal(t, "Updated", description(ctx))
+	})
+
+	t.Run("Writes in a transaction drop the entry once it has ended", func(t *testing.T) {
+		err := NewTxManager(db).WithinTx(ctx, func(txCtx context.Context) error {
+			stale := *todo
+			todo.Description = "Committed"
+			if err := update(txCtx, repo, todo); err != nil {
+				return err
+			}
+			// a concurrent read outside the transaction still finds the item as it was
+			value, _ := json.Marshal(&stale)
+			repo.set(ctx, todoCacheKey(ctx, todo.ID), value)
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "Committed", description(ctx))
+	})
+
+	t.Run("Writes during a miss keep the item read before them out of the cache", func(t *testing.T) {
+		var racing *CachedTodoRepository
+		racing = NewCachedTodoRepository(duringRead{uncached, func() {
+			changeBehindCache("Written during the read")
+			racing.invalidate(ctx, todo.ID)
+		}}, cache.NewLRU(cfg.Cache.Size), cfg, logger.Nop()).(*CachedTodoRepository)
+		stored, err := racing.GetByID(ctx, todo.ID)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "Committed", stored.Description)
+		}
+		stored, err = racing.GetByID(ctx, todo.ID)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "Written during the read", stored.Description)
+		}
+	})
+
+	t.Run("Delete drops the entry", func(t *testing.T) {
+		assert.NoError(t, repo.Delete(ctx, todo.ID))
+		assert.Equal(t, domain.ErrRecordNotFound.Error(), description(ctx))
+	})
+
+	hits, misses := repo.Stats()
+	assert.Equal(t, uint64(2), hits)
+	assert.Equal(t, uint64(6), misses)
+}
+
+// duringRead runs during after every read of an item, as a write committing while it is read
+type duringRead struct {
+	domain.TodoRepository
+	during func()
+}
+
+func (r duringRead) GetByID(ctx context.Context, id domain.UUID) (*domain.TodoItem, error) {
+	todo, err := r.TodoRepository.GetByID(ctx, id)
+	r.during()
+	return todo, err
+}
+
+func TestCachedTodoRepositoryDisabled(t *testing.T) {
+	db, cfg := sqlite.NewDb(t, ""), config.Default()
+	cfg.Cache.Enabled = false
+	repo := NewTodoRepository(db, newReadRouter(db, nil, logger.Nop()), logger.Nop(), cfg)
+	assert.Same(t, repo, NewCachedTodoRepository(repo, cache.NewLRU(1), cfg, logger.Nop()))
+}
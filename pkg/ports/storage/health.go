@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/taheri24/helitask/pkg/domain"
+	"github.com/taheri24/helitask/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// healthCheckTimeout bounds a single ping of the database
+const healthCheckTimeout = 2 * time.Second
+
+// DBHealth implements domain.HealthChecker by pinging the database.
+// It logs when the connection is lost and when it has been restored.
+type DBHealth struct {
+	DB     *gorm.DB
+	logger logger.Logger
+
+	mu      sync.Mutex
+	healthy bool
+}
+
+// NewDBHealth creates a new instance of the DBHealth
+func NewDBHealth(db *gorm.DB, logger logger.Logger) domain.HealthChecker {
+	return &DBHealth{DB: db, logger: logger, healthy: true}
+}
+
+// Check pings the database; the pool opens a new connection when the old ones broke
+func (h *DBHealth) Check(ctx context.Context) error {
+	err := ping(ctx, h.DB)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if healthy := err == nil; healthy != h.healthy {
+		h.healthy = healthy
+		if healthy {
+			h.logger.Info("Database connection restored")
+		} else {
+			h.logger.Error("Database connection lost", err)
+		}
+	}
+	return err
+}
@@ -0,0 +1,22 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taheri24/helitask/pkg/logger"
+	"github.com/taheri24/helitask/pkg/ports/storage/sqlite"
+)
+
+func TestDBHealth(t *testing.T) {
+	db := sqlite.NewDb(t, "")
+	health := NewDBHealth(db, logger.Nop())
+	assert.NoError(t, health.Check(t.Context()))
+
+	sqlDb, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDb.Close()
+	assert.Error(t, health.Check(t.Context()))
+}
@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/taheri24/helitask/pkg/config"
+	"github.com/taheri24/helitask/pkg/domain"
+	"github.com/taheri24/helitask/pkg/logger"
+	"go.uber.org/fx"
+	"gorm.io/gorm"
+)
+
+// PostgresIdempotencyRepository implements the IdempotencyRepository interface
+type PostgresIdempotencyRepository struct {
+	DB *gorm.DB
+}
+
+// NewIdempotencyRepository creates a new instance of the PostgresIdempotencyRepository
+func NewIdempotencyRepository(db *gorm.DB) domain.IdempotencyRepository {
+	return &PostgresIdempotencyRepository{DB: db}
+}
+
+func (r *PostgresIdempotencyRepository) byKey(ctx context.Context, clientID, key string) *gorm.DB {
+	return conn(ctx, r.DB).Model(&domain.IdempotencyRecord{}).Scopes(inWorkspace(ctx)).
+		Where("client_id = ? AND key = ?", clientID, key)
+}
+
+// Reserve implements domain.IdempotencyRepository
+func (r *PostgresIdempotencyRepository) Reserve(ctx context.Context, rec *domain.IdempotencyRecord) error {
+	rec.WorkspaceID = domain.WorkspaceFromContext(ctx).ID
+	// an expired record no longer holds its key
+	err := r.byKey(ctx, rec.ClientID, rec.Key).Where("expires_at < ?", time.Now()).
+		Delete(&domain.IdempotencyRecord{}).Error
+	if err != nil {
+		return fmt.Errorf("failed to release expired idempotency key, %w", err)
+	}
+	if err := conn(ctx, r.DB).Create(rec).Error; err != nil {
+		return fmt.Errorf("failed to reserve idempotency key, %w", translateError(err))
+	}
+	return nil
+}
+
+// Get implements domain.IdempotencyRepository
+func (r *PostgresIdempotencyRepository) Get(ctx context.Context, clientID, key string) (*domain.IdempotencyRecord, error) {
+	var rec domain.IdempotencyRecord
+	if err := r.byKey(ctx, clientID, key).First(&rec).Error; err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// Complete implements domain.IdempotencyRepository
+func (r *PostgresIdempotencyRepository) Complete(ctx context.Context, clientID, key string, statusCode int, body []byte) error {
+	err := r.byKey(ctx, clientID, key).Updates(map[string]any{"status_code": statusCode, "body": body}).Error
+	if err != nil {
+		return fmt.Errorf("failed to store idempotent response, %w", err)
+	}
+	return nil
+}
+
+// Release implements domain.IdempotencyRepository
+func (r *PostgresIdempotencyRepository) Release(ctx context.Context, clientID, key string) error {
+	return r.byKey(ctx, clientID, key).Delete(&domain.IdempotencyRecord{}).Error
+}
+
+// PurgeExpired removes the records of every workspace that expired before now
+func (r *PostgresIdempotencyRepository) PurgeExpired(ctx context.Context, now time.Time) (int64, error) {
+	res := conn(ctx, r.DB).Where("expires_at < ?", now).Delete(&domain.IdempotencyRecord{})
+	return res.RowsAffected, res.Error
+}
+
+// StartIdempotencyPurge periodically removes expired idempotency records while the app runs
+func StartIdempotencyPurge(lc fx.Lifecycle, repo domain.IdempotencyRepository, cfg *config.Config, logger logger.Logger) {
+	interval := cfg.Idempotency.TTL / 4
+	if interval <= 0 {
+		return
+	}
+	done := make(chan struct{})
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go func() {
+				ticker := time.NewTicker(interval)
+				defer ticker.Stop()
+				for {
+					select {
+					case <-done:
+						return
+					case now := <-ticker.C:
+						if _, err := repo.PurgeExpired(context.Background(), now); err != nil {
+							logger.Error("Failed to purge idempotency keys", err)
+						}
+					}
+				}
+			}()
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			close(done)
+			return nil
+		},
+	})
+}
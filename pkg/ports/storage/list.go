@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/taheri24/helitask/pkg/domain"
+	"gorm.io/gorm"
+)
+
+// PostgresListRepository implements the ListRepository interface
+type PostgresListRepository struct {
+	DB    *gorm.DB
+	reads *ReadRouter
+}
+
+// NewListRepository creates a new instance of the PostgresListRepository
+func NewListRepository(db *gorm.DB, reads *ReadRouter) domain.ListRepository {
+	return &PostgresListRepository{DB: db, reads: reads}
+}
+
+// Create stores the list together with the admin membership of its creator
+func (r *PostgresListRepository) Create(ctx context.Context, list *domain.List) error {
+	list.WorkspaceID = domain.WorkspaceFromContext(ctx).ID
+	err := conn(ctx, r.DB).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(list).Error; err != nil {
+			return err
+		}
+		return tx.Create(&domain.Membership{
+			ListID:      list.ID,
+			UserID:      list.CreatedBy,
+			WorkspaceID: list.WorkspaceID,
+			Role:        domain.RoleAdmin,
+		}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save list, %w", translateError(err))
+	}
+	return nil
+}
+
+// GetByID retrieves a List by ID, from a read replica unless ctx asks for the primary
+func (r *PostgresListRepository) GetByID(ctx context.Context, id domain.UUID) (*domain.List, error) {
+	var list domain.List
+	err := r.reads.read(ctx, func(db *gorm.DB) error {
+		return db.Scopes(inWorkspace(ctx)).First(&list, "id=?", id.String()).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// GetByIDs retrieves the Lists with the given ids in one query
+func (r *PostgresListRepository) GetByIDs(ctx context.Context, ids []domain.UUID) ([]*domain.List, error) {
+	var lists []*domain.List
+	err := r.reads.read(ctx, func(db *gorm.DB) error {
+		return db.Scopes(inWorkspace(ctx)).Where("id IN ?", uuidStrings(ids)).Find(&lists).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return lists, nil
+}
+
+// PostgresMembershipRepository implements the MembershipRepository interface.
+// Memberships decide authorization, so they are always read from the primary.
+type PostgresMembershipRepository struct {
+	DB *gorm.DB
+}
+
+// NewMembershipRepository creates a new instance of the PostgresMembershipRepository
+func NewMembershipRepository(db *gorm.DB) domain.MembershipRepository {
+	return &PostgresMembershipRepository{DB: db}
+}
+
+// Get retrieves the membership of a user on a list
+func (r *PostgresMembershipRepository) Get(ctx context.Context, listID domain.UUID, userID string) (*domain.Membership, error) {
+	var m domain.Membership
+	err := conn(ctx, r.DB).Scopes(inWorkspace(ctx)).
+		First(&m, "list_id=? AND user_id=?", listID.String(), userID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// List retrieves all memberships of a list
+func (r *PostgresMembershipRepository) List(ctx context.Context, listID domain.UUID) ([]domain.Membership, error) {
+	var members []domain.Membership
+	err := conn(ctx, r.DB).Scopes(inWorkspace(ctx)).
+		Where("list_id=?", listID.String()).Order("created_at, user_id").Find(&members).Error
+	return members, err
+}
+
+// Add stores a new membership
+func (r *PostgresMembershipRepository) Add(ctx context.Context, m *domain.Membership) error {
+	m.WorkspaceID = domain.WorkspaceFromContext(ctx).ID
+	if err := conn(ctx, r.DB).Create(m).Error; err != nil {
+		return fmt.Errorf("failed to save membership, %w", translateError(err))
+	}
+	return nil
+}
+
+// UpdateRole changes the role of an existing membership
+func (r *PostgresMembershipRepository) UpdateRole(ctx context.Context, listID domain.UUID, userID string, role domain.Role) error {
+	res := conn(ctx, r.DB).Model(&domain.Membership{}).Scopes(inWorkspace(ctx)).
+		Where("list_id=? AND user_id=?", listID.String(), userID).Update("role", role)
+	if res.Error != nil {
+		return fmt.Errorf("failed to update membership, %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return domain.ErrRecordNotFound
+	}
+	return nil
+}
+
+// translateError maps driver level errors onto domain errors
+func translateError(err error) error {
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return fmt.Errorf("%w: %w", domain.ErrConflict, err)
+	}
+	return err
+}
@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taheri24/helitask/pkg/config"
+	"github.com/taheri24/helitask/pkg/domain"
+	"github.com/taheri24/helitask/pkg/logger"
+	"github.com/taheri24/helitask/pkg/ports/storage/sqlite"
+)
+
+func TestTodoList(t *testing.T) {
+	db := sqlite.NewDb(t, "")
+	repo := NewTodoRepository(db, newReadRouter(db, nil, logger.Nop()), logger.Nop(), config.Default())
+	ctx := t.Context()
+	listID, due := domain.NewUUID(), time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	todos := []*domain.TodoItem{
+		{ID: domain.NewUUID(), OwnerID: "alice", Description: "Third", DueDate: due.Add(2 * time.Hour)},
+		{ID: domain.NewUUID(), OwnerID: "alice", Description: "First", DueDate: due, Completed: true},
+		{ID: domain.NewUUID(), OwnerID: "bob", Description: "Second", DueDate: due.Add(time.Hour), ListID: &listID},
+		{ID: domain.NewUUID(), OwnerID: "alice", Description: "Deleted", DueDate: due},
+	}
+	for _, todo := range todos {
+		assert.NoError(t, repo.Create(ctx, todo))
+	}
+	assert.NoError(t, repo.Delete(ctx, todos[3].ID))
+	descriptions := func(filter domain.TodoFilter, page domain.Page) []string {
+		listed, err := repo.List(ctx, filter, page)
+		assert.NoError(t, err)
+		var out []string
+		for _, todo := range listed {
+			out = append(out, todo.Description)
+		}
+		return out
+	}
+
+	completed, open := true, false
+	assert.Equal(t, []string{"First", "Second", "Third"}, descriptions(domain.TodoFilter{}, domain.Page{Limit: 10}), "items are listed by due date, without the trash")
+	assert.Equal(t, []string{"Second"}, descriptions(domain.TodoFilter{}, domain.Page{Limit: 1, Offset: 1}))
+	assert.Equal(t, []string{"Second"}, descriptions(domain.TodoFilter{ListID: &listID}, domain.Page{Limit: 10}))
+	assert.Equal(t, []string{"First", "Third"}, descriptions(domain.TodoFilter{OwnerID: "alice"}, domain.Page{Limit: 10}))
+	assert.Equal(t, []string{"First"}, descriptions(domain.TodoFilter{Completed: &completed}, domain.Page{Limit: 10}))
+	assert.Equal(t, []string{"Third"}, descriptions(domain.TodoFilter{OwnerID: "alice", Completed: &open}, domain.Page{Limit: 10}))
+	assert.Empty(t, descriptions(domain.TodoFilter{}, domain.Page{Limit: 10, Offset: 3}))
+
+	found, err := repo.GetByIDs(ctx, []domain.UUID{todos[0].ID, todos[3].ID, domain.NewUUID(), todos[2].ID})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []domain.UUID{todos[0].ID, todos[2].ID}, []domain.UUID{found[0].ID, found[1].ID}, "missing and deleted items are left out")
+	found, err = repo.GetByIDs(domain.WithDeleted(ctx), []domain.UUID{todos[3].ID})
+	assert.NoError(t, err)
+	assert.Len(t, found, 1)
+
+	other := domain.WithWorkspace(ctx, domain.Workspace{ID: "other"})
+	elsewhere, err := repo.List(other, domain.TodoFilter{}, domain.Page{Limit: 10})
+	assert.NoError(t, err)
+	assert.Empty(t, elsewhere)
+}
+
+func TestListsAreScopedToWorkspaces(t *testing.T) {
+	db := sqlite.NewDb(t, "")
+	lists := NewListRepository(db, newReadRouter(db, nil, logger.Nop()))
+	memberships := NewMembershipRepository(db)
+	ctx, other := t.Context(), domain.WithWorkspace(t.Context(), domain.Workspace{ID: "other"})
+	id := domain.NewUUID()
+
+	assert.NoError(t, lists.Create(ctx, &domain.List{ID: id, Name: "Sprint", CreatedBy: "alice"}))
+	_, err := lists.GetByID(other, id)
+	assert.ErrorIs(t, err, domain.ErrRecordNotFound)
+	assert.NoError(t, lists.Create(other, &domain.List{ID: id, Name: "Backlog", CreatedBy: "bob"}), "ids are scoped to their workspace")
+	assert.ErrorIs(t, lists.Create(ctx, &domain.List{ID: id, Name: "Other", CreatedBy: "alice"}), domain.ErrConflict)
+
+	list, err := lists.GetByID(other, id)
+	assert.NoError(t, err)
+	assert.Equal(t, "Backlog", list.Name)
+	_, err = memberships.Get(other, id, "alice")
+	assert.ErrorIs(t, err, domain.ErrRecordNotFound)
+	m, err := memberships.Get(other, id, "bob")
+	assert.NoError(t, err)
+	assert.Equal(t, domain.RoleAdmin, m.Role)
+}
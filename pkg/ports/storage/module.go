@@ -1,13 +1,19 @@
 package storage
 
 import (
+	"github.com/taheri24/helitask/pkg/cache"
 	"go.uber.org/fx"
 )
 
 var Module fx.Option
 
+// CacheModule puts the read-through cache in front of the TodoRepository. It belongs at
+// the root of the app next to Module, since fx keeps a decoration inside its own module.
+var CacheModule fx.Option
+
 func init() {
 
-	Module = fx.Module("repoPostgres", fx.Provide(NewTodoRepository))
+	Module = fx.Module("repoPostgres", fx.Provide(NewReadRouter, NewTodoRepository, NewListRepository, NewMembershipRepository, NewIdempotencyRepository, NewAuditRepository, NewSyncRepository, NewOutboxRepository, NewWebhookRepository, NewWebhookDeliveryRepository, NewTxManager, NewDBHealth))
+	CacheModule = fx.Options(fx.Provide(cache.NewStore), fx.Decorate(NewCachedTodoRepository))
 
 }
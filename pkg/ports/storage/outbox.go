@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/taheri24/helitask/pkg/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PostgresOutboxRepository implements the OutboxRepository interface
+type PostgresOutboxRepository struct {
+	DB *gorm.DB
+}
+
+// NewOutboxRepository creates a new instance of the PostgresOutboxRepository
+func NewOutboxRepository(db *gorm.DB) domain.OutboxRepository {
+	return &PostgresOutboxRepository{DB: db}
+}
+
+// Pending implements domain.OutboxRepository. The messages stay locked until the transaction
+// of ctx ends, and those another relay holds are skipped. When another relay holds the oldest
+// message, none are returned, so that no event is published ahead of those stored before it.
+func (r *PostgresOutboxRepository) Pending(ctx context.Context, limit int) ([]*domain.OutboxMessage, error) {
+	var oldest []uint64
+	err := conn(ctx, r.DB).Model(&domain.OutboxMessage{}).Scopes(pending).Order("id").Limit(1).Pluck("id", &oldest).Error
+	if err != nil || len(oldest) == 0 {
+		return nil, outboxError(err)
+	}
+	var messages []*domain.OutboxMessage
+	err = conn(ctx, r.DB).Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Scopes(pending).Order("id").Limit(limit).Find(&messages).Error
+	if err != nil {
+		return nil, outboxError(err)
+	}
+	if len(messages) == 0 || messages[0].ID != oldest[0] {
+		return nil, nil
+	}
+	return messages, nil
+}
+
+// pending selects the messages that are neither published nor dead
+func pending(db *gorm.DB) *gorm.DB {
+	return db.Where("published_at IS NULL AND dead_at IS NULL")
+}
+
+func outboxError(err error) error {
+	if err != nil {
+		return fmt.Errorf("failed to read the outbox, %w", err)
+	}
+	return nil
+}
+
+// MarkPublished implements domain.OutboxRepository
+func (r *PostgresOutboxRepository) MarkPublished(ctx context.Context, id uint64, at time.Time) error {
+	return conn(ctx, r.DB).Model(&domain.OutboxMessage{}).Where("id = ?", id).
+		Updates(map[string]any{"published_at": at, "attempts": gorm.Expr("attempts + 1"), "last_error": ""}).Error
+}
+
+// MarkFailed implements domain.OutboxRepository
+func (r *PostgresOutboxRepository) MarkFailed(ctx context.Context, id uint64, cause error) error {
+	return conn(ctx, r.DB).Model(&domain.OutboxMessage{}).Where("id = ?", id).
+		Updates(map[string]any{"attempts": gorm.Expr("attempts + 1"), "last_error": cause.Error()}).Error
+}
+
+// MarkDead implements domain.OutboxRepository
+func (r *PostgresOutboxRepository) MarkDead(ctx context.Context, id uint64, cause error, at time.Time) error {
+	return conn(ctx, r.DB).Model(&domain.OutboxMessage{}).Where("id = ?", id).
+		Updates(map[string]any{"dead_at": at, "attempts": gorm.Expr("attempts + 1"), "last_error": cause.Error()}).Error
+}
+
+// PurgePublished implements domain.OutboxRepository
+func (r *PostgresOutboxRepository) PurgePublished(ctx context.Context, before time.Time) (int64, error) {
+	res := conn(ctx, r.DB).Where("published_at < ? OR dead_at < ?", before, before).Delete(&domain.OutboxMessage{})
+	return res.RowsAffected, res.Error
+}
@@ -0,0 +1,36 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/taheri24/helitask/pkg/ports/storage/postgres"
+)
+
+// TestOutboxPendingSkipsLockedMessages tests that relays claim messages with a lock and wait while
+// another relay holds the oldest message
+func TestOutboxPendingSkipsLockedMessages(t *testing.T) {
+	db, mockSql := postgres.NewMockDB()
+	outbox := NewOutboxRepository(db)
+	columns := []string{"id", "event_id", "workspace_id", "type", "attempts"}
+
+	mockSql.ExpectQuery(`^SELECT "id" FROM "outbox" WHERE published_at IS NULL AND dead_at IS NULL ORDER BY id LIMIT \$1$`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mockSql.ExpectQuery(`^SELECT \* FROM "outbox" WHERE published_at IS NULL AND dead_at IS NULL ORDER BY id LIMIT \$1 FOR UPDATE SKIP LOCKED$`).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(3, "0195b4a0-0000-7000-8000-000000000001", "default", "todo.created", 0).
+			AddRow(4, "0195b4a0-0000-7000-8000-000000000002", "default", "todo.updated", 0))
+	messages, err := outbox.Pending(t.Context(), 10)
+	assert.NoError(t, err)
+	assert.Len(t, messages, 2)
+
+	mockSql.ExpectQuery(`^SELECT "id" FROM "outbox"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mockSql.ExpectQuery(`FOR UPDATE SKIP LOCKED$`).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(5, "0195b4a0-0000-7000-8000-000000000003", "default", "todo.deleted", 0))
+	messages, err = outbox.Pending(t.Context(), 10)
+	assert.NoError(t, err)
+	assert.Empty(t, messages, "the messages after the one another relay holds wait for it")
+
+	assert.NoError(t, mockSql.ExpectationsWereMet())
+}
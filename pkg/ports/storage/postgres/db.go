@@ -1,16 +1,18 @@
 package postgres
 
 import (
+	"database/sql"
 	"fmt"
 
 	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/taheri24/helitask/pkg/config"
 	pg "gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
 // NewDB initializes the database connection
 func NewDB(dsn string) (*gorm.DB, error) {
-	db, err := gorm.Open(pg.Open(dsn))
+	db, err := gorm.Open(pg.Open(dsn), &gorm.Config{TranslateError: true})
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
@@ -18,6 +20,29 @@ func NewDB(dsn string) (*gorm.DB, error) {
 	return db, nil
 }
 
+// Open initializes the connection pool without connecting, leaving the first
+// connection to the caller, e.g. to retry it while the database is starting
+func Open(dsn string) (*gorm.DB, error) {
+	db, err := gorm.Open(pg.Open(dsn), &gorm.Config{TranslateError: true, DisableAutomaticPing: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	return db, nil
+}
+
+// ConfigurePool applies the pool settings of cfg to db
+func ConfigurePool(db *sql.DB, cfg config.DatabaseConfig) {
+	if cfg.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(cfg.MaxOpenConns)
+	}
+	if cfg.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+}
+
 // NewDB initializes the database connection
 func NewMockDB() (*gorm.DB, sqlmock.Sqlmock) {
 	sqlDB, mock, err := sqlmock.New()
@@ -26,6 +51,7 @@ func NewMockDB() (*gorm.DB, sqlmock.Sqlmock) {
 	}
 	db, err := gorm.Open(pg.New(pg.Config{Conn: sqlDB}), &gorm.Config{
 		SkipDefaultTransaction: true,
+		TranslateError:         true,
 	})
 	if err != nil {
 		panic(fmt.Errorf("failed to connect to database: %w", err))
@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/taheri24/helitask/pkg/config"
+	"github.com/taheri24/helitask/pkg/domain"
+	"github.com/taheri24/helitask/pkg/logger"
+	"github.com/taheri24/helitask/pkg/ports/storage/postgres"
+	"go.uber.org/fx"
+	"gorm.io/gorm"
+)
+
+// replica is a read-only copy of the primary database
+type replica struct {
+	db   *gorm.DB
+	down atomic.Bool
+}
+
+// ReadRouter spreads reads over the healthy read replicas and sends them to the
+// primary when there is none, or when ctx asks for the primary. Writes never use it.
+type ReadRouter struct {
+	primary  *gorm.DB
+	replicas []*replica
+	next     atomic.Uint64
+	logger   logger.Logger
+}
+
+// NewReadRouter creates a new instance of the ReadRouter for the replicas in cfg.
+// Unhealthy replicas are probed every cfg.DB.ReplicaCheckInterval while the app runs.
+func NewReadRouter(lc fx.Lifecycle, db *gorm.DB, cfg *config.Config, logger logger.Logger) (*ReadRouter, error) {
+	replicas := make([]*gorm.DB, 0, len(cfg.DB.ReplicaDSNs))
+	for _, dsn := range cfg.DB.ReplicaDSNs {
+		replicaDb, err := postgres.Open(dsn)
+		if err != nil {
+			return nil, err
+		}
+		sqlDb, err := replicaDb.DB()
+		if err != nil {
+			return nil, err
+		}
+		postgres.ConfigurePool(sqlDb, cfg.DB)
+		replicas = append(replicas, replicaDb)
+	}
+	r := newReadRouter(db, replicas, logger)
+	if len(replicas) == 0 || cfg.DB.ReplicaCheckInterval <= 0 {
+		return r, nil
+	}
+
+	done := make(chan struct{})
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go func() {
+				ticker := time.NewTicker(cfg.DB.ReplicaCheckInterval)
+				defer ticker.Stop()
+				for {
+					select {
+					case <-done:
+						return
+					case <-ticker.C:
+						r.checkReplicas(context.Background())
+					}
+				}
+			}()
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			close(done)
+			for _, rep := range r.replicas {
+				if sqlDb, err := rep.db.DB(); err == nil {
+					sqlDb.Close()
+				}
+			}
+			return nil
+		},
+	})
+	return r, nil
+}
+
+func newReadRouter(primary *gorm.DB, replicas []*gorm.DB, logger logger.Logger) *ReadRouter {
+	r := &ReadRouter{primary: primary, logger: logger}
+	for _, db := range replicas {
+		r.replicas = append(r.replicas, &replica{db: db})
+	}
+	return r
+}
+
+// read runs fn on the connection chosen for ctx. A replica failing with anything but a
+// missing record is taken out of rotation and fn runs again on the primary.
+func (r *ReadRouter) read(ctx context.Context, fn func(db *gorm.DB) error) error {
+	rep := r.pick(ctx)
+	if rep == nil {
+		return fn(conn(ctx, r.primary))
+	}
+	err := fn(rep.db.WithContext(ctx))
+	if err == nil || errors.Is(err, gorm.ErrRecordNotFound) || ctx.Err() != nil {
+		return err
+	}
+	r.markDown(rep, err)
+	return fn(r.primary.WithContext(ctx))
+}
+
+// pick returns the next healthy replica, or nil when the read must use the primary
+func (r *ReadRouter) pick(ctx context.Context) *replica {
+	if len(r.replicas) == 0 || domain.UsesPrimary(ctx) {
+		return nil
+	}
+	if _, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		// reads inside a transaction must see its own writes
+		return nil
+	}
+	start := r.next.Add(1)
+	for i := range r.replicas {
+		if rep := r.replicas[(start+uint64(i))%uint64(len(r.replicas))]; !rep.down.Load() {
+			return rep
+		}
+	}
+	return nil
+}
+
+func (r *ReadRouter) markDown(rep *replica, err error) {
+	if rep.down.CompareAndSwap(false, true) {
+		r.logger.Error("Read replica failed, reading from the primary", err)
+	}
+}
+
+// checkReplicas pings every replica, bringing back the ones that answer again
+func (r *ReadRouter) checkReplicas(ctx context.Context) {
+	for _, rep := range r.replicas {
+		err := ping(ctx, rep.db)
+		if err != nil {
+			r.markDown(rep, err)
+		} else if rep.down.CompareAndSwap(true, false) {
+			r.logger.Info("Read replica restored")
+		}
+	}
+}
+
+// ping checks that db answers within healthCheckTimeout
+func ping(ctx context.Context, db *gorm.DB) error {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+	sqlDb, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDb.PingContext(ctx)
+}
@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taheri24/helitask/pkg/config"
+	"github.com/taheri24/helitask/pkg/domain"
+	"github.com/taheri24/helitask/pkg/logger"
+	"github.com/taheri24/helitask/pkg/ports/storage/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestReadRouter(t *testing.T) {
+	primary, replicaDb := sqlite.NewDb(t, ""), sqlite.NewDb(t, "")
+	router := newReadRouter(primary, []*gorm.DB{replicaDb}, logger.Nop())
+	repo := NewTodoRepository(primary, router, logger.Nop(), config.Default())
+
+	// written to the primary only, as if the replica lagged behind
+	written := &domain.TodoItem{ID: domain.NewUUID(), WorkspaceID: domain.DefaultWorkspaceID, Description: "written", DueDate: time.Now()}
+	// found on the replica only, telling replica reads from primary reads
+	replicated := &domain.TodoItem{ID: domain.NewUUID(), WorkspaceID: domain.DefaultWorkspaceID, Description: "replicated", DueDate: time.Now()}
+	assert.NoError(t, primary.Create(written).Error)
+	assert.NoError(t, replicaDb.Create(replicated).Error)
+	found := func(ctx context.Context, todo *domain.TodoItem) bool {
+		_, err := repo.GetByID(ctx, todo.ID)
+		return err == nil
+	}
+
+	t.Run("Reads use the replica", func(t *testing.T) {
+		assert.True(t, found(t.Context(), replicated))
+		assert.False(t, found(t.Context(), written))
+	})
+
+	t.Run("Pinned reads use the primary", func(t *testing.T) {
+		assert.True(t, found(domain.WithPrimary(t.Context()), written))
+	})
+
+	t.Run("Reads in a transaction use the primary", func(t *testing.T) {
+		err := NewTxManager(primary).WithinTx(t.Context(), func(ctx context.Context) error {
+			assert.True(t, found(ctx, written))
+			return nil
+		})
+		assert.NoError(t, err)
+	})
+
+	t.Run("Recovered replica rejoins", func(t *testing.T) {
+		router.markDown(router.replicas[0], assert.AnError)
+		assert.False(t, found(t.Context(), replicated))
+		router.checkReplicas(t.Context())
+		assert.True(t, found(t.Context(), replicated))
+	})
+
+	t.Run("Failing replica fails over to the primary", func(t *testing.T) {
+		sqlDb, err := replicaDb.DB()
+		if err != nil {
+			t.Fatal(err)
+		}
+		sqlDb.Close()
+		assert.True(t, found(t.Context(), written))
+		assert.True(t, router.replicas[0].down.Load())
+		router.checkReplicas(t.Context())
+		assert.True(t, router.replicas[0].down.Load())
+	})
+}
@@ -17,15 +17,19 @@ import (
 var sqlFiles embed.FS
 
 func NewDb(t *testing.T, scriptName string) *gorm.DB {
-	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{TranslateError: true})
 	if err != nil {
 		panic(err)
 
 	}
-	db.AutoMigrate(&domain.TodoItem{})
-	if dbConn, err := db.DB(); err != nil {
+	dbConn, err := db.DB()
+	if err != nil {
 		panic(err)
-	} else if scriptName != "" {
+	}
+	// every connection to ":memory:" opens a separate database, so all queries share one
+	dbConn.SetMaxOpenConns(1)
+	db.AutoMigrate(&domain.TodoItem{}, &domain.List{}, &domain.Membership{}, &domain.IdempotencyRecord{}, &domain.AuditEntry{}, &domain.TodoVersion{}, &domain.ChangeCounter{}, &domain.OutboxMessage{}, &domain.Webhook{}, &domain.WebhookDelivery{})
+	if scriptName != "" {
 		runScript(scriptName, dbConn)
 	}
 	return db
@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/taheri24/helitask/pkg/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PostgresSyncRepository implements the SyncRepository interface over the versions of the todo items.
+// Their sequences are given out by PostgresTodoRepository in the transactions of its writes.
+type PostgresSyncRepository struct {
+	DB    *gorm.DB
+	reads *ReadRouter
+}
+
+// NewSyncRepository creates a new instance of the PostgresSyncRepository
+func NewSyncRepository(db *gorm.DB, reads *ReadRouter) domain.SyncRepository {
+	return &PostgresSyncRepository{DB: db, reads: reads}
+}
+
+// Changes returns the current versions of the items of the workspace changed after cursor,
+// in the order of their last change. A cursor before the last change removed by a purge fails
+// with ErrResyncRequired, since the deletions of the purged items are gone.
+func (r *PostgresSyncRepository) Changes(ctx context.Context, after domain.SyncCursor, limit int) ([]*domain.TodoVersion, error) {
+	var versions []*domain.TodoVersion
+	var purged []int64
+	err := r.reads.read(ctx, func(db *gorm.DB) error {
+		err := db.Scopes(inWorkspace(ctx)).
+			Where("valid_to IS NULL AND (sequence > ? OR sequence = ? AND todo_id > ?)", after.Sequence, after.Sequence, after.TodoID.String()).
+			Order("sequence").Order("todo_id").Limit(limit).Find(&versions).Error
+		if err != nil {
+			return err
+		}
+		// the watermark is read after the changes, so a purge committing in between is noticed
+		return db.Model(&domain.ChangeCounter{}).Where("workspace_id = ?", domain.WorkspaceFromContext(ctx).ID).
+			Pluck("purged_through", &purged).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read todo changes, %w", contextError(ctx, err))
+	}
+	if after != (domain.SyncCursor{}) && len(purged) > 0 && after.Sequence < purged[0] {
+		return nil, domain.ErrResyncRequired
+	}
+	return versions, nil
+}
+
+// nextSequences takes n numbers from the change counter of the workspace and returns the first
+// of them. The counter stays locked until the transaction of tx ends.
+func nextSequences(tx *gorm.DB, workspaceID string, n int) (int64, error) {
+	counter := domain.ChangeCounter{WorkspaceID: workspaceID, Value: int64(n)}
+	err := tx.Clauses(
+		clause.OnConflict{
+			Columns:   []clause.Column{{Name: "workspace_id"}},
+			DoUpdates: clause.Assignments(map[string]any{"value": gorm.Expr("change_counters.value + ?", n)}),
+		},
+		clause.Returning{Columns: []clause.Column{{Name: "value"}}},
+	).Create(&counter).Error
+	if err != nil {
+		return 0, err
+	}
+	return counter.Value - int64(n) + 1, nil
+}
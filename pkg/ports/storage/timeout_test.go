@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taheri24/helitask/pkg/config"
+	"github.com/taheri24/helitask/pkg/domain"
+	"github.com/taheri24/helitask/pkg/logger"
+	"github.com/taheri24/helitask/pkg/ports/storage/sqlite"
+)
+
+// TestTodoRepositoryCancellation tests that the context of a call and the configured timeouts stop the database work
+func TestTodoRepositoryCancellation(t *testing.T) {
+	db := sqlite.NewDb(t, "")
+	cfg := config.Default()
+	cfg.DB.ReadTimeout, cfg.DB.WriteTimeout = 50*time.Millisecond, 50*time.Millisecond
+	repo, txManager := NewTodoRepository(db, newReadRouter(db, nil, logger.Nop()), logger.Nop(), cfg), NewTxManager(db)
+	newItem := func() *domain.TodoItem {
+		return &domain.TodoItem{ID: domain.NewUUID(), Description: "Test Todo", DueDate: time.Now()}
+	}
+
+	t.Run("Canceled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+		todo := newItem()
+		assert.ErrorIs(t, repo.Create(ctx, todo), context.Canceled)
+		_, err := repo.GetByID(ctx, todo.ID)
+		assert.ErrorIs(t, err, context.Canceled)
+
+		_, err = repo.GetByID(t.Context(), todo.ID)
+		assert.ErrorIs(t, err, domain.ErrRecordNotFound, "the canceled create stored nothing")
+	})
+
+	t.Run("Expired deadline of the caller", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(t.Context(), time.Nanosecond)
+		defer cancel()
+		<-ctx.Done()
+		assert.ErrorIs(t, repo.Delete(ctx, domain.NewUUID()), context.DeadlineExceeded)
+	})
+
+	t.Run("Operation timeout", func(t *testing.T) {
+		todo := newItem()
+		assert.NoError(t, repo.Create(t.Context(), todo))
+
+		// the open transaction holds the only connection of the sqlite database,
+		// so calls outside of it wait until their timeout expires
+		err := txManager.WithinTx(t.Context(), func(ctx context.Context) error {
+			started := time.Now()
+			_, err := repo.GetByID(t.Context(), todo.ID)
+			assert.ErrorIs(t, err, context.DeadlineExceeded)
+			todo.Description = "Updated"
+			assert.ErrorIs(t, repo.Update(t.Context(), todo), context.DeadlineExceeded)
+			assert.Less(t, time.Since(started), time.Second)
+
+			_, err = repo.GetByID(ctx, todo.ID)
+			return err
+		})
+		assert.NoError(t, err)
+
+		stored, err := repo.GetByID(t.Context(), todo.ID)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "Test Todo", stored.Description)
+		}
+	})
+}
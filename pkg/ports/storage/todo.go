@@ -5,10 +5,15 @@ import (
 	"errors"
 
 	"fmt"
+	"slices"
+	"time"
 
+	"github.com/taheri24/helitask/pkg/config"
 	"github.com/taheri24/helitask/pkg/domain"
 	"github.com/taheri24/helitask/pkg/logger"
+	"go.uber.org/fx"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // Custom error for not found Todo items
@@ -17,30 +22,505 @@ var ErrTodoNotFound = errors.New("todo not found")
 // PostgresTodoRepository implements the TodoRepository interface
 type PostgresTodoRepository struct {
 	DB     *gorm.DB
+	reads  *ReadRouter
 	logger logger.Logger
+	// timeouts bound the statements of every operation
+	timeouts config.DatabaseConfig
 }
 
 // NewTodoRepository creates a new instance of the PostgresTodoRepository
-func NewTodoRepository(db *gorm.DB, logger logger.Logger) domain.TodoRepository {
-	return &PostgresTodoRepository{DB: db, logger: logger}
+func NewTodoRepository(db *gorm.DB, reads *ReadRouter, logger logger.Logger, cfg *config.Config) domain.TodoRepository {
+	return &PostgresTodoRepository{DB: db, reads: reads, logger: logger, timeouts: cfg.DB}
+}
+
+// inWorkspace is a gorm scope restricting a query to the workspace carried by ctx
+func inWorkspace(ctx context.Context) func(*gorm.DB) *gorm.DB {
+	workspaceID := domain.WorkspaceFromContext(ctx).ID
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("workspace_id = ?", workspaceID)
+	}
 }
 
 // Create implements the TodoRepository interface for PostgreSQL
 func (r *PostgresTodoRepository) Create(ctx context.Context, todo *domain.TodoItem) error {
+	ctx, cancel := withTimeout(ctx, r.timeouts.WriteTimeout)
+	defer cancel()
+	ws := domain.WorkspaceFromContext(ctx)
+	if err := checkDescriptions(ws, todo); err != nil {
+		return err
+	}
+	todo.WorkspaceID = ws.ID
 
-	if err := r.DB.Create(todo).Error; err != nil {
+	err := r.recorded(ctx, func(tx *gorm.DB, now time.Time) ([]todoChange, error) {
+		if err := checkQuota(ctx, tx, ws, 1); err != nil {
+			return nil, err
+		}
+		event := todo.Create(ctx, now)
+		if err := tx.Create(todo).Error; err != nil {
+			return nil, err
+		}
+		return []todoChange{{domain.AuditCreated, nil, todo, event}}, nil
+	})
+	if err != nil {
 		///r.logger.Error("Failed to save todo item", err)
-		return fmt.Errorf("failed to save todo item, %w", err)
+		return fmt.Errorf("failed to save todo item, %w", contextError(ctx, translateError(err)))
+	}
+	return nil
+}
+
+// todoChange is a change of one TodoItem made by a write, with the event the change raised;
+// before is nil for a created item
+type todoChange struct {
+	action        domain.AuditAction
+	before, after *domain.TodoItem
+	event         *domain.Event
+}
+
+// recorded runs write in a transaction that also appends the audit entry and the version of
+// every change write returns, and stores the event the change raised in the outbox, so that no
+// change is stored without them. The current versions of the changed items are closed at the
+// time passed to write, and the new versions take the next numbers of the change sequence of
+// the workspace, which also numbers their events.
+func (r *PostgresTodoRepository) recorded(ctx context.Context, write func(tx *gorm.DB, now time.Time) ([]todoChange, error)) error {
+	return conn(ctx, r.DB).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		changes, err := write(tx, now)
+		if err != nil || len(changes) == 0 {
+			return err
+		}
+		entries := make([]*domain.AuditEntry, len(changes))
+		versions := make([]*domain.TodoVersion, len(changes))
+		messages := make([]*domain.OutboxMessage, len(changes))
+		var changed []string
+		for i, change := range changes {
+			entries[i] = domain.NewAuditEntry(ctx, change.action, change.before, change.after, now)
+			versions[i] = domain.NewTodoVersion(change.after, now)
+			messages[i] = domain.NewOutboxMessage(change.event)
+			if change.before != nil {
+				changed = append(changed, change.after.ID.String())
+			}
+		}
+		first, err := nextSequences(tx, domain.WorkspaceFromContext(ctx).ID, len(changes))
+		if err != nil {
+			return err
+		}
+		for i, version := range versions {
+			version.Sequence = first + int64(i)
+			messages[i].Payload.Sequence = uint64(version.Sequence)
+		}
+		if len(changed) > 0 {
+			err := tx.Model(&domain.TodoVersion{}).Scopes(inWorkspace(ctx)).
+				Where("todo_id IN ? AND valid_to IS NULL", changed).Update("valid_to", now).Error
+			if err != nil {
+				return err
+			}
+		}
+		if err := tx.CreateInBatches(versions, createBatchSize).Error; err != nil {
+			return err
+		}
+		if err := tx.CreateInBatches(entries, createBatchSize).Error; err != nil {
+			return err
+		}
+		return tx.CreateInBatches(messages, createBatchSize).Error
+	})
+}
+
+// lockForWrite reads the current state of a TodoItem, locking its row until the transaction ends
+func lockForWrite(ctx context.Context, tx *gorm.DB, id domain.UUID, todo *domain.TodoItem) error {
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).Scopes(inWorkspace(ctx)).First(todo, "id = ?", id.String()).Error
+}
+
+// writeState stores the fields of after that writes may change, together with its version and field clocks
+func writeState(ctx context.Context, tx *gorm.DB, after *domain.TodoItem) error {
+	var deletedAt any
+	if after.DeletedAt.Valid {
+		deletedAt = after.DeletedAt.Time
+	}
+	return tx.Unscoped().Model(&domain.TodoItem{}).Scopes(inWorkspace(ctx)).Where("id = ?", after.ID.String()).
+		Updates(map[string]any{
+			"list_id":      after.ListID,
+			"description":  after.Description,
+			"due_date":     after.DueDate,
+			"completed":    after.Completed,
+			"deleted_at":   deletedAt,
+			"version":      after.Version,
+			"field_clocks": after.Clocks,
+		}).Error
+}
+
+// changeItem locks the TodoItem id, lets change apply an operation of the domain to the current
+// state and stores the resulting state as a new version. Nothing is written when the operation
+// raised no event.
+// Only items in the trash are changed when action restores one, and only items at the
+// version ctx expects when it expects one.
+func (r *PostgresTodoRepository) changeItem(ctx context.Context, tx *gorm.DB, id domain.UUID, action domain.AuditAction, change func(after *domain.TodoItem) (*domain.Event, error)) ([]todoChange, error) {
+	var before domain.TodoItem
+	locking := tx
+	if action == domain.AuditRestored {
+		locking = tx.Unscoped().Where("deleted_at IS NOT NULL")
+	}
+	if err := lockForWrite(ctx, locking, id, &before); err != nil {
+		return nil, err
+	}
+	if version, ok := domain.ExpectedVersion(ctx); ok && version != before.Version {
+		return nil, domain.ErrVersionConflict
+	}
+	after := before
+	event, err := change(&after)
+	if err != nil || event == nil {
+		return nil, err
+	}
+	if err := writeState(ctx, tx, &after); err != nil {
+		return nil, err
+	}
+	return []todoChange{{action, &before, &after, event}}, nil
+}
+
+// Update sets the description, due date, status and list of an existing TodoItem whose clocks
+// are later than the stored ones, and leaves todo holding the stored item
+func (r *PostgresTodoRepository) Update(ctx context.Context, todo *domain.TodoItem) error {
+	ctx, cancel := withTimeout(ctx, r.timeouts.WriteTimeout)
+	defer cancel()
+	ws := domain.WorkspaceFromContext(ctx)
+	if err := checkDescriptions(ws, todo); err != nil {
+		return err
+	}
+	var current domain.TodoItem
+	err := r.recorded(ctx, func(tx *gorm.DB, now time.Time) ([]todoChange, error) {
+		return r.changeItem(ctx, tx, todo.ID, domain.AuditUpdated, func(after *domain.TodoItem) (*domain.Event, error) {
+			// merging with the locked row keeps the fields written later since todo was read
+			event := after.Write(ctx, todo, todo.Clocks, now)
+			current = *after
+			return event, nil
+		})
+	})
+	if errors.Is(err, domain.ErrRecordNotFound) {
+		return domain.ErrRecordNotFound
+	} else if err != nil {
+		return fmt.Errorf("failed to update todo item, %w", contextError(ctx, err))
+	}
+	*todo = current
+	return nil
+}
+
+// createBatchSize bounds the number of rows of a single multi-row insert
+const createBatchSize = 100
+
+// CreateMany stores all todo items with multi-row inserts
+func (r *PostgresTodoRepository) CreateMany(ctx context.Context, todos []*domain.TodoItem) error {
+	if len(todos) == 0 {
+		return nil
+	}
+	ctx, cancel := withTimeout(ctx, r.timeouts.BatchTimeout)
+	defer cancel()
+	ws := domain.WorkspaceFromContext(ctx)
+	if err := checkDescriptions(ws, todos...); err != nil {
+		return err
+	}
+	for _, todo := range todos {
+		todo.WorkspaceID = ws.ID
+	}
+
+	err := r.recorded(ctx, func(tx *gorm.DB, now time.Time) ([]todoChange, error) {
+		if err := checkQuota(ctx, tx, ws, len(todos)); err != nil {
+			return nil, err
+		}
+		changes := make([]todoChange, len(todos))
+		for i, todo := range todos {
+			changes[i] = todoChange{domain.AuditCreated, nil, todo, todo.Create(ctx, now)}
+		}
+		if err := tx.CreateInBatches(todos, createBatchSize).Error; err != nil {
+			return nil, err
+		}
+		return changes, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save todo items, %w", contextError(ctx, translateError(err)))
+	}
+	return nil
+}
+
+// checkDescriptions verifies that the descriptions of todos fit the quota of the workspace
+func checkDescriptions(ws domain.Workspace, todos ...*domain.TodoItem) error {
+	for _, todo := range todos {
+		if max := ws.Quota.MaxDescriptionSize; max > 0 && len(todo.Description) > max {
+			return domain.ErrDescriptionTooLarge
+		}
+	}
+	return nil
+}
+
+// checkQuota verifies that n more items outside the trash keep the workspace within its quota.
+// It counts while holding the change counter of the workspace, which every write takes before
+// it commits, so that concurrent writes cannot each pass the check on the same count.
+func checkQuota(ctx context.Context, tx *gorm.DB, ws domain.Workspace, n int) error {
+	if ws.Quota.MaxTodos <= 0 {
+		return nil
+	}
+	if _, err := nextSequences(tx, ws.ID, 0); err != nil {
+		return fmt.Errorf("failed to lock the change counter, %w", err)
+	}
+	var count int64
+	if err := tx.Model(&domain.TodoItem{}).Scopes(inWorkspace(ctx)).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to count todo items, %w", err)
+	}
+	if count+int64(n) > int64(ws.Quota.MaxTodos) {
+		return domain.ErrTodoQuotaExceeded
 	}
 	return nil
 }
 
-// GetByID retrieves a TodoItem by ID
-func (r *PostgresTodoRepository) GetByID(context context.Context, id domain.UUID) (*domain.TodoItem, error) {
+// Delete moves a TodoItem to the trash by setting its deleted_at
+func (r *PostgresTodoRepository) Delete(ctx context.Context, id domain.UUID) error {
+	ctx, cancel := withTimeout(ctx, r.timeouts.WriteTimeout)
+	defer cancel()
+	err := r.recorded(ctx, func(tx *gorm.DB, now time.Time) ([]todoChange, error) {
+		return r.changeItem(ctx, tx, id, domain.AuditDeleted, func(after *domain.TodoItem) (*domain.Event, error) {
+			return after.Delete(ctx, now), nil
+		})
+	})
+	if errors.Is(err, domain.ErrRecordNotFound) {
+		return domain.ErrRecordNotFound
+	} else if err != nil {
+		return fmt.Errorf("failed to delete todo item, %w", contextError(ctx, err))
+	}
+	return nil
+}
+
+// GetByID retrieves a TodoItem by ID, from a read replica unless ctx asks for the primary
+func (r *PostgresTodoRepository) GetByID(ctx context.Context, id domain.UUID) (*domain.TodoItem, error) {
+	ctx, cancel := withTimeout(ctx, r.timeouts.ReadTimeout)
+	defer cancel()
 	var todo domain.TodoItem
 	key := id.String()
-	if err := r.DB.First(&todo, "id=?", key).Error; err != nil {
-		return nil, err
+	err := r.reads.read(ctx, func(db *gorm.DB) error {
+		if domain.IncludesDeleted(ctx) {
+			db = db.Unscoped()
+		}
+		return db.Scopes(inWorkspace(ctx)).First(&todo, "id=?", key).Error
+	})
+	if err != nil {
+		return nil, contextError(ctx, err)
 	}
 	return &todo, nil
 }
+
+// GetByIDs retrieves the TodoItems with the given ids in one query
+func (r *PostgresTodoRepository) GetByIDs(ctx context.Context, ids []domain.UUID) ([]*domain.TodoItem, error) {
+	ctx, cancel := withTimeout(ctx, r.timeouts.ReadTimeout)
+	defer cancel()
+	var todos []*domain.TodoItem
+	err := r.reads.read(ctx, func(db *gorm.DB) error {
+		if domain.IncludesDeleted(ctx) {
+			db = db.Unscoped()
+		}
+		return db.Scopes(inWorkspace(ctx)).Where("id IN ?", uuidStrings(ids)).Find(&todos).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch todo items, %w", contextError(ctx, err))
+	}
+	return todos, nil
+}
+
+// uuidStrings formats ids the way GetByID queries them
+func uuidStrings(ids []domain.UUID) []string {
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = id.String()
+	}
+	return keys
+}
+
+// GetAsOf retrieves a TodoItem as it was at the given time from its versions
+func (r *PostgresTodoRepository) GetAsOf(ctx context.Context, id domain.UUID, at time.Time) (*domain.TodoItem, error) {
+	ctx, cancel := withTimeout(ctx, r.timeouts.ReadTimeout)
+	defer cancel()
+	var version domain.TodoVersion
+	err := r.reads.read(ctx, func(db *gorm.DB) error {
+		return db.Scopes(inWorkspace(ctx)).
+			Where("todo_id = ? AND valid_from <= ? AND (valid_to IS NULL OR valid_to > ?)", id.String(), at, at).
+			First(&version).Error
+	})
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+	if version.DeletedAt != nil && !domain.IncludesDeleted(ctx) {
+		return nil, domain.ErrRecordNotFound
+	}
+	return version.Item(), nil
+}
+
+// Revert copies the fields of an earlier version of a TodoItem over its current state
+func (r *PostgresTodoRepository) Revert(ctx context.Context, id domain.UUID, version int64, at domain.HLC) (*domain.TodoItem, error) {
+	ctx, cancel := withTimeout(ctx, r.timeouts.WriteTimeout)
+	defer cancel()
+	var current domain.TodoItem
+	err := r.recorded(ctx, func(tx *gorm.DB, now time.Time) ([]todoChange, error) {
+		return r.changeItem(ctx, tx, id, domain.AuditReverted, func(after *domain.TodoItem) (*domain.Event, error) {
+			var target domain.TodoVersion
+			err := tx.Scopes(inWorkspace(ctx)).First(&target, "todo_id = ? AND version = ?", id.String(), version).Error
+			if err != nil {
+				return nil, err
+			}
+			event, err := after.Revert(ctx, &target, at, now)
+			current = *after
+			return event, err
+		})
+	})
+	if errors.Is(err, domain.ErrRecordNotFound) || errors.Is(err, domain.ErrDeletedVersion) {
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to revert todo item, %w", contextError(ctx, err))
+	}
+	return &current, nil
+}
+
+// List returns the TodoItems of the workspace matching filter, by due date and then id
+func (r *PostgresTodoRepository) List(ctx context.Context, filter domain.TodoFilter, page domain.Page) ([]*domain.TodoItem, error) {
+	ctx, cancel := withTimeout(ctx, r.timeouts.ReadTimeout)
+	defer cancel()
+	var todos []*domain.TodoItem
+	err := r.reads.read(ctx, func(db *gorm.DB) error {
+		query := db.Scopes(inWorkspace(ctx))
+		if filter.ListID != nil {
+			query = query.Where("list_id = ?", *filter.ListID)
+		}
+		if filter.OwnerID != "" {
+			query = query.Where("owner_id = ?", filter.OwnerID)
+		}
+		if filter.Completed != nil {
+			query = query.Where("completed = ?", *filter.Completed)
+		}
+		return query.Order("due_date").Order("id").Limit(page.Limit).Offset(page.Offset).Find(&todos).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list todo items, %w", contextError(ctx, err))
+	}
+	return todos, nil
+}
+
+// ListDeleted returns the TodoItems in the trash of the workspace, most recently deleted first
+func (r *PostgresTodoRepository) ListDeleted(ctx context.Context, page domain.Page) ([]*domain.TodoItem, error) {
+	ctx, cancel := withTimeout(ctx, r.timeouts.ReadTimeout)
+	defer cancel()
+	var todos []*domain.TodoItem
+	err := r.reads.read(ctx, func(db *gorm.DB) error {
+		return db.Unscoped().Scopes(inWorkspace(ctx)).Where("deleted_at IS NOT NULL").
+			Order("deleted_at DESC").Order("id").Limit(page.Limit).Offset(page.Offset).Find(&todos).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deleted todo items, %w", contextError(ctx, err))
+	}
+	return todos, nil
+}
+
+// Restore takes a TodoItem out of the trash
+func (r *PostgresTodoRepository) Restore(ctx context.Context, id domain.UUID) error {
+	ctx, cancel := withTimeout(ctx, r.timeouts.WriteTimeout)
+	defer cancel()
+	err := r.recorded(ctx, func(tx *gorm.DB, now time.Time) ([]todoChange, error) {
+		return r.changeItem(ctx, tx, id, domain.AuditRestored, func(after *domain.TodoItem) (*domain.Event, error) {
+			// the item counts again once it is out of the trash
+			if err := checkQuota(ctx, tx, domain.WorkspaceFromContext(ctx), 1); err != nil {
+				return nil, err
+			}
+			return after.Restore(ctx, now), nil
+		})
+	})
+	if errors.Is(err, domain.ErrRecordNotFound) {
+		return domain.ErrRecordNotFound
+	} else if err != nil {
+		return fmt.Errorf("failed to restore todo item, %w", contextError(ctx, err))
+	}
+	return nil
+}
+
+// PurgeDeleted permanently removes the TodoItems of every workspace deleted before the given time,
+// together with their versions, so that their ids can be used again. Their audit entries are kept.
+func (r *PostgresTodoRepository) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	var purged int64
+	err := conn(ctx, r.DB).Transaction(func(tx *gorm.DB) error {
+		// locking the items keeps them from being restored while their versions are removed
+		var items []domain.TodoItem
+		err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).Select("workspace_id", "id").
+			Where("deleted_at < ?", before).Find(&items).Error
+		if err != nil {
+			return err
+		}
+		byWorkspace := make(map[string][]string)
+		for _, item := range items {
+			byWorkspace[item.WorkspaceID] = append(byWorkspace[item.WorkspaceID], item.ID.String())
+		}
+		for workspaceID, ids := range byWorkspace {
+			var through int64
+			for chunk := range slices.Chunk(ids, createBatchSize) {
+				var last int64
+				err := tx.Model(&domain.TodoVersion{}).Where("workspace_id = ? AND todo_id IN ?", workspaceID, chunk).
+					Select("COALESCE(MAX(sequence), 0)").Scan(&last).Error
+				if err != nil {
+					return err
+				}
+				through = max(through, last)
+				err = tx.Where("workspace_id = ? AND todo_id IN ?", workspaceID, chunk).Delete(&domain.TodoVersion{}).Error
+				if err != nil {
+					return err
+				}
+				res := tx.Unscoped().Where("workspace_id = ? AND id IN ?", workspaceID, chunk).Delete(&domain.TodoItem{})
+				if res.Error != nil {
+					return res.Error
+				}
+				purged += res.RowsAffected
+			}
+			if err := markPurged(tx, workspaceID, through); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge deleted todo items, %w", contextError(ctx, err))
+	}
+	return purged, nil
+}
+
+// markPurged records that the changes of the workspace up to sequence through may have been
+// removed, so that clients which synced before them start over
+func markPurged(tx *gorm.DB, workspaceID string, through int64) error {
+	if _, err := nextSequences(tx, workspaceID, 0); err != nil {
+		return err
+	}
+	return tx.Model(&domain.ChangeCounter{}).Where("workspace_id = ? AND purged_through < ?", workspaceID, through).
+		Update("purged_through", through).Error
+}
+
+// StartTrashPurge periodically purges the todo items deleted longer than the retention ago
+func StartTrashPurge(lc fx.Lifecycle, repo domain.TodoRepository, cfg *config.Config, logger logger.Logger) {
+	interval := cfg.Trash.PurgeInterval
+	if interval <= 0 {
+		return
+	}
+	done := make(chan struct{})
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go func() {
+				ticker := time.NewTicker(interval)
+				defer ticker.Stop()
+				for {
+					select {
+					case <-done:
+						return
+					case now := <-ticker.C:
+						if _, err := repo.PurgeDeleted(context.Background(), now.Add(-cfg.Trash.Retention)); err != nil {
+							logger.Error("Failed to purge deleted todo items", err)
+						}
+					}
+				}
+			}()
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			close(done)
+			return nil
+		},
+	})
+}
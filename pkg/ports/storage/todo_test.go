@@ -1,10 +1,13 @@
 package storage
 
 import (
+	"errors"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/taheri24/helitask/pkg/config"
 	"github.com/taheri24/helitask/pkg/domain"
 	"github.com/taheri24/helitask/pkg/logger"
 	"github.com/taheri24/helitask/pkg/ports/storage/postgres"
@@ -21,7 +24,7 @@ func setupApp(t *testing.T, options ...fx.Option) (sqlmock.Sqlmock, *fxtest.App)
 		fx.NopLogger, // remove this to getting more details on DI problems
 		fx.Provide(func() logger.Logger {
 			return testLogger
-		}), fx.Supply(db, sqlMock), Module}, options...)
+		}), fx.Supply(db, sqlMock, config.Default()), Module}, options...)
 	fxApp := fxtest.New(t, opts...)
 	return sqlMock, fxApp
 }
@@ -36,7 +39,18 @@ func TestCreateTodoItem(t *testing.T) {
 		DueDate:     time.Now(),
 	}
 
-	mockSql.ExpectExec(`^INSERT INTO.+todo_items.+`).WithArgs(freshItem.ID, freshItem.Description, freshItem.DueDate).WillReturnResult(sqlmock.NewResult(0, 1))
+	// the item, its first version, its audit entry and its event are written in one transaction
+	mockSql.ExpectBegin()
+	mockSql.ExpectExec(`^INSERT INTO.+todo_items.+`).WithArgs(domain.DefaultWorkspaceID, freshItem.ID, nil, "", freshItem.Description, freshItem.DueDate, false, 1, sqlmock.AnyArg(), nil).WillReturnResult(sqlmock.NewResult(0, 1))
+	mockSql.ExpectQuery(`^INSERT INTO.+change_counters.+ON CONFLICT.+RETURNING`).WithArgs(domain.DefaultWorkspaceID, 1, 0, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow(7))
+	mockSql.ExpectExec(`^INSERT INTO.+todo_item_versions.+`).WithArgs(domain.DefaultWorkspaceID, freshItem.ID, 1, nil, "", freshItem.Description, freshItem.DueDate, false, nil, sqlmock.AnyArg(), sqlmock.AnyArg(), 7, nil).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mockSql.ExpectQuery(`^INSERT INTO.+todo_audit_log.+`).WithArgs(domain.DefaultWorkspaceID, freshItem.ID, 1, domain.AuditCreated, "", "", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mockSql.ExpectQuery(`^INSERT INTO.+outbox.+`).WithArgs(sqlmock.AnyArg(), domain.DefaultWorkspaceID, domain.TodoCreated, sqlmock.AnyArg(), sqlmock.AnyArg(), 0, "", nil, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mockSql.ExpectCommit()
 	if err := repo.Create(t.Context(), freshItem); err != nil {
 		t.Errorf("repo.Create failed  ,%s", err)
 		return
@@ -48,3 +62,59 @@ func TestCreateTodoItem(t *testing.T) {
 	}
 
 }
+
+func TestCreateTodoItemConflict(t *testing.T) {
+	var repo domain.TodoRepository
+	mockSql, fxApp := setupApp(t, fx.Populate(&repo))
+	fxApp.RequireStart()
+	defer fxApp.RequireStop()
+	freshItem := &domain.TodoItem{
+		ID:          domain.NewUUID(),
+		Description: "Test Todo",
+		DueDate:     time.Now(),
+	}
+
+	mockSql.ExpectBegin()
+	mockSql.ExpectExec(`^INSERT INTO.+todo_items.+`).WillReturnError(&pgconn.PgError{Code: "23505"})
+	mockSql.ExpectRollback()
+	err := repo.Create(t.Context(), freshItem)
+	if !errors.Is(err, domain.ErrConflict) {
+		t.Errorf("repo.Create returned %v, want domain.ErrConflict", err)
+	}
+	if err := mockSql.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestCreateManyTodoItems(t *testing.T) {
+	var repo domain.TodoRepository
+	mockSql, fxApp := setupApp(t, fx.Populate(&repo))
+	fxApp.RequireStart()
+	defer fxApp.RequireStop()
+	items := []*domain.TodoItem{
+		{ID: domain.NewUUID(), Description: "First Todo", DueDate: time.Now()},
+		{ID: domain.NewUUID(), Description: "Second Todo", DueDate: time.Now()},
+	}
+
+	// both rows are written by a single statement, and so are their versions, audit entries and events
+	mockSql.ExpectBegin()
+	mockSql.ExpectExec(`^INSERT INTO.+todo_items.+VALUES \(.+\),\(.+\)$`).
+		WithArgs(domain.DefaultWorkspaceID, items[0].ID, nil, "", items[0].Description, items[0].DueDate, false, 1, sqlmock.AnyArg(), nil,
+			domain.DefaultWorkspaceID, items[1].ID, nil, "", items[1].Description, items[1].DueDate, false, 1, sqlmock.AnyArg(), nil).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mockSql.ExpectQuery(`^INSERT INTO.+change_counters.+ON CONFLICT.+RETURNING`).WithArgs(domain.DefaultWorkspaceID, 2, 0, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow(2))
+	mockSql.ExpectExec(`^INSERT INTO.+todo_item_versions.+VALUES \(.+\),\(.+\)$`).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mockSql.ExpectQuery(`^INSERT INTO.+todo_audit_log.+VALUES \(.+\),\(.+\) RETURNING`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+	mockSql.ExpectQuery(`^INSERT INTO.+outbox.+VALUES \(.+\),\(.+\) RETURNING`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+	mockSql.ExpectCommit()
+	if err := repo.CreateMany(t.Context(), items); err != nil {
+		t.Errorf("repo.CreateMany failed  ,%s", err)
+	}
+	if err := mockSql.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
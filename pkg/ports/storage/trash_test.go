@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taheri24/helitask/pkg/config"
+	"github.com/taheri24/helitask/pkg/domain"
+	"github.com/taheri24/helitask/pkg/logger"
+	"github.com/taheri24/helitask/pkg/ports/storage/sqlite"
+)
+
+func TestTodoTrash(t *testing.T) {
+	db := sqlite.NewDb(t, "")
+	repo := NewTodoRepository(db, newReadRouter(db, nil, logger.Nop()), logger.Nop(), config.Default())
+	ctx := t.Context()
+	todos := make([]*domain.TodoItem, 3)
+	for i := range todos {
+		todos[i] = &domain.TodoItem{ID: domain.NewUUID(), Description: "Test Todo", DueDate: time.Now()}
+		assert.NoError(t, repo.Create(ctx, todos[i]))
+	}
+	deletedIDs := func(page domain.Page) []domain.UUID {
+		deleted, err := repo.ListDeleted(ctx, page)
+		assert.NoError(t, err)
+		ids := make([]domain.UUID, len(deleted))
+		for i, todo := range deleted {
+			ids[i] = todo.ID
+		}
+		return ids
+	}
+
+	assert.NoError(t, repo.Delete(ctx, todos[0].ID))
+	time.Sleep(time.Millisecond)
+	assert.NoError(t, repo.Delete(ctx, todos[1].ID))
+	assert.ErrorIs(t, repo.Delete(ctx, todos[1].ID), domain.ErrRecordNotFound, "items in the trash cannot be deleted again")
+
+	_, err := repo.GetByID(ctx, todos[0].ID)
+	assert.ErrorIs(t, err, domain.ErrRecordNotFound)
+	deleted, err := repo.GetByID(domain.WithDeleted(ctx), todos[0].ID)
+	if assert.NoError(t, err) {
+		assert.True(t, deleted.DeletedAt.Valid)
+	}
+
+	assert.Equal(t, []domain.UUID{todos[1].ID, todos[0].ID}, deletedIDs(domain.Page{Limit: 10}))
+	assert.Equal(t, []domain.UUID{todos[0].ID}, deletedIDs(domain.Page{Limit: 1, Offset: 1}))
+	other := domain.WithWorkspace(ctx, domain.Workspace{ID: "other"})
+	deletedElsewhere, err := repo.ListDeleted(other, domain.Page{Limit: 10})
+	assert.NoError(t, err)
+	assert.Empty(t, deletedElsewhere)
+
+	assert.NoError(t, repo.Restore(ctx, todos[0].ID))
+	assert.ErrorIs(t, repo.Restore(ctx, todos[0].ID), domain.ErrRecordNotFound, "only items in the trash can be restored")
+	assert.ErrorIs(t, repo.Restore(ctx, todos[2].ID), domain.ErrRecordNotFound)
+	_, err = repo.GetByID(ctx, todos[0].ID)
+	assert.NoError(t, err)
+
+	purged, err := repo.PurgeDeleted(ctx, time.Now().Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Zero(t, purged, "items deleted within the retention are kept")
+	var tombstone domain.TodoVersion
+	assert.NoError(t, db.Where("todo_id = ? AND valid_to IS NULL", todos[1].ID.String()).First(&tombstone).Error)
+	purged, err = repo.PurgeDeleted(ctx, time.Now().Add(time.Second))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+	_, err = repo.GetByID(domain.WithDeleted(ctx), todos[1].ID)
+	assert.ErrorIs(t, err, domain.ErrRecordNotFound)
+	assert.Empty(t, deletedIDs(domain.Page{Limit: 10}))
+	var versions int64
+	assert.NoError(t, db.Model(&domain.TodoVersion{}).Where("todo_id = ?", todos[1].ID.String()).Count(&versions).Error)
+	assert.Zero(t, versions, "the versions of purged items are removed with them")
+	var counter domain.ChangeCounter
+	assert.NoError(t, db.First(&counter, "workspace_id = ?", tombstone.WorkspaceID).Error)
+	assert.Equal(t, tombstone.Sequence, counter.PurgedThrough, "the purge records the last change it removed")
+	changes := NewSyncRepository(db, newReadRouter(db, nil, logger.Nop()))
+	_, err = changes.Changes(ctx, domain.SyncCursor{Sequence: tombstone.Sequence - 1}, 10)
+	assert.ErrorIs(t, err, domain.ErrResyncRequired, "a cursor before the purged changes may have missed deletions")
+	_, err = changes.Changes(ctx, domain.SyncCursor{Sequence: tombstone.Sequence, TodoID: tombstone.TodoID}, 10)
+	assert.NoError(t, err)
+	_, err = changes.Changes(ctx, domain.SyncCursor{}, 10)
+	assert.NoError(t, err, "a first sync needs none of the purged changes")
+
+	recreated := &domain.TodoItem{ID: todos[1].ID, Description: "Test Todo", DueDate: time.Now()}
+	assert.NoError(t, repo.Create(ctx, recreated), "the ids of purged items can be used again")
+	current, err := repo.GetAsOf(ctx, recreated.ID, time.Now())
+	if assert.NoError(t, err) {
+		assert.False(t, current.DeletedAt.Valid)
+	}
+}
@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"context"
+	"sync"
+
+	"github.com/taheri24/helitask/pkg/domain"
+	"gorm.io/gorm"
+)
+
+type txKey struct{}
+
+type txHooksKey struct{}
+
+// txHooks holds the functions to run once the outermost transaction has ended
+type txHooks struct {
+	mu  sync.Mutex
+	fns []func()
+}
+
+// GormTxManager implements domain.TxManager on top of gorm transactions.
+// It works with every gorm dialect, so the Postgres and the sqlite databases share it.
+type GormTxManager struct {
+	DB *gorm.DB
+}
+
+// NewTxManager creates a new instance of the GormTxManager
+func NewTxManager(db *gorm.DB) domain.TxManager {
+	return &GormTxManager{DB: db}
+}
+
+// WithinTx runs fn in a transaction stored in its context. When ctx already carries one,
+// fn runs in a savepoint that is rolled back on its own when fn fails.
+// The functions registered with afterTx run once the outermost transaction has ended.
+func (m *GormTxManager) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		// gorm turns nested transactions into savepoints
+		return tx.Transaction(func(tx *gorm.DB) error {
+			return fn(context.WithValue(ctx, txKey{}, tx))
+		})
+	}
+	hooks := &txHooks{}
+	ctx = context.WithValue(ctx, txHooksKey{}, hooks)
+	err := m.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(context.WithValue(ctx, txKey{}, tx))
+	})
+	hooks.mu.Lock()
+	fns := hooks.fns
+	hooks.fns = nil
+	hooks.mu.Unlock()
+	for _, fn := range fns {
+		fn()
+	}
+	return err
+}
+
+// afterTx runs fn once the transaction of ctx has ended, whether it committed or not,
+// or right away outside of a transaction
+func afterTx(ctx context.Context, fn func()) {
+	hooks, ok := ctx.Value(txHooksKey{}).(*txHooks)
+	if !ok {
+		fn()
+		return
+	}
+	hooks.mu.Lock()
+	defer hooks.mu.Unlock()
+	hooks.fns = append(hooks.fns, fn)
+}
+
+// conn returns the transaction carried by ctx, or db outside of one, bound to ctx
+func conn(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return tx.WithContext(ctx)
+	}
+	return db.WithContext(ctx)
+}
@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taheri24/helitask/pkg/config"
+	"github.com/taheri24/helitask/pkg/domain"
+	"github.com/taheri24/helitask/pkg/logger"
+	"github.com/taheri24/helitask/pkg/ports/storage/sqlite"
+)
+
+func TestTxManager(t *testing.T) {
+	db := sqlite.NewDb(t, "")
+	repo, txManager := NewTodoRepository(db, newReadRouter(db, nil, logger.Nop()), logger.Nop(), config.Default()), NewTxManager(db)
+	errAbort := errors.New("abort")
+	newItem := func(description string) *domain.TodoItem {
+		return &domain.TodoItem{ID: domain.NewUUID(), Description: description, DueDate: time.Now()}
+	}
+	exists := func(ctx context.Context, todo *domain.TodoItem) bool {
+		_, err := repo.GetByID(ctx, todo.ID)
+		return err == nil
+	}
+
+	t.Run("Commit", func(t *testing.T) {
+		first, second := newItem("first"), newItem("second")
+		err := txManager.WithinTx(t.Context(), func(ctx context.Context) error {
+			if err := repo.Create(ctx, first); err != nil {
+				return err
+			}
+			assert.True(t, exists(ctx, first), "writes are visible inside the transaction")
+			return repo.Create(ctx, second)
+		})
+		assert.NoError(t, err)
+		assert.True(t, exists(t.Context(), first))
+		assert.True(t, exists(t.Context(), second))
+	})
+
+	t.Run("Rollback", func(t *testing.T) {
+		first := newItem("rolled back")
+		err := txManager.WithinTx(t.Context(), func(ctx context.Context) error {
+			if err := repo.Create(ctx, first); err != nil {
+				return err
+			}
+			return errAbort
+		})
+		assert.ErrorIs(t, err, errAbort)
+		assert.False(t, exists(t.Context(), first))
+	})
+
+	t.Run("Nested savepoint", func(t *testing.T) {
+		outer, inner := newItem("outer"), newItem("inner")
+		err := txManager.WithinTx(t.Context(), func(ctx context.Context) error {
+			if err := repo.Create(ctx, outer); err != nil {
+				return err
+			}
+			err := txManager.WithinTx(ctx, func(ctx context.Context) error {
+				if err := repo.Create(ctx, inner); err != nil {
+					return err
+				}
+				return errAbort
+			})
+			assert.ErrorIs(t, err, errAbort)
+			// only the savepoint was rolled back
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.True(t, exists(t.Context(), outer))
+		assert.False(t, exists(t.Context(), inner))
+	})
+
+	t.Run("Nested failure rolls back everything", func(t *testing.T) {
+		outer, inner := newItem("outer"), newItem("inner")
+		err := txManager.WithinTx(t.Context(), func(ctx context.Context) error {
+			if err := repo.Create(ctx, outer); err != nil {
+				return err
+			}
+			return txManager.WithinTx(ctx, func(ctx context.Context) error {
+				if err := repo.Create(ctx, inner); err != nil {
+					return err
+				}
+				return errAbort
+			})
+		})
+		assert.ErrorIs(t, err, errAbort)
+		assert.False(t, exists(t.Context(), outer))
+		assert.False(t, exists(t.Context(), inner))
+	})
+}
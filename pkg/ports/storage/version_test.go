@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taheri24/helitask/pkg/config"
+	"github.com/taheri24/helitask/pkg/domain"
+	"github.com/taheri24/helitask/pkg/logger"
+	"github.com/taheri24/helitask/pkg/ports/storage/sqlite"
+)
+
+// testClock stamps the writes of the tests
+var testClock = domain.NewHLCClock("test")
+
+// update stores the fields of todo changed from the stored item with new clocks, as the todo
+// service does
+func update(ctx context.Context, repo domain.TodoRepository, todo *domain.TodoItem) error {
+	if stored, err := repo.GetByID(domain.WithPrimary(ctx), todo.ID); err == nil {
+		todo.Clocks = stored.Clocks
+		todo.Stamp(stored, testClock.Now)
+	}
+	return repo.Update(ctx, todo)
+}
+
+func TestTodoVersions(t *testing.T) {
+	db := sqlite.NewDb(t, "")
+	repo := NewTodoRepository(db, newReadRouter(db, nil, logger.Nop()), logger.Nop(), config.Default())
+	ctx := t.Context()
+	todo := &domain.TodoItem{ID: domain.NewUUID(), Description: "First", DueDate: time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)}
+	asOf := func(ctx context.Context, at time.Time) *domain.TodoItem {
+		item, err := repo.GetAsOf(ctx, todo.ID, at)
+		if err != nil {
+			return nil
+		}
+		return item
+	}
+
+	beforeCreate := time.Now()
+	assert.NoError(t, repo.Create(ctx, todo))
+	assert.Equal(t, int64(1), todo.Version)
+	afterCreate := time.Now()
+	todo.Description = "Second"
+	assert.NoError(t, update(ctx, repo, todo))
+	assert.Equal(t, int64(2), todo.Version)
+	todo.Description = "Stale"
+	assert.ErrorIs(t, update(domain.WithExpectedVersion(ctx, 1), repo, todo), domain.ErrVersionConflict)
+	todo.Description = "Second"
+	assert.NoError(t, update(domain.WithExpectedVersion(ctx, 2), repo, todo), "an update at the expected version")
+	assert.NoError(t, update(ctx, repo, todo), "an update without changes")
+	assert.Equal(t, int64(2), todo.Version, "an update without changes keeps the version")
+	afterUpdate := time.Now()
+	assert.NoError(t, repo.Delete(ctx, todo.ID))
+	afterDelete := time.Now()
+
+	assert.Nil(t, asOf(ctx, beforeCreate), "the item did not exist yet")
+	if item := asOf(ctx, afterCreate); assert.NotNil(t, item) {
+		assert.Equal(t, "First", item.Description)
+		assert.Equal(t, int64(1), item.Version)
+	}
+	if item := asOf(ctx, afterUpdate); assert.NotNil(t, item) {
+		assert.Equal(t, "Second", item.Description)
+	}
+	assert.Nil(t, asOf(ctx, afterDelete), "the item was in the trash")
+	if item := asOf(domain.WithDeleted(ctx), afterDelete); assert.NotNil(t, item) {
+		assert.True(t, item.DeletedAt.Valid)
+		assert.Equal(t, int64(3), item.Version)
+	}
+
+	assert.NoError(t, repo.Restore(ctx, todo.ID))
+	at := domain.NewHLCClock("test").Now()
+	_, err := repo.Revert(ctx, todo.ID, 3, at)
+	assert.ErrorIs(t, err, domain.ErrDeletedVersion)
+	_, err = repo.Revert(ctx, todo.ID, 9, at)
+	assert.ErrorIs(t, err, domain.ErrRecordNotFound)
+
+	reverted, err := repo.Revert(ctx, todo.ID, 1, at)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "First", reverted.Description)
+		assert.Equal(t, int64(5), reverted.Version, "a revert is recorded as a new version")
+		assert.Equal(t, at, reverted.Clocks[domain.FieldDescription], "the reverted fields are stamped")
+		assert.NotContains(t, reverted.Clocks, domain.FieldCompleted, "the other fields keep their clock")
+	}
+	current, err := repo.GetByID(ctx, todo.ID)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "First", current.Description)
+		assert.Equal(t, int64(5), current.Version)
+	}
+	if item := asOf(ctx, afterUpdate); assert.NotNil(t, item) {
+		assert.Equal(t, "Second", item.Description, "earlier versions are kept")
+	}
+
+	other := domain.WithWorkspace(ctx, domain.Workspace{ID: "other"})
+	_, err = repo.GetAsOf(other, todo.ID, time.Now())
+	assert.ErrorIs(t, err, domain.ErrRecordNotFound)
+}
+
+// TestTodoUpdateKeepsLaterWrites tests that an update read before a later write of another field
+// keeps that write
+func TestTodoUpdateKeepsLaterWrites(t *testing.T) {
+	db := sqlite.NewDb(t, "")
+	repo := NewTodoRepository(db, newReadRouter(db, nil, logger.Nop()), logger.Nop(), config.Default())
+	ctx := t.Context()
+	todo := &domain.TodoItem{ID: domain.NewUUID(), Description: "First", DueDate: time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)}
+	todo.Stamp(nil, testClock.Now)
+	assert.NoError(t, repo.Create(ctx, todo))
+
+	stale := *todo
+	stale.Completed = true
+	stale.Stamp(todo, testClock.Now)
+	merged := *todo
+	merged.Description = "Merged"
+	merged.Stamp(todo, testClock.Now)
+	assert.NoError(t, repo.Update(ctx, &merged))
+
+	assert.NoError(t, repo.Update(ctx, &stale))
+	assert.Equal(t, "Merged", stale.Description, "the later write of the description is kept")
+	assert.True(t, stale.Completed)
+	assert.Equal(t, merged.Clocks[domain.FieldDescription], stale.Clocks[domain.FieldDescription])
+	assert.Equal(t, int64(3), stale.Version)
+}
@@ -0,0 +1,189 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/taheri24/helitask/pkg/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PostgresWebhookRepository implements the WebhookRepository interface
+type PostgresWebhookRepository struct {
+	DB *gorm.DB
+}
+
+// NewWebhookRepository creates a new instance of the PostgresWebhookRepository
+func NewWebhookRepository(db *gorm.DB) domain.WebhookRepository {
+	return &PostgresWebhookRepository{DB: db}
+}
+
+// Create implements domain.WebhookRepository
+func (r *PostgresWebhookRepository) Create(ctx context.Context, webhook *domain.Webhook) error {
+	webhook.WorkspaceID = domain.WorkspaceFromContext(ctx).ID
+	if err := conn(ctx, r.DB).Create(webhook).Error; err != nil {
+		return fmt.Errorf("failed to save webhook, %w", translateError(err))
+	}
+	return nil
+}
+
+// GetByID implements domain.WebhookRepository
+func (r *PostgresWebhookRepository) GetByID(ctx context.Context, id domain.UUID) (*domain.Webhook, error) {
+	var webhook domain.Webhook
+	if err := conn(ctx, r.DB).Scopes(inWorkspace(ctx)).First(&webhook, "id = ?", id.String()).Error; err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+// List implements domain.WebhookRepository
+func (r *PostgresWebhookRepository) List(ctx context.Context, ownerID string) ([]*domain.Webhook, error) {
+	var webhooks []*domain.Webhook
+	err := conn(ctx, r.DB).Scopes(inWorkspace(ctx)).Where("owner_id = ?", ownerID).Order("created_at").Order("id").Find(&webhooks).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhooks, %w", err)
+	}
+	return webhooks, nil
+}
+
+// Update replaces the URL, secret, events and state of a webhook
+func (r *PostgresWebhookRepository) Update(ctx context.Context, webhook *domain.Webhook) error {
+	res := conn(ctx, r.DB).Scopes(inWorkspace(ctx)).Select("url", "secret", "events", "active").Updates(webhook)
+	if res.Error != nil {
+		return fmt.Errorf("failed to update webhook, %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return domain.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Delete implements domain.WebhookRepository
+func (r *PostgresWebhookRepository) Delete(ctx context.Context, id domain.UUID) error {
+	return conn(ctx, r.DB).Transaction(func(tx *gorm.DB) error {
+		res := tx.Scopes(inWorkspace(ctx)).Where("id = ?", id.String()).Delete(&domain.Webhook{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return domain.ErrRecordNotFound
+		}
+		return tx.Scopes(inWorkspace(ctx)).Where("webhook_id = ?", id.String()).Delete(&domain.WebhookDelivery{}).Error
+	})
+}
+
+// Subscribed implements domain.WebhookRepository
+func (r *PostgresWebhookRepository) Subscribed(ctx context.Context, eventType domain.EventType) ([]*domain.Webhook, error) {
+	var active []*domain.Webhook
+	if err := conn(ctx, r.DB).Scopes(inWorkspace(ctx)).Where("active = ?", true).Order("id").Find(&active).Error; err != nil {
+		return nil, fmt.Errorf("failed to find webhooks, %w", err)
+	}
+	// the subscribed events are stored as JSON, so they are matched here rather than in SQL
+	webhooks := active[:0]
+	for _, webhook := range active {
+		if webhook.Wants(eventType) {
+			webhooks = append(webhooks, webhook)
+		}
+	}
+	return webhooks, nil
+}
+
+// PostgresWebhookDeliveryRepository implements the WebhookDeliveryRepository interface
+type PostgresWebhookDeliveryRepository struct {
+	DB *gorm.DB
+}
+
+// NewWebhookDeliveryRepository creates a new instance of the PostgresWebhookDeliveryRepository
+func NewWebhookDeliveryRepository(db *gorm.DB) domain.WebhookDeliveryRepository {
+	return &PostgresWebhookDeliveryRepository{DB: db}
+}
+
+// Enqueue implements domain.WebhookDeliveryRepository
+func (r *PostgresWebhookDeliveryRepository) Enqueue(ctx context.Context, deliveries []*domain.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	err := conn(ctx, r.DB).Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(deliveries, createBatchSize).Error
+	if err != nil {
+		return fmt.Errorf("failed to enqueue webhook deliveries, %w", err)
+	}
+	return nil
+}
+
+// Claim implements domain.WebhookDeliveryRepository. A delivery is claimed by moving its next
+// attempt past now while its attempts are unchanged, which only one worker can do.
+func (r *PostgresWebhookDeliveryRepository) Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*domain.WebhookDelivery, error) {
+	var due []*domain.WebhookDelivery
+	err := conn(ctx, r.DB).Where("status = ? AND next_attempt_at <= ?", domain.DeliveryPending, now).
+		Order("next_attempt_at").Order("id").Limit(limit).Find(&due).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to find due webhook deliveries, %w", err)
+	}
+	// the end of the lease identifies the claim, so it is kept at the precision of the database
+	until := now.Add(lease).Truncate(time.Microsecond)
+	claimed := due[:0]
+	for _, delivery := range due {
+		res := conn(ctx, r.DB).Model(&domain.WebhookDelivery{}).
+			Where("id = ? AND status = ? AND attempts = ? AND next_attempt_at <= ?", delivery.ID, domain.DeliveryPending, delivery.Attempts, now).
+			Update("next_attempt_at", until)
+		if res.Error != nil {
+			return nil, fmt.Errorf("failed to claim webhook delivery, %w", res.Error)
+		}
+		if res.RowsAffected == 1 {
+			delivery.NextAttemptAt = until
+			claimed = append(claimed, delivery)
+		}
+	}
+	return claimed, nil
+}
+
+// Save implements domain.WebhookDeliveryRepository
+func (r *PostgresWebhookDeliveryRepository) Save(ctx context.Context, delivery *domain.WebhookDelivery, claim domain.DeliveryClaim) error {
+	res := conn(ctx, r.DB).Model(&domain.WebhookDelivery{}).
+		Where("id = ? AND status = ? AND attempts = ? AND next_attempt_at = ?", delivery.ID, domain.DeliveryPending, claim.Attempts, claim.Until).
+		Updates(map[string]any{
+			"status":           delivery.Status,
+			"attempts":         delivery.Attempts,
+			"next_attempt_at":  delivery.NextAttemptAt,
+			"last_status_code": delivery.LastStatusCode,
+			"last_error":       delivery.LastError,
+			"delivered_at":     delivery.DeliveredAt,
+		})
+	if res.Error != nil {
+		return fmt.Errorf("failed to save webhook delivery, %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return domain.ErrClaimLost
+	}
+	return nil
+}
+
+// List implements domain.WebhookDeliveryRepository
+func (r *PostgresWebhookDeliveryRepository) List(ctx context.Context, webhookID domain.UUID, status domain.DeliveryStatus, page domain.Page) ([]*domain.WebhookDelivery, error) {
+	query := conn(ctx, r.DB).Scopes(inWorkspace(ctx)).Where("webhook_id = ?", webhookID.String())
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var deliveries []*domain.WebhookDelivery
+	if err := query.Order("id DESC").Limit(page.Limit).Offset(page.Offset).Find(&deliveries).Error; err != nil {
+		return nil, fmt.Errorf("failed to list webhook deliveries, %w", err)
+	}
+	return deliveries, nil
+}
+
+// Retry implements domain.WebhookDeliveryRepository. The attempts start over, so that the
+// delivery gets the full number of attempts again.
+func (r *PostgresWebhookDeliveryRepository) Retry(ctx context.Context, webhookID domain.UUID, id uint64, now time.Time) error {
+	res := conn(ctx, r.DB).Model(&domain.WebhookDelivery{}).Scopes(inWorkspace(ctx)).
+		Where("webhook_id = ? AND id = ? AND status = ?", webhookID.String(), id, domain.DeliveryDead).
+		Updates(map[string]any{"status": domain.DeliveryPending, "attempts": 0, "next_attempt_at": now})
+	if res.Error != nil {
+		return fmt.Errorf("failed to retry webhook delivery, %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return domain.ErrRecordNotFound
+	}
+	return nil
+}
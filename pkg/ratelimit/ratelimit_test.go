@@ -0,0 +1,41 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStoreTake(t *testing.T) {
+	now := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	store := newMemoryStore(func() time.Time { return now })
+	limit := Limit{Rate: 2, Burst: 3}
+
+	for i := 2; i >= 0; i-- {
+		res, err := store.Take(t.Context(), "client", limit)
+		assert.NoError(t, err)
+		assert.True(t, res.Allowed)
+		assert.Equal(t, i, res.Remaining)
+		assert.Equal(t, 3, res.Limit)
+	}
+
+	res, _ := store.Take(t.Context(), "client", limit)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 500*time.Millisecond, res.RetryAfter)
+	assert.Equal(t, 1500*time.Millisecond, res.Reset)
+
+	res, _ = store.Take(t.Context(), "other", limit)
+	assert.True(t, res.Allowed, "buckets are kept per key")
+
+	now = now.Add(500 * time.Millisecond)
+	res, _ = store.Take(t.Context(), "client", limit)
+	assert.True(t, res.Allowed, "a token is refilled after RetryAfter")
+	assert.Equal(t, 0, res.Remaining)
+
+	now = now.Add(time.Hour)
+	res, _ = store.Take(t.Context(), "client", limit)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 2, res.Remaining, "refill is capped at the burst size")
+	assert.Len(t, store.buckets, 1, "idle full buckets are swept")
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"log/slog"
+	"net"
+	"net/http"
+
+	"github.com/taheri24/helitask/pkg/config"
+	"github.com/taheri24/helitask/pkg/utils"
+	"go.uber.org/fx"
+)
+
+// NewAdminHandler serves the internal endpoints, which must not be reachable by API clients
+func NewAdminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("GET /debug/vars", expvar.Handler())
+	return mux
+}
+
+// StartAdminServer serves the internal endpoints on their own address, unless none is
+// configured. A bare port listens on localhost only.
+func StartAdminServer(lc fx.Lifecycle, cfg *config.Config) {
+	addr := cfg.Server.AdminAddr
+	if addr == "" {
+		return
+	}
+	if utils.IsNumber(addr) {
+		addr = "127.0.0.1:" + addr
+	}
+	srv := &http.Server{Addr: addr, Handler: NewAdminHandler()}
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			listener, err := net.Listen("tcp", srv.Addr)
+			if err != nil {
+				slog.Error("Failed to start admin server", slog.Any("err", err))
+				return err
+			}
+			go func() {
+				if err := srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					slog.Error("Admin server stopped", slog.Any("err", err))
+				}
+			}()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			return srv.Shutdown(ctx)
+		},
+	})
+}
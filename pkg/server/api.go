@@ -0,0 +1,14 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/taheri24/helitask/pkg/config"
+)
+
+// APIMiddlewares returns the middlewares every HTTP API runs before its handlers: they give
+// the request context its request id, principal and workspace, and choose the database of
+// its reads
+func APIMiddlewares(cfg *config.Config) gin.HandlersChain {
+	return gin.HandlersChain{RequestID, Authenticate(cfg.Auth.JWTSecret), NewWorkspaceResolver(cfg).Middleware,
+		NewReadYourWrites(cfg.DB.ReadYourWrites).Middleware}
+}
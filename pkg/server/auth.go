@@ -0,0 +1,56 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/taheri24/helitask/pkg/auth"
+	"github.com/taheri24/helitask/pkg/domain"
+)
+
+// claimsKey is the gin context key holding the verified bearer token claims
+const claimsKey = "auth.claims"
+
+// Authenticate verifies the bearer token of a request, when one is sent, and stores its claims.
+// The "sub" claim becomes the principal of the request context.
+// Browsers cannot set headers on WebSocket handshakes, so these may send the token in the
+// access_token query parameter instead.
+// Tokens are ignored altogether when no secret is configured.
+func Authenticate(secret string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := auth.BearerToken(c.GetHeader("Authorization"))
+		if token == "" && websocket.IsWebSocketUpgrade(c.Request) {
+			token = c.Query("access_token")
+		}
+		if token == "" || secret == "" {
+			c.Next()
+			return
+		}
+		claims, err := auth.ParseHS256(token, []byte(secret))
+		if err != nil {
+			abortWithError(c, http.StatusUnauthorized, "Invalid bearer token", err)
+			return
+		}
+		c.Set(claimsKey, claims)
+		principal := domain.Principal{UserID: claims.String("sub")}
+		c.Request = c.Request.WithContext(domain.WithPrincipal(c.Request.Context(), principal))
+		c.Next()
+	}
+}
+
+// Claims returns the verified claims of the request, or nil for anonymous requests
+func Claims(c *gin.Context) auth.Claims {
+	if v, ok := c.Get(claimsKey); ok {
+		return v.(auth.Claims)
+	}
+	return nil
+}
+
+// abortWithError ends the request with an error response in the shape of the API handlers
+func abortWithError(c *gin.Context, status int, message string, err error) {
+	if err != nil {
+		message = message + ": " + err.Error()
+	}
+	c.AbortWithStatusJSON(status, gin.H{"error": message})
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/taheri24/helitask/pkg/domain"
+)
+
+const (
+	// ReadPrimaryCookie and ReadPrimaryHeader carry the unix time until which a client reads from the primary
+	ReadPrimaryCookie = "read_primary_until"
+	ReadPrimaryHeader = "X-Read-Primary-Until"
+)
+
+// ReadYourWrites sends every write request to the primary database and pins its client
+// to the primary for window afterwards, so that the client never reads from a replica
+// that has not caught up with its own writes. The pin is handed out both as a cookie
+// and as a response header; clients without cookies echo the header on their reads.
+type ReadYourWrites struct {
+	window time.Duration
+	now    func() time.Time
+}
+
+// NewReadYourWrites creates the middleware; a zero window only keeps writes on the primary
+func NewReadYourWrites(window time.Duration) *ReadYourWrites {
+	return &ReadYourWrites{window: window, now: time.Now}
+}
+
+// Middleware chooses the database of the reads of the request
+func (m *ReadYourWrites) Middleware(c *gin.Context) {
+	now := m.now()
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		if !m.pinned(c, now) {
+			c.Next()
+			return
+		}
+	default:
+		if m.window > 0 {
+			until := strconv.FormatInt(now.Add(m.window).Unix(), 10)
+			c.SetSameSite(http.SameSiteLaxMode)
+			c.SetCookie(ReadPrimaryCookie, until, int(m.window.Seconds()+1), "/", "", false, true)
+			c.Header(ReadPrimaryHeader, until)
+		}
+	}
+	c.Request = c.Request.WithContext(domain.WithPrimary(c.Request.Context()))
+	c.Next()
+}
+
+// pinned reports whether the request carries a pin that has not expired. Pins reaching
+// further than one window into the future were not handed out here and are ignored.
+func (m *ReadYourWrites) pinned(c *gin.Context, now time.Time) bool {
+	if m.window <= 0 {
+		return false
+	}
+	value := c.GetHeader(ReadPrimaryHeader)
+	if value == "" {
+		value, _ = c.Cookie(ReadPrimaryCookie)
+	}
+	until, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return false
+	}
+	return until >= now.Unix() && until <= now.Add(m.window).Unix()+1
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/taheri24/helitask/pkg/domain"
+)
+
+// TestReadYourWrites tests that writes pin their client to the primary database for a while
+func TestReadYourWrites(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	m := NewReadYourWrites(5 * time.Second)
+	m.now = func() time.Time { return now }
+	app := gin.New()
+	app.Use(m.Middleware)
+	app.Any("/todo", func(c *gin.Context) {
+		c.String(http.StatusOK, strconv.FormatBool(domain.UsesPrimary(c.Request.Context())))
+	})
+
+	req, w := httptest.NewRequest("POST", "/todo", nil), httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	assert.Equal(t, "true", w.Body.String())
+	pin := w.Header().Get(ReadPrimaryHeader)
+	assert.Equal(t, strconv.FormatInt(now.Add(5*time.Second).Unix(), 10), pin)
+	cookies := w.Result().Cookies()
+	if assert.Len(t, cookies, 1) {
+		assert.Equal(t, ReadPrimaryCookie, cookies[0].Name)
+		assert.Equal(t, pin, cookies[0].Value)
+	}
+
+	type TestCase struct {
+		name    string
+		header  string
+		cookie  string
+		elapsed time.Duration
+		primary string
+	}
+	testCases := []TestCase{
+		{"Without pin", "", "", 0, "false"},
+		{"Pinned by header", pin, "", time.Second, "true"},
+		{"Pinned by cookie", "", pin, time.Second, "true"},
+		{"Expired pin", pin, "", 6 * time.Second, "false"},
+		{"Pin not handed out", strconv.FormatInt(now.Add(time.Hour).Unix(), 10), "", 0, "false"},
+		{"Malformed pin", "soon", "", 0, "false"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m.now = func() time.Time { return now.Add(tc.elapsed) }
+			req, w := httptest.NewRequest("GET", "/todo", nil), httptest.NewRecorder()
+			if tc.header != "" {
+				req.Header.Set(ReadPrimaryHeader, tc.header)
+			}
+			if tc.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: ReadPrimaryCookie, Value: tc.cookie})
+			}
+			app.ServeHTTP(w, req)
+			assert.Equal(t, tc.primary, w.Body.String())
+		})
+	}
+}
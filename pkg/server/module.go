@@ -0,0 +1,13 @@
+package server
+
+import (
+	"github.com/taheri24/helitask/pkg/ratelimit"
+	"go.uber.org/fx"
+)
+
+// Module provides the HTTP server. It must be listed before the modules registering routes,
+// as the engine wide middlewares only apply to routes registered after them.
+var Module = fx.Module("httpServer",
+	fx.Provide(ratelimit.NewMemoryStore),
+	fx.Invoke(StartServer, StartAdminServer),
+)
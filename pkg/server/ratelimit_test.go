@@ -0,0 +1,82 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/taheri24/helitask/pkg/config"
+	"github.com/taheri24/helitask/pkg/logger"
+	"github.com/taheri24/helitask/pkg/ratelimit"
+)
+
+func TestRateLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := config.Default()
+	cfg.RateLimit = config.RateLimitConfig{Enabled: true, ReadRate: 0.5, ReadBurst: 2, WriteRate: 0.5, WriteBurst: 1}
+	cfg.Auth.APIKeys = []string{"key-a", "key-b"}
+
+	app := gin.New()
+	app.Use(RateLimit(ratelimit.NewMemoryStore(), cfg, logger.Nop()))
+	app.GET("/todo", func(c *gin.Context) { c.Status(http.StatusOK) })
+	app.POST("/todo", func(c *gin.Context) { c.Status(http.StatusCreated) })
+
+	call := func(method, apiKey string) *httptest.ResponseRecorder {
+		req, w := httptest.NewRequest(method, "/todo", nil), httptest.NewRecorder()
+		if apiKey != "" {
+			req.Header.Set(APIKeyHeader, apiKey)
+		}
+		app.ServeHTTP(w, req)
+		return w
+	}
+
+	w := call("GET", "key-a")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "2", w.Header().Get("RateLimit-Reset"))
+
+	assert.Equal(t, http.StatusOK, call("GET", "key-a").Code)
+	w = call("GET", "key-a")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+
+	assert.Equal(t, http.StatusCreated, call("POST", "key-a").Code, "writes have their own bucket")
+	assert.Equal(t, http.StatusTooManyRequests, call("POST", "key-a").Code)
+	assert.Equal(t, http.StatusOK, call("GET", "key-b").Code, "buckets are kept per API key")
+	assert.Equal(t, http.StatusOK, call("GET", "").Code, "anonymous clients are keyed by IP")
+	assert.Equal(t, http.StatusOK, call("GET", "forged-1").Code)
+	assert.Equal(t, http.StatusTooManyRequests, call("GET", "forged-2").Code, "unknown keys share the bucket of their IP")
+}
+
+// TestRateLimitForwardedFor tests that forwarding headers only name the client of trusted proxies
+func TestRateLimitForwardedFor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := config.Default()
+	cfg.RateLimit = config.RateLimitConfig{Enabled: true, ReadRate: 0.5, ReadBurst: 1, WriteRate: 0.5, WriteBurst: 1}
+	newApp := func(proxies ...string) *gin.Engine {
+		app := gin.New()
+		assert.NoError(t, app.SetTrustedProxies(proxies))
+		app.Use(RateLimit(ratelimit.NewMemoryStore(), cfg, logger.Nop()))
+		app.GET("/todo", func(c *gin.Context) { c.Status(http.StatusOK) })
+		return app
+	}
+	call := func(app *gin.Engine, forwardedFor string) int {
+		req, w := httptest.NewRequest("GET", "/todo", nil), httptest.NewRecorder()
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		app.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	app := newApp(cfg.Server.TrustedProxies...)
+	assert.Equal(t, http.StatusOK, call(app, "203.0.113.1"))
+	assert.Equal(t, http.StatusTooManyRequests, call(app, "203.0.113.2"), "spoofed forwarding headers do not get a new bucket")
+
+	app = newApp("192.0.2.1")
+	assert.Equal(t, http.StatusOK, call(app, "203.0.113.1"))
+	assert.Equal(t, http.StatusOK, call(app, "203.0.113.2"), "trusted proxies name the client")
+	assert.Equal(t, http.StatusTooManyRequests, call(app, "203.0.113.1"))
+}
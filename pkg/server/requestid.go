@@ -0,0 +1,21 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/taheri24/helitask/pkg/domain"
+)
+
+// RequestIDHeader carries the id of a request, from the client or generated here
+const RequestIDHeader = "X-Request-ID"
+
+// RequestID stores the id of the request in its context and echoes it in the response.
+// Ids sent by clients are kept when they are well-formed, so that their logs and ours line up.
+func RequestID(c *gin.Context) {
+	id := c.GetHeader(RequestIDHeader)
+	if !domain.ValidRequestID(id) {
+		id = domain.NewUUID().String()
+	}
+	c.Header(RequestIDHeader, id)
+	c.Request = c.Request.WithContext(domain.WithRequestID(c.Request.Context(), id))
+	c.Next()
+}
@@ -2,28 +2,54 @@ package server
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
+	"net"
+	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/taheri24/helitask/pkg/config"
 	"github.com/taheri24/helitask/pkg/logger"
+	"github.com/taheri24/helitask/pkg/ratelimit"
 	"github.com/taheri24/helitask/pkg/utils"
 	"go.uber.org/fx"
 )
 
-func StartServer(lc fx.Lifecycle, defaultApp *gin.Engine, cfg *config.Config, logger logger.Logger) {
+// StartServer serves defaultApp once the application starts. Stopping the application stops
+// accepting connections and waits for the running requests to finish. Hijacked connections,
+// such as WebSockets, are not tracked by the server; their handlers close them on stop.
+func StartServer(lc fx.Lifecycle, defaultApp *gin.Engine, cfg *config.Config, logger logger.Logger, limiter ratelimit.Store) error {
+	// the client IP is taken from forwarding headers only when a trusted proxy sent them
+	if err := defaultApp.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		return fmt.Errorf("invalid TRUSTED_PROXIES, %w", err)
+	}
+	if cfg.RateLimit.Enabled {
+		defaultApp.Use(RateLimit(limiter, cfg, logger))
+	}
 
+	listenPort := cfg.Server.Port
+	if utils.IsNumber(listenPort) {
+		listenPort = ":" + listenPort
+	}
+	srv := &http.Server{Addr: listenPort, Handler: defaultApp.Handler()}
 	lc.Append(fx.Hook{
 		OnStart: func(ctx context.Context) error {
-			listenPort := cfg.Server.Port
-			if utils.IsNumber(listenPort) {
-				listenPort = ":" + listenPort
-			}
-			if err := defaultApp.Run(listenPort); err != nil {
+			listener, err := net.Listen("tcp", srv.Addr)
+			if err != nil {
 				slog.Error("Failed to start server", slog.Any("bindngErr", err))
 				return err
 			}
+			go func() {
+				if err := srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					slog.Error("Server stopped", slog.Any("err", err))
+				}
+			}()
 			return nil
 		},
+		OnStop: func(ctx context.Context) error {
+			return srv.Shutdown(ctx)
+		},
 	})
+	return nil
 }
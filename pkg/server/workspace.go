@@ -0,0 +1,48 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/netip"
+
+	"github.com/gin-gonic/gin"
+	"github.com/taheri24/helitask/pkg/config"
+	"github.com/taheri24/helitask/pkg/domain"
+	"github.com/taheri24/helitask/pkg/tenant"
+)
+
+// WorkspaceResolver determines the workspace of an incoming request with the tenant.Resolver
+// shared by all APIs
+type WorkspaceResolver struct {
+	resolver *tenant.Resolver
+}
+
+// NewWorkspaceResolver creates a WorkspaceResolver from the tenant and auth settings
+func NewWorkspaceResolver(cfg *config.Config) *WorkspaceResolver {
+	return &WorkspaceResolver{resolver: tenant.NewResolver(cfg)}
+}
+
+// Resolve returns the workspace named by the request
+func (r *WorkspaceResolver) Resolve(c *gin.Context) (domain.Workspace, error) {
+	// the address of the connection, not the one named by forwarding headers
+	peer, _ := netip.ParseAddr(c.RemoteIP())
+	return r.resolver.Resolve(tenant.Request{Claims: Claims(c), Header: c.GetHeader, Host: c.Request.Host, Peer: peer})
+}
+
+// Middleware scopes the request context to the resolved workspace
+func (r *WorkspaceResolver) Middleware(c *gin.Context) {
+	ws, err := r.Resolve(c)
+	if err != nil {
+		status := http.StatusBadRequest
+		switch {
+		case errors.Is(err, domain.ErrUnauthenticated):
+			status = http.StatusUnauthorized
+		case errors.Is(err, tenant.ErrWorkspaceMismatch) || errors.Is(err, tenant.ErrNoWorkspaceClaim):
+			status = http.StatusForbidden
+		}
+		abortWithError(c, status, "Unable to resolve workspace", err)
+		return
+	}
+	c.Request = c.Request.WithContext(domain.WithWorkspace(c.Request.Context(), ws))
+	c.Next()
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/taheri24/helitask/pkg/domain"
+)
+
+// Operations of a batch
+const (
+	BatchCreate = "create"
+	BatchUpdate = "update"
+	BatchDelete = "delete"
+)
+
+// ErrBatchAborted is returned when an atomic batch was rolled back after one of its operations failed
+var ErrBatchAborted = errors.New("batch aborted")
+
+// BatchOp is one operation of a batch
+type BatchOp struct {
+	Op string
+	// ID is the item to update or delete, or the id of the item to create when set
+	ID   *domain.UUID
+	Item *TodoInput
+}
+
+// BatchResult is the outcome of one operation of a batch
+type BatchResult struct {
+	// ID is the item the operation wrote, once it is known
+	ID  *domain.UUID
+	Err error
+}
+
+// batchRun executes the operations of one batch
+type batchRun struct {
+	*TodoService
+	atomic  bool
+	results []BatchResult
+	// pending holds the indexes of consecutive creates waiting for a multi-row insert
+	pending []int
+	todos   map[int]*domain.TodoItem
+}
+
+// Batch creates, updates and deletes several items, returning the outcome of every operation.
+// An atomic batch applies all operations in one transaction, or none of them and fails with
+// ErrBatchAborted; otherwise every operation succeeds or fails on its own.
+func (s *TodoService) Batch(ctx context.Context, ops []BatchOp, atomic bool) ([]BatchResult, error) {
+	run := &batchRun{TodoService: s, atomic: atomic, results: make([]BatchResult, len(ops)), todos: map[int]*domain.TodoItem{}}
+	if !atomic {
+		return run.results, run.execute(ctx, ops)
+	}
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		return run.execute(ctx, ops)
+	})
+	return run.results, err
+}
+
+func (run *batchRun) execute(ctx context.Context, ops []BatchOp) error {
+	for i, op := range ops {
+		if op.Op != BatchCreate {
+			if err := run.flushCreates(ctx); err != nil {
+				return err
+			}
+		}
+		if err := run.apply(ctx, i, op); err != nil {
+			return err
+		}
+		if run.atomic && run.results[i].Err != nil {
+			return ErrBatchAborted
+		}
+	}
+	return run.flushCreates(ctx)
+}
+
+// apply runs a single operation, recording its outcome in the results. Only the failures
+// of reads end the whole batch.
+func (run *batchRun) apply(ctx context.Context, i int, op BatchOp) error {
+	res := &run.results[i]
+	switch op.Op {
+	case BatchCreate:
+		if op.Item == nil {
+			res.Err = invalid("item is required")
+			return nil
+		}
+		if res.Err = op.Item.Validate(); res.Err != nil {
+			return nil
+		}
+		todo := &domain.TodoItem{ID: run.newID(), OwnerID: domain.PrincipalFromContext(ctx).UserID}
+		op.Item.ApplyTo(todo)
+		if op.ID != nil {
+			if *op.ID == (domain.UUID{}) {
+				res.Err = invalid("id must not be the nil UUID")
+				return nil
+			}
+			todo.ID = *op.ID
+		}
+		res.ID = &todo.ID
+		if res.Err = run.policy.Authorize(ctx, domain.ActionCreateTodo, domain.TodoResource(todo)); res.Err != nil {
+			return nil
+		}
+		run.stamp(nil, todo)
+		run.todos[i] = todo
+		run.pending = append(run.pending, i)
+
+	case BatchUpdate, BatchDelete:
+		if op.ID == nil {
+			res.Err = invalid("id is required")
+			return nil
+		}
+		res.ID = op.ID
+		action := domain.ActionUpdateTodo
+		if op.Op == BatchDelete {
+			action = domain.ActionDeleteTodo
+		}
+		existing, err := run.find(domain.WithPrimary(ctx), *op.ID, action)
+		if err != nil && !errors.Is(err, domain.ErrRecordNotFound) && !refused(err) {
+			return err
+		}
+		if res.Err = err; err != nil {
+			return nil
+		}
+		if op.Op == BatchDelete {
+			res.Err = run.repository.Delete(ctx, existing.ID)
+			return nil
+		}
+		if op.Item == nil {
+			res.Err = invalid("item is required")
+			return nil
+		}
+		if res.Err = op.Item.Validate(); res.Err != nil {
+			return nil
+		}
+		_, res.Err = run.change(ctx, existing, *op.Item)
+
+	default:
+		res.Err = invalid("op must be create, update or delete")
+	}
+	return nil
+}
+
+// flushCreates stores the pending creates with one multi-row insert. When that fails outside
+// an atomic batch, the items are created one by one to find out which of them failed.
+func (run *batchRun) flushCreates(ctx context.Context) error {
+	if len(run.pending) == 0 {
+		return nil
+	}
+	pending := run.pending
+	run.pending = nil
+
+	todos := make([]*domain.TodoItem, len(pending))
+	for j, i := range pending {
+		todos[j] = run.todos[i]
+	}
+	err := run.repository.CreateMany(ctx, todos)
+	if err == nil || run.atomic {
+		for _, i := range pending {
+			run.results[i].Err = err
+		}
+		if err != nil {
+			return ErrBatchAborted
+		}
+		return nil
+	}
+	for _, i := range pending {
+		run.results[i].Err = run.repository.Create(ctx, run.todos[i])
+	}
+	return nil
+}
+
+// refused reports whether err is a refusal of the policy
+func refused(err error) bool {
+	return errors.Is(err, domain.ErrForbidden) || errors.Is(err, domain.ErrUnauthenticated)
+}
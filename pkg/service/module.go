@@ -0,0 +1,6 @@
+package service
+
+import "go.uber.org/fx"
+
+// Module provides the use cases shared by the adapters of the API
+var Module = fx.Module("service", fx.Provide(NewTodoService))
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/taheri24/helitask/pkg/domain"
+)
+
+const (
+	// MaxClockSkew is how far the field clocks of pushed changes may be ahead of the server
+	MaxClockSkew = time.Minute
+	// maxMergeAttempts bounds the merges of a change that keep losing to concurrent writes
+	maxMergeAttempts = 3
+)
+
+// SyncPage is a page of the changes of a workspace. Changes the caller may not read are left
+// out, so it may hold fewer versions than the page asked for.
+type SyncPage struct {
+	// Versions are the current versions of the changed items
+	Versions []*domain.TodoVersion
+	// Cursor is the position right after the page
+	Cursor domain.SyncCursor
+	// More reports whether more changes are waiting
+	More bool
+}
+
+// Changes returns the current versions of the items changed after cursor the caller may read,
+// limit changes at a time in the order of their last change. A cursor from before changes that
+// are no longer kept fails with domain.ErrResyncRequired.
+func (s *TodoService) Changes(ctx context.Context, after domain.SyncCursor, limit int) (*SyncPage, error) {
+	versions, err := s.sync.Changes(ctx, after, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	page := &SyncPage{Versions: []*domain.TodoVersion{}, Cursor: after, More: len(versions) > limit}
+	if page.More {
+		versions = versions[:limit]
+	}
+	for _, version := range versions {
+		page.Cursor = domain.SyncCursor{Sequence: version.Sequence, TodoID: version.TodoID}
+		err := s.policy.Authorize(ctx, domain.ActionReadTodo, domain.Resource{ListID: version.ListID, OwnerID: version.OwnerID})
+		if refused(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		page.Versions = append(page.Versions, version)
+	}
+	return page, nil
+}
+
+// SyncChange is a change a client made while offline. A base version of 0 creates the item;
+// any other change only applies while the item is still at the base version. A change with
+// field clocks merges instead: each field listed in them is set when its clock is later than
+// the one of the stored field.
+type SyncChange struct {
+	ID          *domain.UUID
+	BaseVersion int64
+	Deleted     bool
+	Item        *TodoInput
+	Clocks      domain.FieldClocks
+}
+
+// validate checks a change before it is applied
+func (ch *SyncChange) validate() error {
+	if ch.ID == nil {
+		return invalid("id is required")
+	}
+	if ch.BaseVersion < 0 {
+		return invalid("base_version must not be negative")
+	}
+	if !ch.Deleted {
+		if ch.Item == nil {
+			return invalid("item is required")
+		}
+		if err := ch.Item.Validate(); err != nil {
+			return err
+		}
+	}
+	for field, clock := range ch.Clocks {
+		if !slices.Contains(domain.RegisterFields, field) {
+			return invalid("clocks of unknown field " + field)
+		}
+		if clock.Time().After(time.Now().Add(MaxClockSkew)) {
+			return invalid("clock of " + field + " is ahead of the server")
+		}
+	}
+	return nil
+}
+
+// ApplyChange applies a change pushed by a client, returning the item after it and whether
+// it was created. A change conflicting with the stored item fails with domain.ErrVersionConflict
+// or domain.ErrConflict, and returns the current state of the item.
+func (s *TodoService) ApplyChange(ctx context.Context, change SyncChange) (*domain.TodoItem, bool, error) {
+	if err := change.validate(); err != nil {
+		return nil, false, err
+	}
+	ctx = domain.WithPrimary(ctx)
+	existing, err := s.repository.GetByID(domain.WithDeleted(ctx), *change.ID)
+	if errors.Is(err, domain.ErrRecordNotFound) {
+		existing = nil
+	} else if err != nil {
+		return nil, false, err
+	}
+
+	switch {
+	case existing == nil && (change.Deleted || change.BaseVersion > 0):
+		return nil, false, domain.ErrRecordNotFound
+	case existing == nil:
+		todo := &domain.TodoItem{ID: *change.ID, OwnerID: domain.PrincipalFromContext(ctx).UserID}
+		change.Item.ApplyTo(todo)
+		err := s.create(ctx, todo)
+		todo, err = s.current(ctx, todo, err)
+		return todo, err == nil, err
+	}
+	if err := s.policy.Authorize(ctx, domain.ActionReadTodo, domain.TodoResource(existing)); err != nil {
+		return nil, false, err
+	}
+	var todo *domain.TodoItem
+	switch {
+	case change.Deleted && existing.DeletedAt.Valid:
+		// deleting an item that is already deleted changes nothing
+		todo, err = s.current(ctx, existing, nil)
+	case len(change.Clocks) > 0 && !change.Deleted && !existing.DeletedAt.Valid:
+		todo, err = s.merge(ctx, existing, change)
+	case change.BaseVersion != existing.Version:
+		todo, err = s.current(ctx, existing, domain.ErrVersionConflict)
+	case change.Deleted:
+		if err = s.policy.Authorize(ctx, domain.ActionDeleteTodo, domain.TodoResource(existing)); err == nil {
+			err = s.repository.Delete(domain.WithExpectedVersion(ctx, change.BaseVersion), existing.ID)
+			todo, err = s.current(ctx, existing, err)
+		}
+	default:
+		if err = s.policy.Authorize(ctx, domain.ActionUpdateTodo, domain.TodoResource(existing)); err == nil {
+			_, err = s.change(domain.WithExpectedVersion(ctx, change.BaseVersion), existing, *change.Item)
+			todo, err = s.current(ctx, existing, err)
+		}
+	}
+	return todo, false, err
+}
+
+// merge applies the fields of change written after the stored ones. When another write gets
+// in between, the change is merged again with the result of that write.
+func (s *TodoService) merge(ctx context.Context, existing *domain.TodoItem, change SyncChange) (*domain.TodoItem, error) {
+	values := &domain.TodoItem{}
+	change.Item.ApplyTo(values)
+	for attempt := 1; ; attempt++ {
+		merged := *existing
+		if !merged.Merge(values, change.Clocks) {
+			// every field was written later already
+			return s.current(ctx, existing, nil)
+		}
+		if err := s.policy.Authorize(ctx, domain.ActionUpdateTodo, domain.TodoResource(existing)); err != nil {
+			return nil, err
+		}
+		if !domain.SameList(existing.ListID, merged.ListID) {
+			if err := s.policy.Authorize(ctx, domain.ActionCreateTodo, domain.TodoResource(&merged)); err != nil {
+				return nil, err
+			}
+		}
+		s.stamp(existing, &merged)
+		err := s.repository.Update(domain.WithExpectedVersion(ctx, existing.Version), &merged)
+		if !errors.Is(err, domain.ErrVersionConflict) || attempt == maxMergeAttempts {
+			return s.current(ctx, &merged, err)
+		}
+		existing, err = s.repository.GetByID(ctx, existing.ID)
+		if errors.Is(err, domain.ErrRecordNotFound) {
+			return s.current(ctx, &merged, domain.ErrVersionConflict)
+		} else if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// current reads the stored state of todo after a write of it ending with err. A write that
+// conflicts with another one returns the stored state along with the error.
+func (s *TodoService) current(ctx context.Context, todo *domain.TodoItem, err error) (*domain.TodoItem, error) {
+	conflict := errors.Is(err, domain.ErrVersionConflict) || errors.Is(err, domain.ErrConflict)
+	if err != nil && !conflict {
+		return nil, err
+	}
+	stored, readErr := s.repository.GetByID(domain.WithDeleted(ctx), todo.ID)
+	if readErr == nil {
+		todo = stored
+	} else if conflict {
+		todo = nil
+	}
+	return todo, err
+}
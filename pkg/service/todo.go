@@ -0,0 +1,397 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/taheri24/helitask/pkg/domain"
+	"gorm.io/gorm"
+)
+
+const (
+	// MaxDescriptionLength is the maximum allowed length for todo item descriptions
+	MaxDescriptionLength = 1000
+	// MinDescriptionLength is the minimum required length for todo item descriptions
+	MinDescriptionLength = 1
+)
+
+var (
+	// ErrInvalidInput is matched by every ValidationError
+	ErrInvalidInput = errors.New("invalid input")
+	// ErrVersionNotFound is returned when reverting an item to a version it never had
+	ErrVersionNotFound = errors.New("version not found")
+)
+
+// ValidationError tells a client why the input of a use case was rejected
+type ValidationError struct {
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+// Is makes a ValidationError match ErrInvalidInput
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrInvalidInput
+}
+
+func invalid(message string) error {
+	return &ValidationError{message}
+}
+
+// TodoInput holds the fields of a todo item a client sets
+type TodoInput struct {
+	ListID      *domain.UUID
+	Description string
+	DueDate     time.Time
+	Completed   bool
+}
+
+// Validate checks the fields shared by every representation of a todo item
+func (in TodoInput) Validate() error {
+	if len(in.Description) < MinDescriptionLength {
+		return invalid("description is required")
+	}
+	if len(in.Description) > MaxDescriptionLength {
+		return invalid("description exceeds maximum length")
+	}
+	if in.DueDate.IsZero() {
+		return invalid("due_date is required")
+	}
+	return nil
+}
+
+// ApplyTo copies the fields onto todo
+func (in TodoInput) ApplyTo(todo *domain.TodoItem) {
+	todo.ListID, todo.Description, todo.DueDate, todo.Completed = in.ListID, in.Description, in.DueDate, in.Completed
+}
+
+// TodoPage is a page of a listing of todo items. Items the caller may not read are left out,
+// so it may hold fewer items than the page asked for.
+type TodoPage struct {
+	Items []*domain.TodoItem
+	// Positions holds the offset of each of Items in the listing
+	Positions []int
+	// Next is the offset of the listing right after the page
+	Next int
+	// More reports whether the listing goes on after the page
+	More bool
+}
+
+// TodoService holds the use cases of todo items shared by the REST, JSON-RPC, gRPC and GraphQL
+// adapters. It validates input, generates the ids of new items, stamps the field clocks of every
+// write, and asks the policy before every read and write. The events of the writes are recorded
+// by the repository, in the same transaction as the writes themselves.
+type TodoService struct {
+	repository domain.TodoRepository
+	audit      domain.AuditRepository
+	sync       domain.SyncRepository
+	policy     domain.Policy
+	tx         domain.TxManager
+	// clock stamps the fields the writes change; no other clock stamps them
+	clock *domain.HLCClock
+	// newID generates the ids of the items created without one
+	newID func() domain.UUID
+}
+
+// NewTodoService creates the TodoService over repository and the audit trail and changes of its
+// items, authorizing with policy and running the use cases made of several writes in transactions of tx
+func NewTodoService(repository domain.TodoRepository, audit domain.AuditRepository, sync domain.SyncRepository, policy domain.Policy, tx domain.TxManager) *TodoService {
+	node := domain.NewUUID().String()[:8]
+	return &TodoService{repository: repository, audit: audit, sync: sync, policy: policy, tx: tx, clock: domain.NewHLCClock(node), newID: domain.NewUUID}
+}
+
+// Create stores a new item owned by the caller. Offline clients generate the ids of their
+// items, so id is used when it is set.
+func (s *TodoService) Create(ctx context.Context, id *domain.UUID, in TodoInput) (*domain.TodoItem, error) {
+	if err := in.Validate(); err != nil {
+		return nil, err
+	}
+	todo := &domain.TodoItem{ID: s.newID(), OwnerID: domain.PrincipalFromContext(ctx).UserID}
+	if id != nil {
+		if *id == (domain.UUID{}) {
+			return nil, invalid("id must not be the nil UUID")
+		}
+		todo.ID = *id
+	}
+	in.ApplyTo(todo)
+	if err := s.create(ctx, todo); err != nil {
+		return nil, err
+	}
+	return todo, nil
+}
+
+func (s *TodoService) create(ctx context.Context, todo *domain.TodoItem) error {
+	if err := s.policy.Authorize(ctx, domain.ActionCreateTodo, domain.TodoResource(todo)); err != nil {
+		return err
+	}
+	s.stamp(nil, todo)
+	return s.repository.Create(ctx, todo)
+}
+
+// stamp stamps the fields of after that changed from before without a later clock, once the
+// clock has moved past the clocks of both
+func (s *TodoService) stamp(before, after *domain.TodoItem) {
+	if before != nil {
+		s.observe(before)
+	}
+	s.observe(after)
+	after.Stamp(before, s.clock.Now)
+}
+
+// observe moves the clock past the field clocks of todo, which may come from other nodes
+func (s *TodoService) observe(todo *domain.TodoItem) {
+	for _, clock := range todo.Clocks {
+		s.clock.Observe(clock)
+	}
+}
+
+// Get returns the item with id once the caller may read it. Items in the trash are only found
+// with a context of domain.WithDeleted.
+func (s *TodoService) Get(ctx context.Context, id domain.UUID) (*domain.TodoItem, error) {
+	return s.find(ctx, id, domain.ActionReadTodo)
+}
+
+// GetAsOf returns the item with id as it was at a time, once the caller may read it
+func (s *TodoService) GetAsOf(ctx context.Context, id domain.UUID, at time.Time) (*domain.TodoItem, error) {
+	todo, err := s.repository.GetAsOf(ctx, id, at)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.policy.Authorize(ctx, domain.ActionReadTodo, domain.TodoResource(todo)); err != nil {
+		return nil, err
+	}
+	return todo, nil
+}
+
+// find returns the item with id once the caller may perform action on it
+func (s *TodoService) find(ctx context.Context, id domain.UUID, action domain.Action) (*domain.TodoItem, error) {
+	todo, err := s.repository.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.policy.Authorize(ctx, action, domain.TodoResource(todo)); err != nil {
+		return nil, err
+	}
+	return todo, nil
+}
+
+// List returns a page of the items matching filter the caller may read, by due date
+func (s *TodoService) List(ctx context.Context, filter domain.TodoFilter, page domain.Page) (*TodoPage, error) {
+	return s.readable(ctx, page, func(page domain.Page) ([]*domain.TodoItem, error) {
+		return s.repository.List(ctx, filter, page)
+	})
+}
+
+// ListDeleted returns a page of the items in the trash the caller may read, most recently
+// deleted first
+func (s *TodoService) ListDeleted(ctx context.Context, page domain.Page) (*TodoPage, error) {
+	return s.readable(ctx, page, func(page domain.Page) ([]*domain.TodoItem, error) {
+		return s.repository.ListDeleted(ctx, page)
+	})
+}
+
+// readable lists a page with list, leaving out the items the caller may not read
+func (s *TodoService) readable(ctx context.Context, page domain.Page, list func(domain.Page) ([]*domain.TodoItem, error)) (*TodoPage, error) {
+	// one more item than asked tells whether the listing goes on
+	todos, err := list(domain.Page{Limit: page.Limit + 1, Offset: page.Offset})
+	if err != nil {
+		return nil, err
+	}
+	result := &TodoPage{Items: []*domain.TodoItem{}, More: len(todos) > page.Limit}
+	todos = todos[:min(len(todos), page.Limit)]
+	result.Next = page.Offset + len(todos)
+	for i, todo := range todos {
+		err := s.policy.Authorize(ctx, domain.ActionReadTodo, domain.TodoResource(todo))
+		if refused(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		result.Items = append(result.Items, todo)
+		result.Positions = append(result.Positions, page.Offset+i)
+	}
+	return result, nil
+}
+
+// Put creates the item with id, or replaces the fields of the existing one, reporting whether
+// it was created. An item in the trash is restored and replaced.
+func (s *TodoService) Put(ctx context.Context, id domain.UUID, in TodoInput) (*domain.TodoItem, bool, error) {
+	if id == (domain.UUID{}) {
+		return nil, false, invalid("id must not be the nil UUID")
+	}
+	if err := in.Validate(); err != nil {
+		return nil, false, err
+	}
+	todo, created, err := s.put(ctx, id, in)
+	if errors.Is(err, domain.ErrConflict) {
+		// a concurrent put created the item first, so this one replaces it
+		todo, err = s.replace(ctx, id, in)
+		created = false
+	}
+	return todo, created, err
+}
+
+func (s *TodoService) put(ctx context.Context, id domain.UUID, in TodoInput) (*domain.TodoItem, bool, error) {
+	todo, err := s.replace(ctx, id, in)
+	if !errors.Is(err, domain.ErrRecordNotFound) {
+		return todo, false, err
+	}
+	trashed, err := s.repository.GetByID(domain.WithDeleted(domain.WithPrimary(ctx)), id)
+	if err == nil && trashed.DeletedAt.Valid {
+		err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+			if _, err := s.Restore(ctx, id); err != nil {
+				return err
+			}
+			todo, err = s.replace(ctx, id, in)
+			return err
+		})
+		return todo, false, err
+	} else if err != nil && !errors.Is(err, domain.ErrRecordNotFound) {
+		return nil, false, err
+	}
+	todo = &domain.TodoItem{ID: id, OwnerID: domain.PrincipalFromContext(ctx).UserID}
+	in.ApplyTo(todo)
+	if err := s.create(ctx, todo); err != nil {
+		return nil, false, err
+	}
+	return todo, true, nil
+}
+
+// Update replaces the fields of an existing item. With a context of domain.WithExpectedVersion
+// it only applies while the item is still at that version.
+func (s *TodoService) Update(ctx context.Context, id domain.UUID, in TodoInput) (*domain.TodoItem, error) {
+	if err := in.Validate(); err != nil {
+		return nil, err
+	}
+	return s.replace(ctx, id, in)
+}
+
+func (s *TodoService) replace(ctx context.Context, id domain.UUID, in TodoInput) (*domain.TodoItem, error) {
+	existing, err := s.find(domain.WithPrimary(ctx), id, domain.ActionUpdateTodo)
+	if err != nil {
+		return nil, err
+	}
+	return s.change(ctx, existing, in)
+}
+
+// change writes the fields of in over existing, which the caller may update
+func (s *TodoService) change(ctx context.Context, existing *domain.TodoItem, in TodoInput) (*domain.TodoItem, error) {
+	changed := *existing
+	in.ApplyTo(&changed)
+	if !domain.SameList(existing.ListID, changed.ListID) {
+		// moving an item into a list takes the permission to create items there
+		if err := s.policy.Authorize(ctx, domain.ActionCreateTodo, domain.TodoResource(&changed)); err != nil {
+			return nil, err
+		}
+	}
+	s.stamp(existing, &changed)
+	if err := s.repository.Update(ctx, &changed); err != nil {
+		return nil, err
+	}
+	return &changed, nil
+}
+
+// Patch changes some of the fields of an existing item: edit gets the current fields and sets
+// the ones to change. The result is validated like a created item. With a context of
+// domain.WithExpectedVersion it only applies while the item is still at that version.
+func (s *TodoService) Patch(ctx context.Context, id domain.UUID, edit func(in *TodoInput) error) (*domain.TodoItem, error) {
+	existing, err := s.find(domain.WithPrimary(ctx), id, domain.ActionUpdateTodo)
+	if err != nil {
+		return nil, err
+	}
+	in := TodoInput{ListID: existing.ListID, Description: existing.Description, DueDate: existing.DueDate, Completed: existing.Completed}
+	if err := edit(&in); err != nil {
+		return nil, err
+	}
+	if err := in.Validate(); err != nil {
+		return nil, err
+	}
+	return s.change(ctx, existing, in)
+}
+
+// Revert writes the fields of an earlier version over the item with id, recording the result
+// as a new version. It fails with ErrVersionNotFound when the item has no such version, and
+// with domain.ErrDeletedVersion when the item was deleted in that version.
+func (s *TodoService) Revert(ctx context.Context, id domain.UUID, version int64) (*domain.TodoItem, error) {
+	existing, err := s.find(domain.WithPrimary(ctx), id, domain.ActionUpdateTodo)
+	if err != nil {
+		return nil, err
+	}
+	// the earlier version may be in another list, which is only known once it is read
+	var reverted *domain.TodoItem
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		s.observe(existing)
+		if reverted, err = s.repository.Revert(ctx, id, version, s.clock.Now()); err != nil {
+			return err
+		}
+		if !domain.SameList(existing.ListID, reverted.ListID) {
+			return s.policy.Authorize(ctx, domain.ActionCreateTodo, domain.TodoResource(reverted))
+		}
+		return nil
+	})
+	if errors.Is(err, domain.ErrRecordNotFound) {
+		return nil, ErrVersionNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return reverted, nil
+}
+
+// HistoryPage is a page of the audit trail of a todo item
+type HistoryPage struct {
+	Entries []*domain.AuditEntry
+	// Next is the offset of the trail right after the page
+	Next int
+	// More reports whether the trail goes on after the page
+	More bool
+}
+
+// History returns a page of the changes of the item with id, most recent first, once the
+// caller may read it. The history of items in the trash can be read as well.
+func (s *TodoService) History(ctx context.Context, id domain.UUID, page domain.Page) (*HistoryPage, error) {
+	if _, err := s.find(domain.WithDeleted(ctx), id, domain.ActionReadTodo); err != nil {
+		return nil, err
+	}
+	// one more entry than asked tells whether the history goes on
+	entries, err := s.audit.History(ctx, id, domain.Page{Limit: page.Limit + 1, Offset: page.Offset})
+	if err != nil {
+		return nil, err
+	}
+	result := &HistoryPage{More: len(entries) > page.Limit}
+	result.Entries = entries[:min(len(entries), page.Limit)]
+	result.Next = page.Offset + len(result.Entries)
+	return result, nil
+}
+
+// Delete moves the item with id to the trash
+func (s *TodoService) Delete(ctx context.Context, id domain.UUID) error {
+	if _, err := s.find(domain.WithPrimary(ctx), id, domain.ActionDeleteTodo); err != nil {
+		return err
+	}
+	return s.repository.Delete(ctx, id)
+}
+
+// Restore takes the item with id out of the trash, failing with domain.ErrRecordNotFound when
+// it is not in the trash
+func (s *TodoService) Restore(ctx context.Context, id domain.UUID) (*domain.TodoItem, error) {
+	todo, err := s.repository.GetByID(domain.WithDeleted(domain.WithPrimary(ctx)), id)
+	if err == nil && !todo.DeletedAt.Valid {
+		err = domain.ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	// restoring undoes a delete, so it takes the same permission
+	if err := s.policy.Authorize(ctx, domain.ActionDeleteTodo, domain.TodoResource(todo)); err != nil {
+		return nil, err
+	}
+	if err := s.repository.Restore(ctx, id); err != nil {
+		return nil, err
+	}
+	todo.DeletedAt, todo.Version = gorm.DeletedAt{}, todo.Version+1
+	return todo, nil
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taheri24/helitask/pkg/config"
+	"github.com/taheri24/helitask/pkg/domain"
+	"github.com/taheri24/helitask/pkg/logger"
+	"github.com/taheri24/helitask/pkg/policy"
+	"github.com/taheri24/helitask/pkg/ports/storage"
+	"github.com/taheri24/helitask/pkg/ports/storage/sqlite"
+	"go.uber.org/fx/fxtest"
+)
+
+func TestTodoService(t *testing.T) {
+	db := sqlite.NewDb(t, "")
+	cfg := config.Default()
+	reads, err := storage.NewReadRouter(fxtest.NewLifecycle(t), db, cfg, logger.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := NewTodoService(storage.NewTodoRepository(db, reads, logger.Nop(), cfg), storage.NewAuditRepository(db, reads), storage.NewSyncRepository(db, reads), policy.NewEngine(storage.NewMembershipRepository(db)), storage.NewTxManager(db))
+	generated := domain.NewUUID()
+	svc.newID = func() domain.UUID { return generated }
+	alice := domain.WithPrincipal(t.Context(), domain.Principal{UserID: "alice"})
+	bob := domain.WithPrincipal(t.Context(), domain.Principal{UserID: "bob"})
+	in := TodoInput{Description: "Water the plants", DueDate: time.Now().Add(time.Hour)}
+
+	_, err = svc.Create(alice, nil, TodoInput{DueDate: in.DueDate})
+	assert.ErrorIs(t, err, ErrInvalidInput)
+	assert.EqualError(t, err, "description is required")
+	_, err = svc.Create(alice, nil, TodoInput{Description: in.Description})
+	assert.EqualError(t, err, "due_date is required")
+	_, err = svc.Create(alice, &domain.UUID{}, in)
+	assert.ErrorIs(t, err, ErrInvalidInput, "the nil UUID is no id")
+
+	todo, err := svc.Create(alice, nil, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, generated, todo.ID)
+	assert.Equal(t, "alice", todo.OwnerID)
+	assert.Len(t, todo.Clocks, len(domain.RegisterFields), "every field of a new item is stamped")
+
+	_, err = svc.Get(bob, todo.ID)
+	assert.ErrorIs(t, err, domain.ErrForbidden)
+	_, err = svc.Update(bob, todo.ID, in)
+	assert.ErrorIs(t, err, domain.ErrForbidden)
+
+	in.Completed = true
+	updated, err := svc.Update(alice, todo.ID, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(2), updated.Version)
+	assert.True(t, updated.Clocks[domain.FieldCompleted].After(todo.Clocks[domain.FieldCompleted]))
+	assert.Equal(t, todo.Clocks[domain.FieldDescription], updated.Clocks[domain.FieldDescription], "unchanged fields keep their clock")
+	_, err = svc.Update(domain.WithExpectedVersion(alice, 1), todo.ID, in)
+	assert.ErrorIs(t, err, domain.ErrVersionConflict)
+
+	id := domain.NewUUID()
+	in.DueDate = in.DueDate.Add(time.Hour)
+	_, created, err := svc.Put(bob, id, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, created)
+	_, created, err = svc.Put(bob, id, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, created)
+
+	page, err := svc.List(bob, domain.TodoFilter{}, domain.Page{Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, page.Items, "items the caller may not read are left out")
+	assert.True(t, page.More)
+	page, err = svc.List(bob, domain.TodoFilter{}, domain.Page{Limit: 1, Offset: page.Next})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, page.Items, 1) {
+		assert.Equal(t, id, page.Items[0].ID)
+		assert.Equal(t, []int{1}, page.Positions)
+	}
+	assert.False(t, page.More)
+
+	assert.ErrorIs(t, svc.Delete(bob, todo.ID), domain.ErrForbidden)
+	assert.NoError(t, svc.Delete(alice, todo.ID))
+	deleted, err := svc.ListDeleted(alice, domain.Page{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, deleted.Items, 1)
+	_, err = svc.Restore(bob, todo.ID)
+	assert.ErrorIs(t, err, domain.ErrForbidden)
+	restored, err := svc.Restore(alice, todo.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, restored.DeletedAt.Valid)
+	_, err = svc.Restore(alice, todo.ID)
+	assert.ErrorIs(t, err, domain.ErrRecordNotFound, "only items in the trash can be restored")
+
+	assert.NoError(t, svc.Delete(bob, id))
+	_, _, err = svc.Put(alice, id, in)
+	assert.ErrorIs(t, err, domain.ErrForbidden, "putting an item in the trash restores it")
+	in.Description = "Water the plants twice"
+	put, created, err := svc.Put(bob, id, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, created, "an item in the trash is restored and replaced")
+	assert.False(t, put.DeletedAt.Valid)
+	assert.Equal(t, in.Description, put.Description)
+	stored, err := svc.Get(bob, id)
+	if assert.NoError(t, err) {
+		assert.Equal(t, in.Description, stored.Description)
+	}
+}
+
+// racingRepository lets another put create the item right before the create of the service
+type racingRepository struct {
+	domain.TodoRepository
+	race func()
+}
+
+func (r *racingRepository) Create(ctx context.Context, todo *domain.TodoItem) error {
+	if r.race != nil {
+		race := r.race
+		r.race = nil
+		race()
+	}
+	return r.TodoRepository.Create(ctx, todo)
+}
+
+func TestTodoServicePutRace(t *testing.T) {
+	db := sqlite.NewDb(t, "")
+	cfg := config.Default()
+	reads, err := storage.NewReadRouter(fxtest.NewLifecycle(t), db, cfg, logger.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := &racingRepository{TodoRepository: storage.NewTodoRepository(db, reads, logger.Nop(), cfg)}
+	svc := NewTodoService(repo, storage.NewAuditRepository(db, reads), storage.NewSyncRepository(db, reads), policy.NewEngine(storage.NewMembershipRepository(db)), storage.NewTxManager(db))
+	ctx := domain.WithPrincipal(t.Context(), domain.Principal{UserID: "alice"})
+	id := domain.NewUUID()
+	in := TodoInput{Description: "Second", DueDate: time.Now().Add(time.Hour)}
+	repo.race = func() {
+		_, _, err := svc.Put(ctx, id, TodoInput{Description: "First", DueDate: in.DueDate})
+		assert.NoError(t, err)
+	}
+
+	todo, created, err := svc.Put(ctx, id, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, created, "the put losing the race replaces the item")
+	assert.Equal(t, int64(2), todo.Version)
+	assert.Equal(t, "Second", todo.Description)
+}
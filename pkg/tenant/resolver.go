@@ -0,0 +1,131 @@
+package tenant
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"strings"
+
+	"github.com/taheri24/helitask/pkg/auth"
+	"github.com/taheri24/helitask/pkg/config"
+	"github.com/taheri24/helitask/pkg/domain"
+)
+
+var (
+	// ErrInvalidWorkspace is returned when a request names a malformed workspace id
+	ErrInvalidWorkspace = errors.New("invalid workspace id")
+	// ErrWorkspaceMismatch is returned when the header or subdomain differs from the token claim
+	ErrWorkspaceMismatch = errors.New("workspace does not match the bearer token")
+	// ErrNoWorkspaceClaim is returned when a required token claim is missing
+	ErrNoWorkspaceClaim = errors.New("bearer token names no workspace")
+)
+
+// Request holds what a request of any of the APIs tells about its workspace
+type Request struct {
+	// Claims are the verified claims of the bearer token, nil for anonymous requests
+	Claims auth.Claims
+	// Header returns the value of a header, or of the metadata of a gRPC call
+	Header func(name string) string
+	// Host is the host the request was sent to
+	Host string
+	// Peer is the address of the connection the request came on
+	Peer netip.Addr
+}
+
+// Resolver determines the workspace of a request, the same way for every API.
+// A token claim takes precedence over the header, which takes precedence over the subdomain.
+// When bearer tokens are verified, the header and the subdomain name the workspace only for
+// authenticated callers and trusted gateways, and a configured claim is required of everyone else.
+type Resolver struct {
+	cfg config.TenantConfig
+	// authenticating reports whether bearer tokens are verified at all
+	authenticating bool
+}
+
+// NewResolver creates a Resolver from the tenant and auth settings
+func NewResolver(cfg *config.Config) *Resolver {
+	tenant := cfg.Tenant
+	if tenant.Default == "" {
+		tenant.Default = domain.DefaultWorkspaceID
+	}
+	return &Resolver{cfg: tenant, authenticating: cfg.Auth.JWTSecret != ""}
+}
+
+// Resolve returns the workspace named by req. Anonymous requests that must carry a token fail
+// with domain.ErrUnauthenticated.
+func (r *Resolver) Resolve(req Request) (domain.Workspace, error) {
+	id := r.fromClaims(req.Claims)
+	named := ""
+	if r.cfg.Header != "" && req.Header != nil {
+		named = strings.ToLower(req.Header(r.cfg.Header))
+	}
+	if named == "" {
+		named = r.fromSubdomain(req.Host)
+	}
+	if r.authenticating && !r.trusted(req.Peer) {
+		switch {
+		case r.cfg.Claim != "" && req.Claims == nil:
+			return domain.Workspace{}, domain.ErrUnauthenticated
+		case r.cfg.Claim != "" && id == "":
+			return domain.Workspace{}, ErrNoWorkspaceClaim
+		case req.Claims == nil:
+			// anonymous callers stay in the default workspace
+			named = ""
+		}
+	}
+	if id != "" && named != "" && id != named {
+		return domain.Workspace{}, ErrWorkspaceMismatch
+	}
+	if id == "" {
+		id = named
+	}
+	if id == "" {
+		id = r.cfg.Default
+	}
+	if !domain.ValidWorkspaceID(id) {
+		return domain.Workspace{}, ErrInvalidWorkspace
+	}
+
+	quota := r.cfg.QuotaFor(id)
+	return domain.Workspace{ID: id, Quota: domain.Quota{
+		MaxTodos:           quota.MaxTodos,
+		MaxDescriptionSize: quota.MaxDescriptionSize,
+	}}, nil
+}
+
+func (r *Resolver) fromClaims(claims auth.Claims) string {
+	if r.cfg.Claim == "" {
+		return ""
+	}
+	return strings.ToLower(claims.String(r.cfg.Claim))
+}
+
+// fromSubdomain returns the leftmost label of hosts below the configured base domain
+func (r *Resolver) fromSubdomain(host string) string {
+	if r.cfg.BaseDomain == "" {
+		return ""
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	sub, ok := strings.CutSuffix(strings.ToLower(host), "."+strings.ToLower(r.cfg.BaseDomain))
+	if !ok || strings.Contains(sub, ".") {
+		return ""
+	}
+	return sub
+}
+
+// trusted reports whether peer is one of the trusted gateways. Forwarding headers are not
+// consulted, since any client can send them.
+func (r *Resolver) trusted(peer netip.Addr) bool {
+	if !peer.IsValid() {
+		return false
+	}
+	peer = peer.Unmap()
+	for _, prefix := range r.cfg.TrustedGateways {
+		if prefix.Contains(peer) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package tenant
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taheri24/helitask/pkg/auth"
+	"github.com/taheri24/helitask/pkg/config"
+	"github.com/taheri24/helitask/pkg/domain"
+)
+
+func TestResolver(t *testing.T) {
+	cfg := config.Default()
+	cfg.Tenant.BaseDomain = "todo.example.com"
+	cfg.Tenant.TrustedGateways = []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+	open := NewResolver(cfg)
+	cfg.Auth.JWTSecret = "test-secret"
+	authenticating := NewResolver(cfg)
+	cfg.Tenant.Claim = ""
+	withoutClaim := NewResolver(cfg)
+
+	header := func(workspace string) func(string) string {
+		return func(name string) string {
+			if name == "X-Workspace-ID" {
+				return workspace
+			}
+			return ""
+		}
+	}
+	alice := auth.Claims{"sub": "alice", "workspace": "team-a"}
+	gateway := netip.MustParseAddr("10.1.2.3")
+
+	type TestCase struct {
+		name      string
+		resolver  *Resolver
+		req       Request
+		workspace string
+		err       error
+	}
+	testCases := []TestCase{
+		{"Header without auth", open, Request{Header: header("team-a")}, "team-a", nil},
+		{"Subdomain without auth", open, Request{Host: "team-b.todo.example.com:8080"}, "team-b", nil},
+		{"Default without auth", open, Request{}, domain.DefaultWorkspaceID, nil},
+		{"Invalid workspace", open, Request{Header: header("Team_A!")}, "", ErrInvalidWorkspace},
+		{"Token claim", authenticating, Request{Claims: alice}, "team-a", nil},
+		{"Header agreeing with the claim", authenticating, Request{Claims: alice, Header: header("team-a")}, "team-a", nil},
+		{"Header differing from the claim", authenticating, Request{Claims: alice, Header: header("team-b")}, "", ErrWorkspaceMismatch},
+		{"Subdomain differing from the claim", authenticating, Request{Claims: alice, Host: "team-b.todo.example.com"}, "", ErrWorkspaceMismatch},
+		{"Token without the claim", authenticating, Request{Claims: auth.Claims{"sub": "alice"}}, "", ErrNoWorkspaceClaim},
+		{"Anonymous", authenticating, Request{Header: header("team-a")}, "", domain.ErrUnauthenticated},
+		{"Anonymous through a trusted gateway", authenticating, Request{Header: header("team-a"), Peer: gateway}, "team-a", nil},
+		{"Authenticated header without a claim", withoutClaim, Request{Claims: auth.Claims{"sub": "alice"}, Header: header("team-a")}, "team-a", nil},
+		{"Anonymous header without a claim", withoutClaim, Request{Header: header("team-a")}, domain.DefaultWorkspaceID, nil},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ws, err := tc.resolver.Resolve(tc.req)
+			assert.ErrorIs(t, err, tc.err)
+			assert.Equal(t, tc.workspace, ws.ID)
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/taheri24/helitask/pkg/config"
+	"github.com/taheri24/helitask/pkg/domain"
+	"github.com/taheri24/helitask/pkg/logger"
+	"go.uber.org/fx"
+)
+
+// Deliverer sends the due deliveries to their webhooks. A failed attempt is retried with
+// exponential backoff until the delivery runs out of attempts and is dead.
+type Deliverer struct {
+	webhooks   domain.WebhookRepository
+	deliveries domain.WebhookDeliveryRepository
+	client     *http.Client
+	cfg        config.WebhookConfig
+	now        func() time.Time
+}
+
+// NewDeliverer creates a Deliverer sending with a client bounded by the configured timeout,
+// which only connects to public addresses unless private targets are allowed
+func NewDeliverer(webhooks domain.WebhookRepository, deliveries domain.WebhookDeliveryRepository, cfg *config.Config) *Deliverer {
+	return &Deliverer{
+		webhooks:   webhooks,
+		deliveries: deliveries,
+		client:     newClient(cfg.Webhook),
+		cfg:        cfg.Webhook,
+		now:        time.Now,
+	}
+}
+
+// backoff returns the delay after the given number of failed attempts
+func (d *Deliverer) backoff(attempts int) time.Duration {
+	delay := d.cfg.RetryBackoff
+	for i := 1; i < attempts && delay < d.cfg.RetryMaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, d.cfg.RetryMaxBackoff)
+}
+
+// DeliverOnce sends up to a batch of due deliveries and returns how many were claimed.
+// Each delivery is claimed right before it is sent, so that its lease only has to outlast
+// its own attempt rather than those of the whole batch.
+func (d *Deliverer) DeliverOnce(ctx context.Context) (int, error) {
+	var errs []error
+	n := 0
+	for ; n < d.cfg.BatchSize; n++ {
+		// a claimed delivery is not due again before its attempt has timed out
+		claimed, err := d.deliveries.Claim(ctx, d.now(), 2*d.cfg.Timeout, 1)
+		if err != nil {
+			errs = append(errs, err)
+			break
+		}
+		if len(claimed) == 0 {
+			break
+		}
+		errs = append(errs, d.deliver(ctx, claimed[0]))
+	}
+	return n, errors.Join(errs...)
+}
+
+// deliver makes one attempt of delivery and stores its outcome, unless the delivery was
+// claimed again meanwhile
+func (d *Deliverer) deliver(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	claim := delivery.Claim()
+	wsCtx := domain.WithWorkspace(ctx, domain.Workspace{ID: delivery.WorkspaceID})
+	webhook, err := d.webhooks.GetByID(wsCtx, delivery.WebhookID)
+	if errors.Is(err, domain.ErrRecordNotFound) {
+		// the webhook was deleted together with its deliveries after the claim
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	delivery.Attempts++
+	var status int
+	if !webhook.Active {
+		err = errors.New("webhook is inactive")
+	} else {
+		status, err = d.send(ctx, webhook, delivery)
+	}
+	now := d.now()
+	delivery.LastStatusCode = status
+	switch {
+	case err == nil:
+		delivery.Status, delivery.LastError, delivery.DeliveredAt = domain.DeliveryDelivered, "", &now
+	case delivery.Attempts >= d.cfg.MaxAttempts || !webhook.Active:
+		delivery.Status, delivery.LastError = domain.DeliveryDead, err.Error()
+	default:
+		delivery.LastError, delivery.NextAttemptAt = err.Error(), now.Add(d.backoff(delivery.Attempts))
+	}
+	return d.deliveries.Save(wsCtx, delivery, claim)
+}
+
+// send posts the payload of delivery, signed with the secret of webhook. Any status but 2xx fails.
+func (d *Deliverer) send(ctx context.Context, webhook *domain.Webhook, delivery *domain.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := d.now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Helitask-Webhooks")
+	req.Header.Set(EventHeader, string(delivery.EventType))
+	req.Header.Set(DeliveryHeader, strconv.FormatUint(delivery.ID, 10))
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(webhook.Secret, timestamp, delivery.Payload))
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver answered %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// StartDeliverer sends due deliveries while the app runs, draining them before waiting for the next poll
+func StartDeliverer(lc fx.Lifecycle, deliverer *Deliverer, cfg *config.Config, logger logger.Logger) {
+	interval := cfg.Webhook.PollInterval
+	if interval <= 0 {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go func() {
+				defer close(done)
+				ticker := time.NewTicker(interval)
+				defer ticker.Stop()
+				for {
+					select {
+					case <-ctx.Done():
+						return
+					case <-ticker.C:
+						for {
+							n, err := deliverer.DeliverOnce(ctx)
+							if err != nil && ctx.Err() == nil {
+								logger.Error("Failed to deliver webhooks", err)
+							}
+							if n < cfg.Webhook.BatchSize || ctx.Err() != nil {
+								break
+							}
+						}
+					}
+				}
+			}()
+			return nil
+		},
+		OnStop: func(stopCtx context.Context) error {
+			cancel()
+			select {
+			case <-done:
+			case <-stopCtx.Done():
+			}
+			return nil
+		},
+	})
+}
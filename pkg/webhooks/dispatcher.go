@@ -0,0 +1,62 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/taheri24/helitask/pkg/domain"
+)
+
+// Dispatcher is the domain.EventPublisher enqueueing a delivery of every event for each
+// webhook subscribed to it. Enqueueing the same event again is a no-op, so the relay may
+// publish an event more than once.
+type Dispatcher struct {
+	webhooks   domain.WebhookRepository
+	deliveries domain.WebhookDeliveryRepository
+	policy     domain.Policy
+}
+
+// NewDispatcher creates a Dispatcher
+func NewDispatcher(webhooks domain.WebhookRepository, deliveries domain.WebhookDeliveryRepository, policy domain.Policy) *Dispatcher {
+	return &Dispatcher{webhooks: webhooks, deliveries: deliveries, policy: policy}
+}
+
+// Publish implements domain.EventPublisher. A webhook only gets the events of the items its
+// owner may read.
+func (d *Dispatcher) Publish(ctx context.Context, event *domain.Event) error {
+	ctx = domain.WithWorkspace(ctx, domain.Workspace{ID: event.WorkspaceID})
+	webhooks, err := d.webhooks.Subscribed(ctx, event.Type)
+	if err != nil || len(webhooks) == 0 {
+		return err
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event %s: %w", event.ID, err)
+	}
+	resource := domain.Resource{ListID: event.Todo.ListID, OwnerID: event.Todo.OwnerID}
+	now := time.Now()
+	var deliveries []*domain.WebhookDelivery
+	for _, webhook := range webhooks {
+		owner := domain.WithPrincipal(ctx, domain.Principal{UserID: webhook.OwnerID})
+		err := d.policy.Authorize(owner, domain.ActionReadTodo, resource)
+		if errors.Is(err, domain.ErrForbidden) || errors.Is(err, domain.ErrUnauthenticated) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		deliveries = append(deliveries, &domain.WebhookDelivery{
+			WorkspaceID:   event.WorkspaceID,
+			WebhookID:     webhook.ID,
+			EventID:       event.ID,
+			EventType:     event.Type,
+			Payload:       payload,
+			Status:        domain.DeliveryPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+		})
+	}
+	return d.deliveries.Enqueue(ctx, deliveries)
+}
@@ -0,0 +1,14 @@
+package webhooks
+
+import (
+	"github.com/taheri24/helitask/pkg/domain"
+	"github.com/taheri24/helitask/pkg/events"
+	"go.uber.org/fx"
+)
+
+// Module adds the Dispatcher to the publishers of the event relay and provides the Deliverer.
+// Deliveries are sent once StartDeliverer is invoked.
+var Module = fx.Module("webhooks", fx.Provide(
+	NewDeliverer,
+	fx.Annotate(NewDispatcher, fx.As(new(domain.EventPublisher)), fx.ResultTags(events.PublisherGroup)),
+))
@@ -0,0 +1,39 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+)
+
+const (
+	// SignatureHeader carries "sha256=" and the hex HMAC-SHA256 of the timestamp, a dot and the body
+	SignatureHeader = "X-Helitask-Signature"
+	// TimestampHeader carries the Unix time of the attempt, letting receivers reject replayed requests
+	TimestampHeader = "X-Helitask-Timestamp"
+	// EventHeader carries the type of the delivered event
+	EventHeader = "X-Helitask-Event"
+	// DeliveryHeader carries the id of the delivery, which is the same for all its attempts
+	DeliveryHeader = "X-Helitask-Delivery"
+)
+
+// Sign returns the signature header value of a delivery body sent at timestamp
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is the signature of body sent at timestamp
+func Verify(secret, signature string, timestamp int64, body []byte) bool {
+	return hmac.Equal([]byte(signature), []byte(Sign(secret, timestamp, body)))
+}
+
+// NewSecret returns a random secret for a webhook created without one
+func NewSecret() string {
+	return rand.Text()
+}
@@ -0,0 +1,94 @@
+package webhooks
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+
+	"github.com/taheri24/helitask/pkg/config"
+)
+
+// ErrForbiddenTarget is returned for webhook targets on addresses that are not public
+var ErrForbiddenTarget = errors.New("webhook target is not a public address")
+
+// reservedPrefixes are the ranges beyond the private, loopback and link-local ones that are
+// not reachable on the public internet
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+// checkAddr fails with ErrForbiddenTarget unless addr is a public unicast address
+func checkAddr(addr netip.Addr) error {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return ErrForbiddenTarget
+	}
+	for _, prefix := range reservedPrefixes {
+		if prefix.Contains(addr) {
+			return ErrForbiddenTarget
+		}
+	}
+	return nil
+}
+
+// ValidateURL checks that rawURL is an absolute http or https URL. Unless cfg allows private
+// targets, hosts that are local names or addresses that are not public are rejected as well;
+// names resolving to such addresses are only caught when a delivery dials them.
+func ValidateURL(rawURL string, cfg config.WebhookConfig) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("url must be an absolute http or https URL")
+	}
+	if cfg.AllowPrivateTargets {
+		return nil
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("url must not target a local address")
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && checkAddr(addr) != nil {
+		return fmt.Errorf("url must not target a private, loopback or link-local address")
+	}
+	return nil
+}
+
+// newClient returns the client of the deliveries. Unless cfg allows private targets, it refuses
+// to connect to addresses that are not public once the host of a target has been resolved, so
+// that a webhook cannot reach the internal network of the server. Redirects are not followed,
+// and their responses fail the attempt like any other answer but 2xx.
+func newClient(cfg config.WebhookConfig) *http.Client {
+	dialer := &net.Dialer{Timeout: cfg.Timeout}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if !cfg.AllowPrivateTargets {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if err := checkAddr(addrPort.Addr()); err != nil {
+				return fmt.Errorf("failed to dial %s, %w", address, err)
+			}
+			return nil
+		}
+		// a proxy would connect to the target on behalf of the client, unchecked
+		transport.Proxy = nil
+	}
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   cfg.Timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
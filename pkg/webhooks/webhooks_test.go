@@ -0,0 +1,190 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/taheri24/helitask/pkg/config"
+	"github.com/taheri24/helitask/pkg/domain"
+	"github.com/taheri24/helitask/pkg/policy"
+	"github.com/taheri24/helitask/pkg/ports/storage"
+	"github.com/taheri24/helitask/pkg/ports/storage/sqlite"
+)
+
+// receiver is a local webhook endpoint answering with status and recording the verified requests
+type receiver struct {
+	mu       sync.Mutex
+	secret   string
+	status   int
+	received []*domain.Event
+	invalid  int
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	body, _ := io.ReadAll(r.Body)
+	timestamp, _ := strconv.ParseInt(r.Header.Get(TimestampHeader), 10, 64)
+	if !Verify(rc.secret, r.Header.Get(SignatureHeader), timestamp, body) {
+		rc.invalid++
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	var event domain.Event
+	if err := json.Unmarshal(body, &event); err != nil || r.Header.Get(EventHeader) != string(event.Type) {
+		rc.invalid++
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	rc.received = append(rc.received, &event)
+	w.WriteHeader(rc.status)
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	db := sqlite.NewDb(t, "")
+	cfg := config.Default()
+	cfg.Webhook.MaxAttempts, cfg.Webhook.RetryBackoff, cfg.Webhook.RetryMaxBackoff = 3, time.Minute, time.Hour
+	// the receiver listens on the loopback interface
+	cfg.Webhook.AllowPrivateTargets = true
+	hooks, deliveries := storage.NewWebhookRepository(db), storage.NewWebhookDeliveryRepository(db)
+	dispatcher := NewDispatcher(hooks, deliveries, policy.NewEngine(storage.NewMembershipRepository(db)))
+	deliverer := NewDeliverer(hooks, deliveries, cfg)
+	// the deliverer runs a little after the events are dispatched
+	now := time.Now().Add(time.Second)
+	deliverer.now = func() time.Time { return now }
+	ctx := t.Context()
+
+	rc := &receiver{secret: "s3cret", status: http.StatusOK}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+	webhook := &domain.Webhook{ID: domain.NewUUID(), OwnerID: "alice", URL: server.URL, Secret: rc.secret, Active: true,
+		Events: []domain.EventType{domain.TodoCreated, domain.TodoCompleted}}
+	assert.NoError(t, hooks.Create(ctx, webhook))
+
+	event := func(owner string) *domain.Event {
+		todo := &domain.TodoItem{ID: domain.NewUUID(), WorkspaceID: domain.DefaultWorkspaceID, OwnerID: owner, Description: "Hooked"}
+		return domain.NewTodoEvent(ctx, domain.TodoCreated, todo, now)
+	}
+	created := event("alice")
+	assert.NoError(t, dispatcher.Publish(ctx, created))
+	assert.NoError(t, dispatcher.Publish(ctx, created), "an event published again is not delivered twice")
+	assert.NoError(t, dispatcher.Publish(ctx, event("bob")), "alice may not read the items of bob")
+	updated := event("alice")
+	updated.Type = domain.TodoUpdated
+	assert.NoError(t, dispatcher.Publish(ctx, updated), "the webhook is not subscribed to updates")
+
+	n, err := deliverer.DeliverOnce(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	if assert.Len(t, rc.received, 1) {
+		assert.Equal(t, created.ID, rc.received[0].ID)
+	}
+	assert.Zero(t, rc.invalid, "deliveries are signed with the secret of the webhook")
+	log, err := deliveries.List(ctx, webhook.ID, "", domain.Page{Limit: 10})
+	if assert.NoError(t, err) && assert.Len(t, log, 1) {
+		assert.Equal(t, domain.DeliveryDelivered, log[0].Status)
+		assert.Equal(t, http.StatusOK, log[0].LastStatusCode)
+	}
+
+	// a failing receiver is retried with exponential backoff until the delivery is dead
+	rc.status = http.StatusServiceUnavailable
+	assert.NoError(t, dispatcher.Publish(ctx, event("alice")))
+	var delays []time.Duration
+	for range 3 {
+		n, err = deliverer.DeliverOnce(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, n)
+		pending, err := deliveries.List(ctx, webhook.ID, domain.DeliveryPending, domain.Page{Limit: 10})
+		assert.NoError(t, err)
+		if len(pending) == 0 {
+			break
+		}
+		delays = append(delays, pending[0].NextAttemptAt.Sub(now))
+		n, err = deliverer.DeliverOnce(ctx)
+		assert.NoError(t, err)
+		assert.Zero(t, n, "a delivery is not retried before its backoff")
+		now = pending[0].NextAttemptAt
+	}
+	assert.Equal(t, []time.Duration{time.Minute, 2 * time.Minute}, delays)
+	dead, err := deliveries.List(ctx, webhook.ID, domain.DeliveryDead, domain.Page{Limit: 10})
+	if assert.NoError(t, err) && assert.Len(t, dead, 1) {
+		assert.Equal(t, 3, dead[0].Attempts)
+		assert.Equal(t, http.StatusServiceUnavailable, dead[0].LastStatusCode)
+		assert.Contains(t, dead[0].LastError, "503")
+
+		rc.status = http.StatusNoContent
+		assert.NoError(t, deliveries.Retry(ctx, webhook.ID, dead[0].ID, now))
+		n, err = deliverer.DeliverOnce(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, n)
+	}
+	dead, err = deliveries.List(ctx, webhook.ID, domain.DeliveryDead, domain.Page{Limit: 10})
+	assert.NoError(t, err)
+	assert.Empty(t, dead)
+	assert.Len(t, rc.received, 5)
+
+	// an attempt outlasting its lease does not overwrite the attempt of the next claim
+	assert.NoError(t, dispatcher.Publish(ctx, event("alice")))
+	stale, err := deliveries.Claim(ctx, now, time.Second, 1)
+	if assert.NoError(t, err) && assert.Len(t, stale, 1) {
+		reclaimed, err := deliveries.Claim(ctx, now.Add(2*time.Second), time.Second, 1)
+		if assert.NoError(t, err) && assert.Len(t, reclaimed, 1) {
+			claim := stale[0].Claim()
+			stale[0].Attempts, stale[0].Status = 1, domain.DeliveryDead
+			assert.ErrorIs(t, deliveries.Save(ctx, stale[0], claim), domain.ErrClaimLost)
+			claim = reclaimed[0].Claim()
+			reclaimed[0].Attempts, reclaimed[0].Status = 1, domain.DeliveryDelivered
+			assert.NoError(t, deliveries.Save(ctx, reclaimed[0], claim))
+		}
+	}
+}
+
+func TestClient(t *testing.T) {
+	var redirected bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/internal" {
+			redirected = true
+		}
+		http.Redirect(w, r, "/internal", http.StatusFound)
+	}))
+	defer server.Close()
+	cfg := config.Default().Webhook
+
+	_, err := newClient(cfg).Get(server.URL)
+	assert.ErrorIs(t, err, ErrForbiddenTarget, "loopback addresses are refused at dial time")
+
+	cfg.AllowPrivateTargets = true
+	resp, err := newClient(cfg).Get(server.URL)
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusFound, resp.StatusCode)
+		assert.False(t, redirected, "redirects are not followed")
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	cfg := config.Default().Webhook
+	assert.NoError(t, ValidateURL("https://example.com/hook", cfg))
+	assert.NoError(t, ValidateURL("http://93.184.215.14:8080/hook", cfg))
+	for _, target := range []string{"ftp://example.com", "/hook", "http://10.1.2.3/", "http://192.168.0.1/", "http://[::1]/", "http://[fe80::1]/", "http://0.0.0.0/", "http://100.64.0.1/", "http://LOCALHOST./"} {
+		assert.Error(t, ValidateURL(target, cfg), target)
+	}
+	cfg.AllowPrivateTargets = true
+	assert.NoError(t, ValidateURL("http://127.0.0.1:8080/hook", cfg))
+}
+
+func TestSignature(t *testing.T) {
+	body := []byte(`{"type":"todo.created"}`)
+	signature := Sign("secret", 1700000000, body)
+	assert.True(t, Verify("secret", signature, 1700000000, body))
+	assert.False(t, Verify("other", signature, 1700000000, body))
+	assert.False(t, Verify("secret", signature, 1700000001, body), "the timestamp is signed")
+	assert.False(t, Verify("secret", signature, 1700000000, []byte(`{}`)))
+}